@@ -1,7 +1,10 @@
 package main
 
-import "flashcard_service/pkg/drivers"
+import (
+	"flashcard_service/pkg/drivers"
+	"os"
+)
 
 func main() {
-	drivers.Run()
+	drivers.RunCommand(os.Args[1:])
 }
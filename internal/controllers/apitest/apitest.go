@@ -0,0 +1,145 @@
+// Package apitest serves the whole API on an in-memory SQLite database and an
+// in-memory cache store, for controller tests that go through the router.
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/worker"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/sqlite"
+	"flashcard_service/pkg/drivers"
+	"flashcard_service/pkg/objects"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type Server struct {
+	T       *testing.T
+	Handler http.Handler
+	DB      database.Database
+	Store   cache.Store
+	Service *category.CategoryService
+}
+
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+	db := sqlite.NewSQLiteWithPath(":memory:")
+	err := db.Connect()
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	store := cache.NewMemoryStore()
+	queue := worker.NewQueue(2, 100, 3, time.Millisecond)
+	t.Cleanup(func() {
+		queue.Close()
+		db.Close()
+	})
+	service := category.NewCategoryService(store, queue)
+	return &Server{
+		T:       t,
+		Handler: drivers.NewRouter(db, store, service),
+		DB:      db,
+		Store:   store,
+		Service: service,
+	}
+}
+
+// Request sends body as JSON to url on behalf of userId. headers are name,
+// value pairs.
+func (s *Server) Request(method string, url string, userId string, body any, headers ...string) *httptest.ResponseRecorder {
+	s.T.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+	req := httptest.NewRequest(method, url, &reader)
+	if len(userId) > 0 {
+		req.Header.Set(constant.UserIdHeader, userId)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// Exec runs a statement on the database behind the API, to set up state the
+// API cannot, such as old timestamps.
+func (s *Server) Exec(query string, args ...any) {
+	s.T.Helper()
+	_, cancel, err := s.DB.Exec(query, args...)
+	defer cancel()
+	if err != nil {
+		s.T.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+func (s *Server) CreateCategory(userId string, name string) model.Category {
+	s.T.Helper()
+	rec := s.Request(http.MethodPost, "/api/v1/category", userId, map[string]string{"name": name})
+	Expect(s.T, rec, http.StatusCreated)
+	return Decode[model.Category](s.T, rec)
+}
+
+func (s *Server) CreateFlashcard(userId string, categoryId int64, name string, content string) model.Flashcard {
+	s.T.Helper()
+	body := []map[string]string{{"name": name, "content": content}}
+	rec := s.Request(http.MethodPost, "/api/v1/category/"+strconv.FormatInt(categoryId, 10)+"/flashcards", userId, body)
+	Expect(s.T, rec, http.StatusCreated)
+	return Decode[[]model.Flashcard](s.T, rec)[0]
+}
+
+// Envelope decodes the ApiResponse every endpoint writes, checking that its
+// code matches the HTTP status.
+func Envelope(t *testing.T, rec *httptest.ResponseRecorder) objects.ApiResponse {
+	t.Helper()
+	var response objects.ApiResponse
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if response.Code != rec.Code {
+		t.Fatalf("envelope code %d, HTTP status %d", response.Code, rec.Code)
+	}
+	return response
+}
+
+// Decode returns the data of the envelope as T.
+func Decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var response struct {
+		Data T `json:"data"`
+	}
+	Envelope(t, rec)
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return response.Data
+}
+
+func Expect(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func ExpectError(t *testing.T, rec *httptest.ResponseRecorder, want int, errorCode string) objects.ApiResponse {
+	t.Helper()
+	Expect(t, rec, want)
+	response := Envelope(t, rec)
+	if response.ErrorCode != errorCode {
+		t.Fatalf("errorCode = %q, want %q", response.ErrorCode, errorCode)
+	}
+	return response
+}
@@ -1,10 +1,26 @@
 package app
 
-import "net/http"
+import (
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/pkg/utils"
+	"net/http"
+)
 
-type AppController struct{}
+type AppController struct {
+	categoryService *category.CategoryService
+}
+
+func NewAppController(categoryService *category.CategoryService) *AppController {
+	return &AppController{
+		categoryService: categoryService,
+	}
+}
 
 func (a *AppController) HeathCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	utils.WriteJSON(w, r, http.StatusOK, "OK")
+}
+
+// CacheStats reports the hit counters of each cache tier of this instance.
+func (a *AppController) CacheStats(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, r, http.StatusOK, a.categoryService.CacheStats())
 }
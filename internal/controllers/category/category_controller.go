@@ -1,17 +1,20 @@
 package category
 
 import (
-	"encoding/json"
+	"database/sql"
 	"errors"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
 	"flashcard_service/internal/repositories"
 	"flashcard_service/pkg/constant"
 	"flashcard_service/pkg/database"
-	"flashcard_service/pkg/database/mysql/repositories_impl"
-	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/database/repositories_impl"
 	"flashcard_service/pkg/objects"
 	"flashcard_service/pkg/utils"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 
@@ -21,36 +24,93 @@ import (
 type CategoryController struct {
 	categoryRepo  repositories.CategoryRepository
 	flashcardRepo repositories.FlashcardRepository
-	redis         *redis.RedisDatabase
+	revisionRepo  repositories.FlashcardRevisionRepository
+	// primary reads from the primary database, for reads that must see a
+	// write made just before.
+	primary readRepositories
+	// maxFlashcardBatch caps how many flashcards one create request holds.
+	maxFlashcardBatch int
 	*CategoryService
 }
 
-func NewCategoryController(db database.Database, redis *redis.RedisDatabase) *CategoryController {
+type readRepositories struct {
+	category  repositories.CategoryRepository
+	flashcard repositories.FlashcardRepository
+	revision  repositories.FlashcardRevisionRepository
+}
+
+func NewCategoryController(db database.Database, categoryService *CategoryService) *CategoryController {
+	maxFlashcardBatch, err := strconv.Atoi(os.Getenv("FLASHCARD_BATCH_MAX_SIZE"))
+	if err != nil || maxFlashcardBatch <= 0 {
+		maxFlashcardBatch = 100
+	}
+	primary := db.Primary()
 	return &CategoryController{
 		categoryRepo:  repositories_impl.NewCategoryRepositoryImpl(db),
 		flashcardRepo: repositories_impl.NewFlashcardRepositoryImpl(db),
-		redis:         redis,
-		CategoryService: &CategoryService{
-			r: redis,
+		revisionRepo:  repositories_impl.NewFlashcardRevisionRepositoryImpl(db),
+		primary: readRepositories{
+			category:  repositories_impl.NewCategoryRepositoryImpl(primary),
+			flashcard: repositories_impl.NewFlashcardRepositoryImpl(primary),
+			revision:  repositories_impl.NewFlashcardRevisionRepositoryImpl(primary),
 		},
+		maxFlashcardBatch: maxFlashcardBatch,
+		CategoryService:   categoryService,
+	}
+}
+
+// isBatchInvalid rejects an empty list of flashcards or one larger than
+// maxFlashcardBatch.
+func (c *CategoryController) isBatchInvalid(field string, size int, r *http.Request, trackingId string) bool {
+	var detail objects.FieldError
+	switch {
+	case size == 0:
+		detail = utils.RequiredField(field)
+	case size > c.maxFlashcardBatch:
+		detail = utils.TooManyField(field, c.maxFlashcardBatch)
+	default:
+		return false
+	}
+	msg := "flashcards batch invalid: " + strconv.Itoa(size) + " items"
+	log.Error().
+		Str("trackingId", trackingId).
+		Str("error", msg).
+		Msg("")
+	utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(detail), errors.New(msg))
+	return true
+}
+
+// readFrom returns the repositories a read of the user's data should use:
+// the primary right after the user wrote, the replicas otherwise.
+func (c *CategoryController) readFrom(userId string) readRepositories {
+	if c.CategoryService.WroteRecently(userId) {
+		return c.primary
+	}
+	return readRepositories{
+		category:  c.categoryRepo,
+		flashcard: c.flashcardRepo,
+		revision:  c.revisionRepo,
 	}
 }
 
 func (c *CategoryController) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
 	userId := r.Header.Get(constant.UserIdHeader)
-	if c.isUserIdInvalid(userId, r, trackingId) {
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
 	var createCategoryRequest objects.CreateCategory
-	err := json.NewDecoder(r.Body).Decode(&createCategoryRequest)
+	appErr, err := utils.DecodeJSON(w, r, &createCategoryRequest)
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
 			Str("error", "error when parse create category request: "+err.Error()).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, err)
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(createCategoryRequest, r, trackingId) {
 		return
 	}
 
@@ -64,33 +124,33 @@ func (c *CategoryController) CreateCategory(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	go func() {
-		category := createCategoryRequest.ToCategory()
-		category.Id = id
-		err = c.CategoryService.SaveCategoryToRedisHash(userId, strconv.FormatInt(id, 10), category)
-		if err != nil {
-			log.Info().Msg("Failed to update Redis cache after database update: " + err.Error())
-		}
-	}()
+	c.CategoryService.InvalidateCategories(userId)
+	category, err := c.primary.category.FindOneById(userId, strconv.FormatInt(id, 10))
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get created category: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	c.CategoryService.PublishEvent(events.NewEvent(events.CategoryCreated, userId, strconv.FormatInt(id, 10), "", category))
 
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set(constant.LocationHeader, strings.TrimSuffix(r.URL.Path, "/")+"/"+strconv.FormatInt(id, 10))
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(category.Version))
+	utils.WriteJSON(w, r, http.StatusCreated, category)
 }
 
 func (c *CategoryController) GetAllCategory(w http.ResponseWriter, r *http.Request) {
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
 	userId := r.Header.Get(constant.UserIdHeader)
-	if c.isUserIdInvalid(userId, r, trackingId) {
-		return
-	}
-
-	cachedCategories, err := c.CategoryService.GetCategoriesFromRedisHash(userId)
-	if err == nil && len(cachedCategories) > 0 {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(cachedCategories)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
-	categories, err := c.categoryRepo.FindAll(userId)
+	categories, err := c.CategoryService.GetCategories(userId, func() ([]model.Category, error) {
+		return c.readFrom(userId).category.FindAll(userId)
+	})
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
@@ -100,22 +160,57 @@ func (c *CategoryController) GetAllCategory(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	go func() {
-		err = c.CategoryService.SaveCategoriesToRedisHash(userId, categories)
-		if err != nil {
-			log.Info().Msg(err.Error())
+	utils.WriteJSONWithETag(w, r, "", categories)
+}
+
+func (c *CategoryController) GetCategory(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if len(id) == 0 {
+		msg := "error when get category: id is empty"
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField("id")), errors.New(msg))
+		return
+	}
+
+	if c.CategoryService.CanReadCache(userId) {
+		cachedCategory, err := c.CategoryService.GetCategoryFromRedisHash(userId, id)
+		if err == nil {
+			utils.WriteJSONWithETag(w, r, utils.VersionETag(cachedCategory.Version), cachedCategory)
 			return
 		}
-	}()
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(categories)
+	category, err := c.readFrom(userId).category.FindOneById(userId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrCategoryNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get category: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, utils.VersionETag(category.Version), category)
 }
 
 func (c *CategoryController) DeleteCategory(w http.ResponseWriter, r *http.Request) {
 	userId := r.Header.Get(constant.UserIdHeader)
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
-	if c.isUserIdInvalid(userId, r, trackingId) {
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
@@ -127,11 +222,24 @@ func (c *CategoryController) DeleteCategory(w http.ResponseWriter, r *http.Reque
 			Str("trackingId", trackingId).
 			Str("error", msg).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, errors.New(msg))
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField("id")), errors.New(msg))
 		return
 	}
 
-	err := c.categoryRepo.DeleteById(userId, id)
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
+		return
+	}
+
+	err := c.categoryRepo.DeleteById(userId, id, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrCategoryNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeCategoryPreconditionFailed(w, r, userId, id, trackingId)
+		return
+	}
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
@@ -140,31 +248,30 @@ func (c *CategoryController) DeleteCategory(w http.ResponseWriter, r *http.Reque
 		utils.SetHttpReponseError(r, utils.ErrServerError, err)
 		return
 	}
-	go func() {
-		err = c.redis.HDel(userId, id)
-		if err != nil {
-			log.Info().Msg("Failed to update Redis cache after database update: " + err.Error())
-		}
-	}()
+	c.CategoryService.InvalidateCategories(userId, id)
+	c.CategoryService.PublishEvent(events.NewEvent(events.CategoryDeleted, userId, id, "", nil))
 
-	w.WriteHeader(http.StatusOK)
+	utils.WriteJSON(w, r, http.StatusOK, nil)
 }
 
 func (c *CategoryController) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 	userId := r.Header.Get(constant.UserIdHeader)
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
-	if c.isUserIdInvalid(userId, r, trackingId) {
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
 	var updateCategoryRequest objects.UpdateCategory
-	err := json.NewDecoder(r.Body).Decode(&updateCategoryRequest)
+	appErr, err := utils.DecodeJSON(w, r, &updateCategoryRequest)
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
 			Str("error", "error when parse update category request: "+err.Error()).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, err)
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(updateCategoryRequest, r, trackingId) {
 		return
 	}
 
@@ -176,11 +283,24 @@ func (c *CategoryController) UpdateCategory(w http.ResponseWriter, r *http.Reque
 			Str("trackingId", trackingId).
 			Str("error", msg).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, errors.New(msg))
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField("id")), errors.New(msg))
+		return
+	}
+
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
 		return
 	}
 
-	err = c.categoryRepo.UpdateById(userId, id, updateCategoryRequest.Name)
+	err = c.categoryRepo.UpdateById(userId, id, updateCategoryRequest.Name, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrCategoryNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeCategoryPreconditionFailed(w, r, userId, id, trackingId)
+		return
+	}
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
@@ -190,26 +310,94 @@ func (c *CategoryController) UpdateCategory(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	go func() {
-		cachedCategory, err := c.CategoryService.GetCategoryFromRedisHash(userId, id)
-		if err == nil {
-			cachedCategory.Name = updateCategoryRequest.Name
-			err = c.CategoryService.SaveCategoryToRedisHash(userId, id, cachedCategory)
-			if err != nil {
-				log.Info().Msg("Failed to update Redis cache after database update: " + err.Error())
-			}
-		} else {
-			log.Info().Msg("Cache miss when updating category: " + err.Error())
-		}
-	}()
+	c.CategoryService.InvalidateCategories(userId)
+	category, err := c.primary.category.FindOneById(userId, id)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get updated category: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	c.CategoryService.PublishEvent(events.NewEvent(events.CategoryUpdated, userId, id, "", category))
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(category.Version))
+	utils.WriteJSON(w, r, http.StatusOK, category)
+}
+
+// PatchCategory applies a JSON Merge Patch (RFC 7396) to a category: only the
+// fields present in the body change.
+func (c *CategoryController) PatchCategory(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	var patch objects.PatchCategory
+	appErr, err := utils.DecodeJSON(w, r, &patch)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse patch category request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(patch, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if len(id) == 0 {
+		msg := "error when patch category: id is empty"
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField("id")), errors.New(msg))
+		return
+	}
+
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
+		return
+	}
+
+	generation := c.CategoryService.CacheGeneration(userId)
+	category, err := c.categoryRepo.PatchById(userId, id, patch, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrCategoryNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeCategoryPreconditionFailed(w, r, userId, id, trackingId)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when patch category: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	if !patch.IsEmpty() {
+		c.CategoryService.UpdateCachedCategory(userId, generation, category)
+		c.CategoryService.PublishEvent(events.NewEvent(events.CategoryUpdated, userId, id, "", category))
+	}
+
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(category.Version))
+	utils.WriteJSON(w, r, http.StatusOK, category)
 }
 
 func (c *CategoryController) GetFlashcardsByCategoryId(w http.ResponseWriter, r *http.Request) {
 	userId := r.Header.Get(constant.UserIdHeader)
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
-	if c.isUserIdInvalid(userId, r, trackingId) {
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
@@ -221,42 +409,63 @@ func (c *CategoryController) GetFlashcardsByCategoryId(w http.ResponseWriter, r
 			Str("trackingId", trackingId).
 			Str("error", msg).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, errors.New(msg))
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField("category_id")), errors.New(msg))
+		return
+	}
+
+	flashcards, err := c.CategoryService.GetFlashcards(userId, categoryId, func() ([]model.Flashcard, error) {
+		return c.readFrom(userId).flashcard.FindByCategoryId(userId, categoryId)
+	})
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get flashcards by category id: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSONWithETag(w, r, "", flashcards)
+}
+
+func (c *CategoryController) GetFlashcard(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
-	cachedFlashcards, err := c.CategoryService.GetFlashcardsFromRedisHash(userId, categoryId)
-	if err == nil && len(cachedFlashcards) > 0 {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(cachedFlashcards)
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+	flashcardId := vars["flashcard_id"]
+	if c.isFlashcardAndCategoryInvalid(categoryId, flashcardId, r, trackingId) {
 		return
 	}
 
-	flashcards, err := c.flashcardRepo.FindByCategoryId(userId, categoryId)
+	flashcard, err := c.readFrom(userId).flashcard.FindOneById(userId, flashcardId)
+	if err == nil && strconv.Itoa(flashcard.CategoryId) != categoryId {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return
+	}
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
-			Str("error", "error when get flashcards by category id: "+err.Error()).
+			Str("error", "error when get flashcard: "+err.Error()).
 			Msg("")
 		utils.SetHttpReponseError(r, utils.ErrServerError, err)
 		return
 	}
 
-	go func() {
-		err = c.CategoryService.SaveFlashcardsToRedisHash(userId, categoryId, flashcards)
-		if err != nil {
-			log.Info().Msg(err.Error())
-		}
-	}()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(flashcards)
+	utils.WriteJSONWithETag(w, r, utils.VersionETag(flashcard.Version), flashcard)
 }
 
 func (c *CategoryController) CreateNewFlashcards(w http.ResponseWriter, r *http.Request) {
 	userId := r.Header.Get(constant.UserIdHeader)
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
-	if c.isUserIdInvalid(userId, r, trackingId) {
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
@@ -268,22 +477,28 @@ func (c *CategoryController) CreateNewFlashcards(w http.ResponseWriter, r *http.
 			Str("trackingId", trackingId).
 			Str("error", msg).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, errors.New(msg))
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField("category_id")), errors.New(msg))
 		return
 	}
 
 	var createFlashcardsRequest []objects.CreateFlashcard
-	err := json.NewDecoder(r.Body).Decode(&createFlashcardsRequest)
+	appErr, err := utils.DecodeJSON(w, r, &createFlashcardsRequest)
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
 			Str("error", "error when parse create flashcards request: "+err.Error()).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, err)
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isBatchInvalid("flashcards", len(createFlashcardsRequest), r, trackingId) || c.isRequestInvalid(createFlashcardsRequest, r, trackingId) {
+		return
+	}
+	if c.isCategoryMissing(userId, categoryId, r, trackingId) {
 		return
 	}
 
-	err = c.flashcardRepo.InsertManyByUserId(userId, categoryId, createFlashcardsRequest)
+	flashcards, err := c.flashcardRepo.InsertManyByUserId(userId, categoryId, createFlashcardsRequest)
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
@@ -293,25 +508,24 @@ func (c *CategoryController) CreateNewFlashcards(w http.ResponseWriter, r *http.
 		return
 	}
 
-	go func() {
-		flashcards, err := c.flashcardRepo.FindByCategoryId(userId, categoryId)
-		if err != nil {
-			log.Info().Msg("Failed to fetch flashcards for cache update: " + err.Error())
-			return
-		}
-		err = c.CategoryService.SaveFlashcardsToRedisHash(userId, categoryId, flashcards)
-		if err != nil {
-			log.Info().Msg("Failed to update Redis cache after creating flashcards: " + err.Error())
-		}
-	}()
+	c.CategoryService.InvalidateFlashcards(userId, categoryId)
+	for _, flashcard := range flashcards {
+		c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardCreated, userId, categoryId, strconv.FormatInt(flashcard.ID, 10), flashcard))
+	}
 
-	w.WriteHeader(http.StatusCreated)
+	// A single card is located by its own URL, a batch by the deck it joined.
+	location := strings.TrimSuffix(r.URL.Path, "/")
+	if len(flashcards) == 1 {
+		location += "/" + strconv.FormatInt(flashcards[0].ID, 10)
+	}
+	w.Header().Set(constant.LocationHeader, location)
+	utils.WriteJSON(w, r, http.StatusCreated, flashcards)
 }
 
 func (c *CategoryController) DeleteFlashcard(w http.ResponseWriter, r *http.Request) {
 	userId := r.Header.Get(constant.UserIdHeader)
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
-	if c.isUserIdInvalid(userId, r, trackingId) {
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
@@ -322,7 +536,26 @@ func (c *CategoryController) DeleteFlashcard(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err := c.flashcardRepo.DeleteById(userId, flashcardId)
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
+		return
+	}
+
+	// A card reached through another category's URL is not found there, so
+	// the deck that is invalidated below is always the one that held it.
+	if _, ok := c.flashcardInCategory(userId, categoryId, flashcardId, r, trackingId); !ok {
+		return
+	}
+
+	err := c.flashcardRepo.DeleteById(userId, flashcardId, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeFlashcardPreconditionFailed(w, r, userId, flashcardId, trackingId)
+		return
+	}
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
@@ -332,20 +565,16 @@ func (c *CategoryController) DeleteFlashcard(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	go func() {
-		err = c.CategoryService.DeleteFlashcardFromRedisHash(userId, categoryId, flashcardId)
-		if err != nil {
-			log.Info().Msg("Failed to delete Redis cache after database delete: " + err.Error())
-		}
-	}()
+	c.CategoryService.InvalidateFlashcards(userId, categoryId)
+	c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardDeleted, userId, categoryId, flashcardId, nil))
 
-	w.WriteHeader(http.StatusOK)
+	utils.WriteJSON(w, r, http.StatusOK, nil)
 }
 
 func (c *CategoryController) UpdateFlashcard(w http.ResponseWriter, r *http.Request) {
 	userId := r.Header.Get(constant.UserIdHeader)
 	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
-	if c.isUserIdInvalid(userId, r, trackingId) {
+	if c.IsUserIdInvalid(userId, r, trackingId) {
 		return
 	}
 
@@ -357,18 +586,42 @@ func (c *CategoryController) UpdateFlashcard(w http.ResponseWriter, r *http.Requ
 	}
 
 	var updateFlashcardRequest objects.UpdateFlashcard
-	err := json.NewDecoder(r.Body).Decode(&updateFlashcardRequest)
+	appErr, err := utils.DecodeJSON(w, r, &updateFlashcardRequest)
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
 			Str("error", "error when parse update flashcard request: "+err.Error()).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, err)
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(updateFlashcardRequest, r, trackingId) {
+		return
+	}
+
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
 		return
 	}
 
+	current, ok := c.flashcardInCategory(userId, categoryId, flashcardId, r, trackingId)
+	if !ok {
+		return
+	}
 	flashcard := updateFlashcardRequest.ToFlashcard()
-	err = c.flashcardRepo.UpdateById(userId, flashcardId, flashcard)
+	if flashcard.CategoryId != current.CategoryId && c.isCategoryMissing(userId, strconv.Itoa(flashcard.CategoryId), r, trackingId) {
+		return
+	}
+
+	err = c.flashcardRepo.UpdateById(userId, flashcardId, flashcard, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeFlashcardPreconditionFailed(w, r, userId, flashcardId, trackingId)
+		return
+	}
 	if err != nil {
 		log.Error().
 			Str("trackingId", trackingId).
@@ -378,19 +631,555 @@ func (c *CategoryController) UpdateFlashcard(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	go func() {
-		flashcardIdInt, err := strconv.ParseInt(flashcardId, 10, 64)
-		if err != nil {
-			log.Info().Msg("Failed to parse flashcard ID: " + err.Error())
+	// The card may have moved to another category, drop both lists.
+	c.CategoryService.InvalidateFlashcards(userId, strconv.Itoa(current.CategoryId), strconv.Itoa(flashcard.CategoryId))
+	updated, err := c.primary.flashcard.FindOneById(userId, flashcardId)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get updated flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardUpdated, userId, strconv.Itoa(updated.CategoryId), flashcardId, updated))
+
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(updated.Version))
+	utils.WriteJSON(w, r, http.StatusOK, updated)
+}
+
+// PatchFlashcard applies a JSON Merge Patch (RFC 7396) to a flashcard: only
+// the fields present in the body change. Like UpdateFlashcard it records a
+// revision of the fields that changed.
+func (c *CategoryController) PatchFlashcard(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+	flashcardId := vars["flashcard_id"]
+	if c.isFlashcardAndCategoryInvalid(categoryId, flashcardId, r, trackingId) {
+		return
+	}
+
+	var patch objects.PatchFlashcard
+	appErr, err := utils.DecodeJSON(w, r, &patch)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse patch flashcard request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(patch, r, trackingId) {
+		return
+	}
+
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
+		return
+	}
+
+	current, ok := c.flashcardInCategory(userId, categoryId, flashcardId, r, trackingId)
+	if !ok {
+		return
+	}
+	moved := patch.CategoryId.Set && patch.CategoryId.Value != current.CategoryId
+	if moved && c.isCategoryMissing(userId, strconv.Itoa(patch.CategoryId.Value), r, trackingId) {
+		return
+	}
+
+	generation := c.CategoryService.CacheGeneration(userId)
+	flashcard, err := c.flashcardRepo.PatchById(userId, flashcardId, patch, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeFlashcardPreconditionFailed(w, r, userId, flashcardId, trackingId)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when patch flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	if !patch.IsEmpty() {
+		if moved {
+			// The card moved, drop both lists.
+			c.CategoryService.InvalidateFlashcards(userId, strconv.Itoa(current.CategoryId), strconv.Itoa(flashcard.CategoryId))
+		} else {
+			c.CategoryService.UpdateCachedFlashcard(userId, generation, flashcard)
+		}
+		c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardUpdated, userId, strconv.Itoa(flashcard.CategoryId), flashcardId, flashcard))
+	}
+
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(flashcard.Version))
+	utils.WriteJSON(w, r, http.StatusOK, flashcard)
+}
+
+// MoveFlashcard moves a flashcard to another category of the user. The card
+// keeps its id and revisions; the move is recorded as a revision too.
+func (c *CategoryController) MoveFlashcard(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+	flashcardId := vars["flashcard_id"]
+	if c.isFlashcardAndCategoryInvalid(categoryId, flashcardId, r, trackingId) {
+		return
+	}
+
+	var target objects.FlashcardTarget
+	appErr, err := utils.DecodeJSON(w, r, &target)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse move flashcard request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(target, r, trackingId) {
+		return
+	}
+
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
+		return
+	}
+	current, ok := c.flashcardInCategory(userId, categoryId, flashcardId, r, trackingId)
+	if !ok {
+		return
+	}
+	targetId := strconv.Itoa(target.CategoryId)
+	if c.isCategoryMissing(userId, targetId, r, trackingId) {
+		return
+	}
+
+	flashcard, err := c.flashcardRepo.PatchById(userId, flashcardId, objects.PatchFlashcard{CategoryId: objects.Some(target.CategoryId)}, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeFlashcardPreconditionFailed(w, r, userId, flashcardId, trackingId)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when move flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	c.CategoryService.InvalidateFlashcards(userId, strconv.Itoa(current.CategoryId), targetId)
+	c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardUpdated, userId, targetId, flashcardId, flashcard))
+
+	w.Header().Set(constant.LocationHeader, flashcardLocation(r, categoryId, flashcardId, flashcard))
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(flashcard.Version))
+	utils.WriteJSON(w, r, http.StatusOK, flashcard)
+}
+
+// CopyFlashcard creates a new flashcard with the name and content of another
+// one in a category of the user, which may be the category of the original.
+func (c *CategoryController) CopyFlashcard(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+	flashcardId := vars["flashcard_id"]
+	if c.isFlashcardAndCategoryInvalid(categoryId, flashcardId, r, trackingId) {
+		return
+	}
+
+	var target objects.FlashcardTarget
+	appErr, err := utils.DecodeJSON(w, r, &target)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse copy flashcard request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(target, r, trackingId) {
+		return
+	}
+
+	original, ok := c.flashcardInCategory(userId, categoryId, flashcardId, r, trackingId)
+	if !ok {
+		return
+	}
+	targetId := strconv.Itoa(target.CategoryId)
+	if c.isCategoryMissing(userId, targetId, r, trackingId) {
+		return
+	}
+
+	id, err := c.flashcardRepo.Insert(userId, targetId, objects.CreateFlashcard{
+		Name:    original.Name,
+		Content: original.Content,
+	})
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when copy flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	c.CategoryService.InvalidateFlashcards(userId, targetId)
+	flashcard, err := c.primary.flashcard.FindOneById(userId, strconv.FormatInt(id, 10))
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get copied flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardCreated, userId, targetId, strconv.FormatInt(id, 10), flashcard))
+
+	w.Header().Set(constant.LocationHeader, flashcardLocation(r, categoryId, flashcardId, flashcard))
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(flashcard.Version))
+	utils.WriteJSON(w, r, http.StatusCreated, flashcard)
+}
+
+func (c *CategoryController) GetFlashcardRevisions(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+	flashcardId := vars["flashcard_id"]
+	if c.isFlashcardAndCategoryInvalid(categoryId, flashcardId, r, trackingId) {
+		return
+	}
+
+	if _, ok := c.flashcardInCategory(userId, categoryId, flashcardId, r, trackingId); !ok {
+		return
+	}
+
+	revisions, err := c.readFrom(userId).revision.FindByFlashcardId(userId, flashcardId)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get flashcard revisions: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, revisions)
+}
+
+// RestoreFlashcardRevision puts the flashcard back to the state it had before
+// the given revision. The restore is itself an update: it needs If-Match like
+// PUT, and is recorded as a new revision that can be undone the same way.
+func (c *CategoryController) RestoreFlashcardRevision(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	categoryId := vars["category_id"]
+	flashcardId := vars["flashcard_id"]
+	if c.isFlashcardAndCategoryInvalid(categoryId, flashcardId, r, trackingId) {
+		return
+	}
+
+	version, ok := c.ifMatchVersion(r, trackingId)
+	if !ok {
+		return
+	}
+
+	current, ok := c.flashcardInCategory(userId, categoryId, flashcardId, r, trackingId)
+	if !ok {
+		return
+	}
+
+	revision, err := c.primary.revision.FindOne(userId, flashcardId, vars["revision"])
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrRevisionNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get flashcard revision: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	// The category the card was in back then may have been deleted since.
+	if revision.CategoryId != current.CategoryId && c.isCategoryMissing(userId, strconv.Itoa(revision.CategoryId), r, trackingId) {
+		return
+	}
+
+	err = c.flashcardRepo.UpdateById(userId, flashcardId, model.Flashcard{
+		Name:       revision.Name,
+		Content:    revision.Content,
+		CategoryId: revision.CategoryId,
+	}, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionMismatch) {
+		c.writeFlashcardPreconditionFailed(w, r, userId, flashcardId, trackingId)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when restore flashcard revision: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	flashcard, err := c.primary.flashcard.FindOneById(userId, flashcardId)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get restored flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	c.CategoryService.InvalidateFlashcards(userId, categoryId, strconv.Itoa(flashcard.CategoryId))
+	c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardUpdated, userId, strconv.Itoa(flashcard.CategoryId), flashcardId, flashcard))
+
+	w.Header().Set(constant.ETagHeader, utils.VersionETag(flashcard.Version))
+	utils.WriteJSON(w, r, http.StatusOK, flashcard)
+}
+
+// flashcardInCategory returns the flashcard of the user with the given id if
+// it is in categoryId, and answers FLASHCARD_NOT_FOUND otherwise.
+func (c *CategoryController) flashcardInCategory(userId string, categoryId string, flashcardId string, r *http.Request, trackingId string) (model.Flashcard, bool) {
+	flashcard, err := c.primary.flashcard.FindOneById(userId, flashcardId)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return model.Flashcard{}, false
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return model.Flashcard{}, false
+	}
+	if strconv.Itoa(flashcard.CategoryId) != categoryId {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, errors.New("flashcard "+flashcardId+" is not in category "+categoryId))
+		return model.Flashcard{}, false
+	}
+	return flashcard, true
+}
+
+// isCategoryMissing answers CATEGORY_NOT_FOUND unless categoryId is a
+// category of the user.
+func (c *CategoryController) isCategoryMissing(userId string, categoryId string, r *http.Request, trackingId string) bool {
+	_, err := c.primary.category.FindOneById(userId, categoryId)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrCategoryNotFound, err)
+		return true
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get category: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return true
+	}
+	return false
+}
+
+// flashcardLocation returns the URL of flashcard, given a request to a route
+// below /{category_id}/flashcards/{flashcard_id}.
+func flashcardLocation(r *http.Request, categoryId string, flashcardId string, flashcard model.Flashcard) string {
+	base, _, _ := strings.Cut(r.URL.Path, "/"+categoryId+"/flashcards/"+flashcardId+"/")
+	return base + "/" + strconv.Itoa(flashcard.CategoryId) + "/flashcards/" + strconv.FormatInt(flashcard.ID, 10)
+}
+
+// ifMatchVersion reads the version a PUT or DELETE is conditioned on. It
+// records the request error and returns false when the header is missing or
+// malformed.
+func (c *CategoryController) ifMatchVersion(r *http.Request, trackingId string) (int64, bool) {
+	ifMatch := r.Header.Get(constant.IfMatchHeader)
+	if len(ifMatch) == 0 {
+		msg := "If-Match header is required"
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrPreconditionRequired.WithDetails(utils.RequiredField(constant.IfMatchHeader)), errors.New(msg))
+		return 0, false
+	}
+	version, err := utils.ParseIfMatchVersion(ifMatch)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.InvalidField(constant.IfMatchHeader)), err)
+		return 0, false
+	}
+	return version, true
+}
+
+func (c *CategoryController) writeCategoryPreconditionFailed(w http.ResponseWriter, r *http.Request, userId string, id string, trackingId string) {
+	current, err := c.primary.category.FindOneById(userId, id)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get current category: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	writePreconditionFailed(w, r, utils.VersionETag(current.Version), current)
+}
+
+func (c *CategoryController) writeFlashcardPreconditionFailed(w http.ResponseWriter, r *http.Request, userId string, id string, trackingId string) {
+	current, err := c.primary.flashcard.FindOneById(userId, id)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get current flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	writePreconditionFailed(w, r, utils.VersionETag(current.Version), current)
+}
+
+// writePreconditionFailed answers a stale If-Match with the current version,
+// so the client can retry on top of it.
+func writePreconditionFailed(w http.ResponseWriter, r *http.Request, etag string, current any) {
+	w.Header().Set(constant.ETagHeader, etag)
+	utils.WriteError(w, r, utils.ErrVersionMismatch, current)
+}
+
+// BulkFlashcards deletes or moves many flashcards of the user in one
+// transaction and reports the outcome for each id.
+func (c *CategoryController) BulkFlashcards(w http.ResponseWriter, r *http.Request) {
+	userId := r.Header.Get(constant.UserIdHeader)
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	if c.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	var bulkRequest objects.BulkFlashcards
+	appErr, err := utils.DecodeJSON(w, r, &bulkRequest)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse bulk flashcards request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if c.isRequestInvalid(bulkRequest, r, trackingId) || c.isBatchInvalid("ids", len(bulkRequest.Ids), r, trackingId) || isBulkOpInvalid(bulkRequest, r, trackingId) {
+		return
+	}
+
+	if bulkRequest.Op == objects.BulkOpMove {
+		_, err = c.primary.category.FindOneById(userId, strconv.Itoa(bulkRequest.CategoryId))
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.SetHttpReponseError(r, utils.ErrCategoryNotFound, err)
 			return
 		}
-		flashcard.ID = flashcardIdInt
-		err = c.CategoryService.SaveFlashcardToRedisHash(userId, categoryId, flashcard)
 		if err != nil {
-			log.Info().Msg("Failed to update Redis cache after database update: " + err.Error())
+			log.Error().
+				Str("trackingId", trackingId).
+				Str("error", "error when get target category of bulk move: "+err.Error()).
+				Msg("")
+			utils.SetHttpReponseError(r, utils.ErrServerError, err)
+			return
 		}
+	}
 
-	}()
+	results, err := c.flashcardRepo.ApplyBulk(userId, bulkRequest)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when apply bulk flashcards "+bulkRequest.Op+": "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
+	categoryIds := make([]string, 0)
+	seen := make(map[int]bool)
+	for _, result := range results {
+		if result.Status != objects.BulkStatusApplied {
+			continue
+		}
+		if !seen[result.PreviousCategoryId] {
+			seen[result.PreviousCategoryId] = true
+			categoryIds = append(categoryIds, strconv.Itoa(result.PreviousCategoryId))
+		}
+		id := strconv.FormatInt(result.Id, 10)
+		if result.Flashcard != nil {
+			c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardUpdated, userId, strconv.Itoa(result.Flashcard.CategoryId), id, *result.Flashcard))
+		} else {
+			c.CategoryService.PublishEvent(events.NewEvent(events.FlashcardDeleted, userId, strconv.Itoa(result.PreviousCategoryId), id, nil))
+		}
+	}
+	if len(categoryIds) > 0 {
+		if bulkRequest.Op == objects.BulkOpMove {
+			categoryIds = append(categoryIds, strconv.Itoa(bulkRequest.CategoryId))
+		}
+		c.CategoryService.InvalidateFlashcards(userId, categoryIds...)
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, results)
+}
+
+// isBulkOpInvalid rejects an unknown operation and a move without a target
+// category.
+func isBulkOpInvalid(bulkRequest objects.BulkFlashcards, r *http.Request, trackingId string) bool {
+	var detail objects.FieldError
+	switch {
+	case bulkRequest.Op != objects.BulkOpDelete && bulkRequest.Op != objects.BulkOpMove:
+		detail = utils.InvalidField("op")
+	case bulkRequest.Op == objects.BulkOpMove && bulkRequest.CategoryId <= 0:
+		detail = utils.RequiredField("categoryId")
+	default:
+		return false
+	}
+	msg := "bulk flashcards request invalid: op " + bulkRequest.Op
+	log.Error().
+		Str("trackingId", trackingId).
+		Str("error", msg).
+		Msg("")
+	utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(detail), errors.New(msg))
+	return true
 }
@@ -0,0 +1,791 @@
+package category_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/worker"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/repositories_impl"
+	"flashcard_service/pkg/database/sqlite"
+	"flashcard_service/pkg/drivers"
+	"flashcard_service/pkg/objects"
+	"flashcard_service/pkg/utils"
+	"flashcard_service/pkg/validation"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	t       *testing.T
+	handler http.Handler
+	db      database.Database
+	store   cache.Store
+	service *category.CategoryService
+}
+
+// newTestServer serves the API on an in-memory SQLite database and an
+// in-memory cache store.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db := sqlite.NewSQLiteWithPath(":memory:")
+	err := db.Connect()
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	store := cache.NewMemoryStore()
+	queue := worker.NewQueue(2, 100, 3, time.Millisecond)
+	t.Cleanup(func() {
+		queue.Close()
+		db.Close()
+	})
+	service := category.NewCategoryService(store, queue)
+	return &testServer{
+		t:       t,
+		handler: drivers.NewRouter(db, store, service),
+		db:      db,
+		store:   store,
+		service: service,
+	}
+}
+
+func (s *testServer) do(method string, path string, userId string, body any, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.request(method, "/api/v1/category"+path, userId, body, headers...)
+}
+
+func (s *testServer) request(method string, url string, userId string, body any, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+	req := httptest.NewRequest(method, url, &reader)
+	if len(userId) > 0 {
+		req.Header.Set(constant.UserIdHeader, userId)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// envelope decodes the ApiResponse every endpoint writes, checking that its
+// code matches the HTTP status.
+func envelope(t *testing.T, rec *httptest.ResponseRecorder) objects.ApiResponse {
+	t.Helper()
+	var response objects.ApiResponse
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	if response.Code != rec.Code {
+		t.Fatalf("envelope code %d, HTTP status %d", response.Code, rec.Code)
+	}
+	return response
+}
+
+// decode returns the data of the envelope as T.
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var response struct {
+		Data T `json:"data"`
+	}
+	envelope(t, rec)
+	err := json.Unmarshal(rec.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return response.Data
+}
+
+func expect(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, want int, errorCode string) objects.ApiResponse {
+	t.Helper()
+	expect(t, rec, want)
+	response := envelope(t, rec)
+	if response.ErrorCode != errorCode {
+		t.Fatalf("errorCode = %q, want %q", response.ErrorCode, errorCode)
+	}
+	return response
+}
+
+func (s *testServer) createCategory(userId string, name string) string {
+	s.t.Helper()
+	expect(s.t, s.do(http.MethodPost, "", userId, map[string]string{"name": name}), http.StatusCreated)
+	categories := decode[[]model.Category](s.t, s.do(http.MethodGet, "", userId, nil))
+	for _, c := range categories {
+		if c.Name == name {
+			return strconv.FormatInt(c.Id, 10)
+		}
+	}
+	s.t.Fatalf("created category %q is not listed", name)
+	return ""
+}
+
+func (s *testServer) createFlashcard(userId string, categoryId string, name string, content string) string {
+	s.t.Helper()
+	body := []map[string]string{{"name": name, "content": content}}
+	expect(s.t, s.do(http.MethodPost, "/"+categoryId+"/flashcards", userId, body), http.StatusCreated)
+	flashcards := decode[[]model.Flashcard](s.t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", userId, nil))
+	for _, f := range flashcards {
+		if f.Name == name {
+			return strconv.FormatInt(f.ID, 10)
+		}
+	}
+	s.t.Fatalf("created flashcard %q is not listed", name)
+	return ""
+}
+
+func TestCategoryRoutes(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createCategory("1", "verbs")
+
+	rec := s.do(http.MethodGet, "/"+id, "1", nil)
+	expect(t, rec, http.StatusOK)
+	if rec.Header().Get(constant.ETagHeader) != utils.VersionETag(1) {
+		t.Fatalf("ETag = %q", rec.Header().Get(constant.ETagHeader))
+	}
+	if c := decode[model.Category](t, rec); c.Name != "verbs" {
+		t.Fatalf("category = %+v", c)
+	}
+
+	rec = s.do(http.MethodPut, "/"+id, "1", map[string]string{"name": "nouns"}, constant.IfMatchHeader, utils.VersionETag(1))
+	expect(t, rec, http.StatusOK)
+	if c := decode[model.Category](t, rec); c.Name != "nouns" || c.Version != 2 || rec.Header().Get(constant.ETagHeader) != utils.VersionETag(2) {
+		t.Fatalf("updated category = %+v, ETag %q", c, rec.Header().Get(constant.ETagHeader))
+	}
+	if c := decode[model.Category](t, s.do(http.MethodGet, "/"+id, "1", nil)); c.Name != "nouns" || c.Version != 2 {
+		t.Fatalf("category after update = %+v", c)
+	}
+
+	expect(t, s.do(http.MethodDelete, "/"+id, "1", nil, constant.IfMatchHeader, utils.VersionETag(2)), http.StatusOK)
+	expect(t, s.do(http.MethodGet, "/"+id, "1", nil), http.StatusNotFound)
+	if categories := decode[[]model.Category](t, s.do(http.MethodGet, "", "1", nil)); len(categories) != 0 {
+		t.Fatalf("deleted category is listed: %+v", categories)
+	}
+}
+
+func TestCreateReturnsCreatedResources(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodPost, "", "1", map[string]string{"name": "verbs"})
+	expect(t, rec, http.StatusCreated)
+	category := decode[model.Category](t, rec)
+	if category.Id <= 0 || category.Name != "verbs" || category.Version != 1 || category.CreatedAt == nil {
+		t.Fatalf("created category = %+v", category)
+	}
+	categoryId := strconv.FormatInt(category.Id, 10)
+	if location := rec.Header().Get(constant.LocationHeader); location != "/api/v1/category/"+categoryId {
+		t.Fatalf("Location = %q", location)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	published, closeEvents, err := events.NewPublisher(s.store).Subscribe(ctx, "1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer closeEvents()
+
+	rec = s.do(http.MethodPost, "/"+categoryId+"/flashcards", "1", []map[string]string{{"name": "go", "content": "went"}, {"name": "be"}})
+	expect(t, rec, http.StatusCreated)
+	flashcards := decode[[]model.Flashcard](t, rec)
+	if len(flashcards) != 2 || flashcards[0].Name != "go" || flashcards[1].Name != "be" || flashcards[0].ID <= 0 || flashcards[1].ID <= flashcards[0].ID || flashcards[0].CreatedAt == nil {
+		t.Fatalf("created flashcards = %+v", flashcards)
+	}
+	// Every created card is announced on its own, with its id.
+	announced := make(map[string]string)
+	for len(announced) < len(flashcards) {
+		select {
+		case payload := <-published:
+			var event struct {
+				Type        events.EventType `json:"type"`
+				FlashcardId string           `json:"flashcardId"`
+				Data        model.Flashcard  `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				t.Fatalf("event = %s", payload)
+			}
+			if event.Type == events.FlashcardCreated {
+				announced[event.FlashcardId] = event.Data.Name
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("announced %+v, want one event per card", announced)
+		}
+	}
+	for _, flashcard := range flashcards {
+		if announced[strconv.FormatInt(flashcard.ID, 10)] != flashcard.Name {
+			t.Fatalf("announced %+v for %+v", announced, flashcards)
+		}
+	}
+	if location := rec.Header().Get(constant.LocationHeader); location != "/api/v1/category/"+categoryId+"/flashcards" {
+		t.Fatalf("Location = %q", location)
+	}
+
+	rec = s.do(http.MethodPost, "/"+categoryId+"/flashcards", "1", []map[string]string{{"name": "see"}})
+	flashcards = decode[[]model.Flashcard](t, rec)
+	if location := rec.Header().Get(constant.LocationHeader); len(flashcards) != 1 || location != "/api/v1/category/"+categoryId+"/flashcards/"+strconv.FormatInt(flashcards[0].ID, 10) {
+		t.Fatalf("Location = %q for %+v", location, flashcards)
+	}
+	expect(t, s.do(http.MethodGet, strings.TrimPrefix(rec.Header().Get(constant.LocationHeader), "/api/v1/category"), "1", nil), http.StatusOK)
+}
+
+func TestCategoryErrors(t *testing.T) {
+	s := newTestServer(t)
+	id := s.createCategory("1", "verbs")
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		userId    string
+		body      any
+		headers   []string
+		want      int
+		errorCode string
+	}{
+		{"create without user", http.MethodPost, "", "", map[string]string{"name": "x"}, nil, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"create with malformed body", http.MethodPost, "", "1", "not an object", nil, http.StatusBadRequest, "MALFORMED_BODY"},
+		{"list without user", http.MethodGet, "", "", nil, nil, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"get missing", http.MethodGet, "/999", "1", nil, nil, http.StatusNotFound, "CATEGORY_NOT_FOUND"},
+		{"update without If-Match", http.MethodPut, "/" + id, "1", map[string]string{"name": "x"}, nil, http.StatusPreconditionRequired, "PRECONDITION_REQUIRED"},
+		{"update with malformed If-Match", http.MethodPut, "/" + id, "1", map[string]string{"name": "x"}, []string{constant.IfMatchHeader, "abc"}, http.StatusBadRequest, "VALIDATION_FAILED"},
+		{"update with stale version", http.MethodPut, "/" + id, "1", map[string]string{"name": "x"}, []string{constant.IfMatchHeader, utils.VersionETag(5)}, http.StatusPreconditionFailed, "VERSION_MISMATCH"},
+		{"update missing", http.MethodPut, "/999", "1", map[string]string{"name": "x"}, []string{constant.IfMatchHeader, utils.VersionETag(1)}, http.StatusNotFound, "CATEGORY_NOT_FOUND"},
+		{"delete without If-Match", http.MethodDelete, "/" + id, "1", nil, nil, http.StatusPreconditionRequired, "PRECONDITION_REQUIRED"},
+		{"delete with stale version", http.MethodDelete, "/" + id, "1", nil, []string{constant.IfMatchHeader, utils.VersionETag(5)}, http.StatusPreconditionFailed, "VERSION_MISMATCH"},
+		{"delete missing", http.MethodDelete, "/999", "1", nil, []string{constant.IfMatchHeader, utils.VersionETag(1)}, http.StatusNotFound, "CATEGORY_NOT_FOUND"},
+		{"unknown route", http.MethodGet, "/" + id + "/unknown", "1", nil, nil, http.StatusNotFound, "ROUTE_NOT_FOUND"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectError(t, s.do(test.method, test.path, test.userId, test.body, test.headers...), test.want, test.errorCode)
+		})
+	}
+
+	response := expectError(t, s.do(http.MethodGet, "", "", nil), http.StatusBadRequest, "VALIDATION_FAILED")
+	if len(response.Details) != 1 || response.Details[0].Field != constant.UserIdHeader || response.Details[0].Code != validation.Required {
+		t.Fatalf("details = %+v", response.Details)
+	}
+
+	// A failed precondition returns the current version so the client can
+	// retry on top of it.
+	rec := s.do(http.MethodPut, "/"+id, "1", map[string]string{"name": "x"}, constant.IfMatchHeader, utils.VersionETag(5))
+	if rec.Header().Get(constant.ETagHeader) != utils.VersionETag(1) {
+		t.Fatalf("ETag of 412 = %q", rec.Header().Get(constant.ETagHeader))
+	}
+	if current := decode[model.Category](t, rec); current.Version != 1 {
+		t.Fatalf("current version in 412 = %+v", current)
+	}
+}
+
+func TestFlashcardRoutes(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	otherCategoryId := s.createCategory("1", "irregular")
+	id := s.createFlashcard("1", categoryId, "go", "goed")
+	path := "/" + categoryId + "/flashcards/" + id
+
+	rec := s.do(http.MethodGet, path, "1", nil)
+	expect(t, rec, http.StatusOK)
+	if f := decode[model.Flashcard](t, rec); f.Content != "goed" || f.Version != 1 {
+		t.Fatalf("flashcard = %+v", f)
+	}
+	expect(t, s.do(http.MethodGet, "/"+otherCategoryId+"/flashcards/"+id, "1", nil), http.StatusNotFound)
+
+	update := map[string]any{"name": "go", "content": "went", "categoryId": mustAtoi(t, categoryId)}
+	rec = s.do(http.MethodPut, path, "1", update, constant.IfMatchHeader, utils.VersionETag(1))
+	expect(t, rec, http.StatusOK)
+	if f := decode[model.Flashcard](t, rec); f.Content != "went" || f.Version != 2 || rec.Header().Get(constant.ETagHeader) != utils.VersionETag(2) {
+		t.Fatalf("updated flashcard = %+v, ETag %q", f, rec.Header().Get(constant.ETagHeader))
+	}
+	expect(t, s.do(http.MethodPut, path, "1", update, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusPreconditionFailed)
+
+	revisions := decode[[]model.FlashcardRevision](t, s.do(http.MethodGet, path+"/revisions", "1", nil))
+	if len(revisions) != 1 || revisions[0].Content != "goed" {
+		t.Fatalf("revisions = %+v", revisions)
+	}
+	expect(t, s.do(http.MethodGet, "/"+otherCategoryId+"/flashcards/"+id+"/revisions", "1", nil), http.StatusNotFound)
+	restore := path + "/revisions/" + strconv.Itoa(revisions[0].Revision) + "/restore"
+	expectError(t, s.do(http.MethodPost, restore, "1", nil), http.StatusPreconditionRequired, utils.ErrPreconditionRequired.ErrorCode)
+	expect(t, s.do(http.MethodPost, restore, "1", nil, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusPreconditionFailed)
+	expect(t, s.do(http.MethodPost, "/"+otherCategoryId+"/flashcards/"+id+"/revisions/"+strconv.Itoa(revisions[0].Revision)+"/restore", "1", nil, constant.IfMatchHeader, utils.VersionETag(2)), http.StatusNotFound)
+	rec = s.do(http.MethodPost, restore, "1", nil, constant.IfMatchHeader, utils.VersionETag(2))
+	expect(t, rec, http.StatusOK)
+	if f := decode[model.Flashcard](t, rec); f.Content != "goed" || rec.Header().Get(constant.ETagHeader) != utils.VersionETag(3) {
+		t.Fatalf("restored flashcard = %+v, ETag %q", f, rec.Header().Get(constant.ETagHeader))
+	}
+	expect(t, s.do(http.MethodPost, path+"/revisions/99/restore", "1", nil, constant.IfMatchHeader, utils.VersionETag(3)), http.StatusNotFound)
+
+	// Moving the card drops it from the cached list of its old category.
+	move := map[string]any{"name": "go", "content": "goed", "categoryId": mustAtoi(t, otherCategoryId)}
+	expect(t, s.do(http.MethodPut, path, "1", move, constant.IfMatchHeader, utils.VersionETag(3)), http.StatusOK)
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil)); len(flashcards) != 0 {
+		t.Fatalf("moved card still listed in its old category: %+v", flashcards)
+	}
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+otherCategoryId+"/flashcards", "1", nil)); len(flashcards) != 1 {
+		t.Fatalf("moved card not listed in its new category: %+v", flashcards)
+	}
+
+	path = "/" + otherCategoryId + "/flashcards/" + id
+	expect(t, s.do(http.MethodDelete, path, "1", nil), http.StatusPreconditionRequired)
+	expect(t, s.do(http.MethodDelete, path, "1", nil, constant.IfMatchHeader, utils.VersionETag(4)), http.StatusOK)
+	expect(t, s.do(http.MethodGet, path, "1", nil), http.StatusNotFound)
+	expect(t, s.do(http.MethodDelete, path, "1", nil, constant.IfMatchHeader, utils.VersionETag(4)), http.StatusNotFound)
+}
+
+func TestFlashcardErrors(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+
+	expect(t, s.do(http.MethodPost, "/"+categoryId+"/flashcards", "1", map[string]string{"name": "not a list"}), http.StatusBadRequest)
+	expect(t, s.do(http.MethodPost, "/"+categoryId+"/flashcards", "", []map[string]string{{"name": "go"}}), http.StatusBadRequest)
+	expectError(t, s.do(http.MethodPost, "/999/flashcards", "1", []map[string]string{{"name": "go"}}), http.StatusNotFound, utils.ErrCategoryNotFound.ErrorCode)
+	expect(t, s.do(http.MethodGet, "/"+categoryId+"/flashcards/999", "1", nil), http.StatusNotFound)
+	expect(t, s.do(http.MethodPut, "/"+categoryId+"/flashcards/999", "1", map[string]any{"name": "x", "categoryId": mustAtoi(t, categoryId)}, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusNotFound)
+	expect(t, s.do(http.MethodPut, "/"+categoryId+"/flashcards/999", "1", "not an object", constant.IfMatchHeader, utils.VersionETag(1)), http.StatusBadRequest)
+}
+
+func TestDeleteThroughAnotherCategoryIsNotFound(t *testing.T) {
+	s := newTestServer(t)
+	verbs := s.createCategory("1", "verbs")
+	nouns := s.createCategory("1", "nouns")
+	id := s.createFlashcard("1", verbs, "go", "went")
+
+	// The deck is cached now; a delete through the wrong URL must neither
+	// remove the card nor leave that cached deck behind.
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+verbs+"/flashcards", "1", nil)); len(flashcards) != 1 {
+		t.Fatalf("verbs lists %+v", flashcards)
+	}
+	expectError(t, s.do(http.MethodDelete, "/"+nouns+"/flashcards/"+id, "1", nil, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusNotFound, utils.ErrFlashcardNotFound.ErrorCode)
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+verbs+"/flashcards", "1", nil)); len(flashcards) != 1 {
+		t.Fatalf("verbs lists %+v after a delete through nouns", flashcards)
+	}
+
+	expect(t, s.do(http.MethodDelete, "/"+verbs+"/flashcards/"+id, "1", nil, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusOK)
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+verbs+"/flashcards", "1", nil)); len(flashcards) != 0 {
+		t.Fatalf("deleted card still listed: %+v", flashcards)
+	}
+}
+
+// changedFields lists the fields in the diff of a revision.
+func changedFields(revision model.FlashcardRevision) []string {
+	fields := make([]string, 0, len(revision.Diff))
+	for _, change := range revision.Diff {
+		fields = append(fields, change.Field)
+	}
+	return fields
+}
+
+func TestFlashcardRevisions(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	otherCategoryId := s.createCategory("1", "irregular")
+	id := s.createFlashcard("1", categoryId, "go", "goed")
+	path := "/" + categoryId + "/flashcards/" + id
+	otherPath := "/" + otherCategoryId + "/flashcards/" + id
+
+	update := map[string]any{"name": "go", "content": "went", "categoryId": mustAtoi(t, categoryId)}
+	expect(t, s.do(http.MethodPut, path, "1", update, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusOK)
+	expect(t, s.do(http.MethodPatch, path, "1", map[string]string{"name": "goes"}, constant.IfMatchHeader, utils.VersionETag(2)), http.StatusOK)
+	// An update that changes nothing is not a revision.
+	update["name"] = "goes"
+	expect(t, s.do(http.MethodPut, path, "1", update, constant.IfMatchHeader, utils.VersionETag(3)), http.StatusOK)
+	update["categoryId"] = mustAtoi(t, otherCategoryId)
+	expect(t, s.do(http.MethodPut, path, "1", update, constant.IfMatchHeader, utils.VersionETag(4)), http.StatusOK)
+
+	rec := s.do(http.MethodGet, otherPath+"/revisions", "1", nil)
+	expect(t, rec, http.StatusOK)
+	if strings.Contains(rec.Body.String(), `"author"`) {
+		t.Fatalf("revisions still carry an author: %s", rec.Body.String())
+	}
+	revisions := decode[[]model.FlashcardRevision](t, rec)
+	if len(revisions) != 3 {
+		t.Fatalf("revisions = %+v", revisions)
+	}
+	want := []struct {
+		revision   int
+		fields     []string
+		name       string
+		content    string
+		categoryId string
+	}{
+		{3, []string{"categoryId"}, "goes", "went", categoryId},
+		{2, []string{"name"}, "go", "went", categoryId},
+		{1, []string{"content"}, "go", "goed", categoryId},
+	}
+	for i, w := range want {
+		r := revisions[i]
+		if r.Revision != w.revision || !slices.Equal(changedFields(r), w.fields) || r.Name != w.name || r.Content != w.content || strconv.Itoa(r.CategoryId) != w.categoryId {
+			t.Fatalf("revisions[%d] = %+v, want %+v", i, r, w)
+		}
+	}
+	if change := revisions[2].Diff[0]; change.From != "goed" || change.To != "went" {
+		t.Fatalf("content change = %+v", change)
+	}
+	// A revision is stamped with the updated_at its update wrote to the card.
+	moved := decode[model.Flashcard](t, s.do(http.MethodGet, otherPath, "1", nil))
+	if revisions[0].CreatedAt == nil || moved.UpdatedAt == nil || !revisions[0].CreatedAt.Equal(*moved.UpdatedAt) {
+		t.Fatalf("revision created at %v, card updated at %v", revisions[0].CreatedAt, moved.UpdatedAt)
+	}
+
+	// Restoring the first revision undoes every later update at once, and is
+	// itself recorded as a revision.
+	rec = s.do(http.MethodPost, otherPath+"/revisions/1/restore", "1", nil, constant.IfMatchHeader, utils.VersionETag(5))
+	expect(t, rec, http.StatusOK)
+	if f := decode[model.Flashcard](t, rec); f.Name != "go" || f.Content != "goed" || strconv.Itoa(f.CategoryId) != categoryId || f.Version != 6 {
+		t.Fatalf("restored flashcard = %+v", f)
+	}
+	revisions = decode[[]model.FlashcardRevision](t, s.do(http.MethodGet, path+"/revisions", "1", nil))
+	if len(revisions) != 4 || revisions[0].Revision != 4 || !slices.Equal(changedFields(revisions[0]), []string{"name", "content", "categoryId"}) {
+		t.Fatalf("revisions after restore = %+v", revisions)
+	}
+
+	// The category a revision points back to may be gone.
+	expect(t, s.do(http.MethodDelete, "/"+otherCategoryId, "1", nil, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusOK)
+	rec = s.do(http.MethodPost, path+"/revisions/4/restore", "1", nil, constant.IfMatchHeader, utils.VersionETag(6))
+	expectError(t, rec, http.StatusNotFound, utils.ErrCategoryNotFound.ErrorCode)
+}
+
+func TestRequestsAreValidated(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	flashcardId := s.createFlashcard("1", categoryId, "go", "went")
+	flashcardPath := "/" + categoryId + "/flashcards/" + flashcardId
+	tooMany := make([]map[string]string, 101)
+	for i := range tooMany {
+		tooMany[i] = map[string]string{"name": "go"}
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    any
+		headers []string
+		field   string
+		code    string
+	}{
+		{"unknown category field", http.MethodPost, "", map[string]string{"name": "verbs", "colour": "red"}, nil, "colour", validation.Unknown},
+		{"empty category name", http.MethodPost, "", map[string]string{"name": ""}, nil, "name", validation.Required},
+		{"long category name", http.MethodPut, "/" + categoryId, map[string]string{"name": strings.Repeat("a", 101)}, []string{constant.IfMatchHeader, utils.VersionETag(1)}, "name", validation.TooLong},
+		{"empty batch", http.MethodPost, "/" + categoryId + "/flashcards", []map[string]string{}, nil, "flashcards", validation.Required},
+		{"oversize batch", http.MethodPost, "/" + categoryId + "/flashcards", tooMany, nil, "flashcards", validation.TooMany},
+		{"unnamed flashcard in batch", http.MethodPost, "/" + categoryId + "/flashcards", []map[string]string{{"name": "go"}, {"content": "went"}}, nil, "[1].name", validation.Required},
+		{"flashcard without category", http.MethodPut, flashcardPath, map[string]string{"name": "go"}, []string{constant.IfMatchHeader, utils.VersionETag(1)}, "categoryId", validation.Required},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := expectError(t, s.do(tt.method, tt.path, "1", tt.body, tt.headers...), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+			if len(body.Details) != 1 || body.Details[0].Field != tt.field || body.Details[0].Code != tt.code {
+				t.Fatalf("details = %+v, want %s %s", body.Details, tt.field, tt.code)
+			}
+		})
+	}
+
+	huge := map[string]string{"name": "verbs", "content": strings.Repeat("a", 2<<20)}
+	expectError(t, s.do(http.MethodPost, "", "1", huge), http.StatusRequestEntityTooLarge, utils.ErrRequestTooLarge.ErrorCode)
+}
+
+func TestPatchChangesOnlySentFields(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	flashcardId := s.createFlashcard("1", categoryId, "go", "went")
+	path := "/" + categoryId + "/flashcards/" + flashcardId
+
+	rec := s.do(http.MethodPatch, path, "1", map[string]string{"content": "gone"}, constant.IfMatchHeader, utils.VersionETag(1))
+	expect(t, rec, http.StatusOK)
+	if rec.Header().Get(constant.ETagHeader) != utils.VersionETag(2) {
+		t.Fatalf("ETag = %q", rec.Header().Get(constant.ETagHeader))
+	}
+	if f := decode[model.Flashcard](t, rec); f.Name != "go" || f.Content != "gone" || strconv.Itoa(f.CategoryId) != categoryId {
+		t.Fatalf("patched flashcard = %+v", f)
+	}
+
+	// null clears the content; name and category cannot be cleared.
+	rec = s.do(http.MethodPatch, path, "1", map[string]any{"content": nil}, constant.IfMatchHeader, utils.VersionETag(2))
+	if f := decode[model.Flashcard](t, rec); f.Name != "go" || f.Content != "" {
+		t.Fatalf("patched flashcard = %+v", f)
+	}
+	body := expectError(t, s.do(http.MethodPatch, path, "1", map[string]any{"name": nil}, constant.IfMatchHeader, utils.VersionETag(3)), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+	if len(body.Details) != 1 || body.Details[0].Field != "name" || body.Details[0].Code != validation.Required {
+		t.Fatalf("details = %+v", body.Details)
+	}
+	expect(t, s.do(http.MethodPatch, path, "1", map[string]string{"content": "x"}), http.StatusPreconditionRequired)
+	expect(t, s.do(http.MethodPatch, path, "1", map[string]string{"content": "x"}, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusPreconditionFailed)
+	expect(t, s.do(http.MethodPatch, "/"+categoryId+"/flashcards/999", "1", map[string]string{"content": "x"}, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusNotFound)
+
+	rec = s.do(http.MethodPatch, "/"+categoryId, "1", map[string]string{"name": "nouns"}, constant.IfMatchHeader, utils.VersionETag(1))
+	if c := decode[model.Category](t, rec); c.Name != "nouns" || c.Version != 2 {
+		t.Fatalf("patched category = %+v", c)
+	}
+}
+
+func TestPatchUpdatesCachedEntryInPlace(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	flashcardId := s.createFlashcard("1", categoryId, "go", "went")
+	s.createFlashcard("1", categoryId, "be", "was")
+
+	loads := s.service.CacheStats().DatabaseLoads
+	expect(t, s.do(http.MethodPatch, "/"+categoryId+"/flashcards/"+flashcardId, "1", map[string]string{"content": "gone"}, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusOK)
+	expect(t, s.do(http.MethodPatch, "/"+categoryId, "1", map[string]string{"name": "nouns"}, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusOK)
+
+	flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil))
+	if len(flashcards) != 2 || flashcards[0].Content != "gone" || flashcards[1].Content != "was" {
+		t.Fatalf("flashcards = %+v", flashcards)
+	}
+	categories := decode[[]model.Category](t, s.do(http.MethodGet, "", "1", nil))
+	if len(categories) != 1 || categories[0].Name != "nouns" {
+		t.Fatalf("categories = %+v", categories)
+	}
+	if got := s.service.CacheStats().DatabaseLoads; got != loads {
+		t.Fatalf("reads after a patch made %d loads, want 0", got-loads)
+	}
+}
+
+func TestMoveAndCopyFlashcards(t *testing.T) {
+	s := newTestServer(t)
+	verbs := s.createCategory("1", "verbs")
+	nouns := s.createCategory("1", "nouns")
+	foreign := s.createCategory("2", "theirs")
+	id := s.createFlashcard("1", verbs, "go", "went")
+	listed := func(categoryId string) int {
+		return len(decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil)))
+	}
+	target := func(categoryId string) map[string]int {
+		return map[string]int{"categoryId": mustAtoi(t, categoryId)}
+	}
+	ifMatch := func(version int64) []string {
+		return []string{constant.IfMatchHeader, utils.VersionETag(version)}
+	}
+
+	// Both decks are cached before the move.
+	if listed(verbs) != 1 || listed(nouns) != 0 {
+		t.Fatal("unexpected decks before the move")
+	}
+	expect(t, s.do(http.MethodPost, "/"+verbs+"/flashcards/"+id+"/move", "1", target(nouns)), http.StatusPreconditionRequired)
+	expectError(t, s.do(http.MethodPost, "/"+nouns+"/flashcards/"+id+"/move", "1", target(verbs), ifMatch(1)...), http.StatusNotFound, utils.ErrFlashcardNotFound.ErrorCode)
+	expectError(t, s.do(http.MethodPost, "/"+verbs+"/flashcards/"+id+"/move", "1", target(foreign), ifMatch(1)...), http.StatusNotFound, utils.ErrCategoryNotFound.ErrorCode)
+
+	rec := s.do(http.MethodPost, "/"+verbs+"/flashcards/"+id+"/move", "1", target(nouns), ifMatch(1)...)
+	expect(t, rec, http.StatusOK)
+	if f := decode[model.Flashcard](t, rec); strconv.Itoa(f.CategoryId) != nouns || f.Version != 2 {
+		t.Fatalf("moved flashcard = %+v", f)
+	}
+	if location := rec.Header().Get(constant.LocationHeader); location != "/api/v1/category/"+nouns+"/flashcards/"+id {
+		t.Fatalf("Location = %q", location)
+	}
+	if listed(verbs) != 0 || listed(nouns) != 1 {
+		t.Fatal("the move did not update both decks")
+	}
+
+	rec = s.do(http.MethodPost, "/"+nouns+"/flashcards/"+id+"/copy", "1", target(verbs))
+	expect(t, rec, http.StatusCreated)
+	copied := decode[model.Flashcard](t, rec)
+	if strconv.FormatInt(copied.ID, 10) == id || copied.Name != "go" || copied.Content != "went" || strconv.Itoa(copied.CategoryId) != verbs {
+		t.Fatalf("copied flashcard = %+v", copied)
+	}
+	if location := rec.Header().Get(constant.LocationHeader); location != "/api/v1/category/"+verbs+"/flashcards/"+strconv.FormatInt(copied.ID, 10) {
+		t.Fatalf("Location = %q", location)
+	}
+	if listed(verbs) != 1 || listed(nouns) != 1 {
+		t.Fatal("the copy did not update the target deck")
+	}
+	expectError(t, s.do(http.MethodPost, "/"+nouns+"/flashcards/"+id+"/copy", "2", target(foreign)), http.StatusNotFound, utils.ErrFlashcardNotFound.ErrorCode)
+
+	// A full update checks the target category too.
+	update := map[string]any{"name": "go", "content": "went", "categoryId": mustAtoi(t, foreign)}
+	expectError(t, s.do(http.MethodPut, "/"+nouns+"/flashcards/"+id, "1", update, ifMatch(2)...), http.StatusNotFound, utils.ErrCategoryNotFound.ErrorCode)
+}
+
+func TestBulkFlashcards(t *testing.T) {
+	s := newTestServer(t)
+	verbs := s.createCategory("1", "verbs")
+	nouns := s.createCategory("1", "nouns")
+	goId := mustAtoi(t, s.createFlashcard("1", verbs, "go", "went"))
+	beId := mustAtoi(t, s.createFlashcard("1", verbs, "be", "was"))
+	dogId := mustAtoi(t, s.createFlashcard("1", nouns, "dog", "dogs"))
+	bulk := func(body any) *httptest.ResponseRecorder {
+		return s.request(http.MethodPost, "/api/v1/flashcards/bulk", "1", body)
+	}
+
+	rec := bulk(map[string]any{"op": "move", "ids": []int{goId, dogId, 999}, "categoryId": mustAtoi(t, nouns)})
+	expect(t, rec, http.StatusOK)
+	results := decode[[]objects.BulkFlashcardResult](t, rec)
+	statuses := make([]string, 0, len(results))
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+	}
+	if strings.Join(statuses, ",") != "applied,unchanged,not_found" {
+		t.Fatalf("results = %+v", results)
+	}
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+verbs+"/flashcards", "1", nil)); len(flashcards) != 1 {
+		t.Fatalf("verbs still lists %+v", flashcards)
+	}
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+nouns+"/flashcards", "1", nil)); len(flashcards) != 2 {
+		t.Fatalf("nouns lists %+v", flashcards)
+	}
+
+	rec = bulk(map[string]any{"op": "delete", "ids": []int{beId, goId}})
+	if results := decode[[]objects.BulkFlashcardResult](t, rec); len(results) != 2 || results[0].Status != objects.BulkStatusApplied || results[1].Status != objects.BulkStatusApplied {
+		t.Fatalf("results = %+v", results)
+	}
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+verbs+"/flashcards", "1", nil)); len(flashcards) != 0 {
+		t.Fatalf("verbs still lists %+v", flashcards)
+	}
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+nouns+"/flashcards", "1", nil)); len(flashcards) != 1 {
+		t.Fatalf("nouns lists %+v", flashcards)
+	}
+
+	// Another user's cards are reported as missing.
+	rec = s.request(http.MethodPost, "/api/v1/flashcards/bulk", "2", map[string]any{"op": "delete", "ids": []int{dogId}})
+	if results := decode[[]objects.BulkFlashcardResult](t, rec); len(results) != 1 || results[0].Status != objects.BulkStatusNotFound {
+		t.Fatalf("results = %+v", results)
+	}
+
+	for _, op := range []string{"tag", "reset_schedule", "archive"} {
+		expectError(t, bulk(map[string]any{"op": op, "ids": []int{dogId}}), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+	}
+	expectError(t, bulk(map[string]any{"op": "move", "ids": []int{dogId}}), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+	expectError(t, bulk(map[string]any{"op": "delete", "ids": []int{}}), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+	expectError(t, bulk(map[string]any{"op": "move", "ids": []int{dogId}, "categoryId": 999}), http.StatusNotFound, utils.ErrCategoryNotFound.ErrorCode)
+}
+
+func TestListsAreServedFromCache(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	s.createFlashcard("1", categoryId, "go", "went")
+
+	// Both lists were just read by the helpers above, so these are hits.
+	loads := s.service.CacheStats().DatabaseLoads
+	expect(t, s.do(http.MethodGet, "", "1", nil), http.StatusOK)
+	expect(t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil), http.StatusOK)
+	if got := s.service.CacheStats().DatabaseLoads; got != loads {
+		t.Fatalf("cache hits loaded from the database: %d loads, want %d", got, loads)
+	}
+
+	// A write behind the cache's back stays invisible until an API write
+	// invalidates the list.
+	_, err := repositories_impl.NewCategoryRepositoryImpl(s.db).Insert("1", "nouns")
+	if err != nil {
+		t.Fatalf("insert category: %v", err)
+	}
+	if categories := decode[[]model.Category](t, s.do(http.MethodGet, "", "1", nil)); len(categories) != 1 {
+		t.Fatalf("cache hit returned %+v", categories)
+	}
+	s.createCategory("1", "adjectives")
+	if categories := decode[[]model.Category](t, s.do(http.MethodGet, "", "1", nil)); len(categories) != 3 {
+		t.Fatalf("list after invalidation = %+v", categories)
+	}
+}
+
+func TestListMissLoadsFromDatabase(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+
+	loads := s.service.CacheStats().DatabaseLoads
+	flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil))
+	if len(flashcards) != 0 {
+		t.Fatalf("flashcards = %+v", flashcards)
+	}
+	if got := s.service.CacheStats().DatabaseLoads; got != loads+1 {
+		t.Fatalf("cache miss made %d loads, want 1", got-loads)
+	}
+
+	// The empty list is cached too.
+	s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil)
+	if got := s.service.CacheStats().DatabaseLoads; got != loads+1 {
+		t.Fatalf("second read made %d loads, want 1", got-loads)
+	}
+}
+
+func TestUsersAreIsolated(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	flashcardId := s.createFlashcard("1", categoryId, "go", "went")
+	path := "/" + categoryId + "/flashcards/" + flashcardId
+
+	if categories := decode[[]model.Category](t, s.do(http.MethodGet, "", "2", nil)); len(categories) != 0 {
+		t.Fatalf("user 2 lists %+v", categories)
+	}
+	if flashcards := decode[[]model.Flashcard](t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", "2", nil)); len(flashcards) != 0 {
+		t.Fatalf("user 2 lists %+v", flashcards)
+	}
+	expect(t, s.do(http.MethodGet, "/"+categoryId, "2", nil), http.StatusNotFound)
+	expect(t, s.do(http.MethodGet, path, "2", nil), http.StatusNotFound)
+	expect(t, s.do(http.MethodPut, "/"+categoryId, "2", map[string]string{"name": "x"}, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusNotFound)
+	expect(t, s.do(http.MethodDelete, "/"+categoryId, "2", nil, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusNotFound)
+	expect(t, s.do(http.MethodPut, path, "2", map[string]any{"name": "x", "categoryId": mustAtoi(t, categoryId)}, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusNotFound)
+	expect(t, s.do(http.MethodDelete, path, "2", nil, constant.IfMatchHeader, utils.VersionETag(1)), http.StatusNotFound)
+	expect(t, s.do(http.MethodGet, path+"/revisions", "2", nil), http.StatusNotFound)
+
+	if c := decode[model.Category](t, s.do(http.MethodGet, "/"+categoryId, "1", nil)); c.Name != "verbs" || c.Version != 1 {
+		t.Fatalf("user 2 changed %+v", c)
+	}
+	if f := decode[model.Flashcard](t, s.do(http.MethodGet, path, "1", nil)); f.Content != "went" || f.Version != 1 {
+		t.Fatalf("user 2 changed %+v", f)
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestErrorsAreLocalized(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodGet, "/999", "1", nil, "Accept-Language", "en-GB,en;q=0.8")
+	response := expectError(t, rec, http.StatusNotFound, "CATEGORY_NOT_FOUND")
+	if response.Message != "Category not found" || rec.Header().Get("Content-Language") != "en" {
+		t.Fatalf("message = %q, Content-Language = %q", response.Message, rec.Header().Get("Content-Language"))
+	}
+
+	response = expectError(t, s.do(http.MethodGet, "", "", nil, "Accept-Language", "en"), http.StatusBadRequest, "VALIDATION_FAILED")
+	if len(response.Details) != 1 || response.Details[0].Message != "Is required" {
+		t.Fatalf("details = %+v", response.Details)
+	}
+
+	response = expectError(t, s.do(http.MethodGet, "/999", "1", nil), http.StatusNotFound, "CATEGORY_NOT_FOUND")
+	if response.Message != "Không tìm thấy danh mục" {
+		t.Fatalf("default message = %q", response.Message)
+	}
+}
+
+func TestListETagIgnoresLanguage(t *testing.T) {
+	s := newTestServer(t)
+	categoryId := s.createCategory("1", "verbs")
+	s.createFlashcard("1", categoryId, "go", "went")
+
+	vi := s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil)
+	en := s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil, "Accept-Language", "en")
+	etag := vi.Header().Get(constant.ETagHeader)
+	if !strings.HasPrefix(etag, `W/"`) || en.Header().Get(constant.ETagHeader) != etag || !slices.Contains(en.Header().Values("Vary"), "Accept-Language") {
+		t.Fatalf("ETag vi = %q, en = %q, Vary = %q", etag, en.Header().Get(constant.ETagHeader), en.Header().Values("Vary"))
+	}
+	expect(t, s.do(http.MethodGet, "/"+categoryId+"/flashcards", "1", nil, "Accept-Language", "en", constant.IfNoneMatchHeader, etag), http.StatusNotModified)
+}
@@ -1,39 +1,438 @@
 package category
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"flashcard_service/internal/events"
 	"flashcard_service/internal/model"
+	"flashcard_service/internal/worker"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/constant"
 	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/objects"
 	"flashcard_service/pkg/utils"
+	"flashcard_service/pkg/validation"
+	"math/rand"
 	"net/http"
+	"os"
+	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
 )
 
+const (
+	// generationExpiry outlives any fill that could still be in flight. An
+	// expired counter reads as 0, which no in-flight fill expects any more.
+	generationExpiry = time.Hour
+	// emptyHashField marks a cached list that is known to be empty, so empty
+	// decks are served from the cache instead of looking like a miss.
+	emptyHashField = "_empty"
+)
+
+// CategoryService caches categories and flashcards in Redis. Writes
+// invalidate the cache and bump the user's cache generation, and reads fill it
+// again only if the generation did not move while they were loading from the
+// database. Partial updates patch the one entry they changed instead, when no
+// other write of the user happened meanwhile. When an invalidation fails it is
+// retried on the worker queue and, until it is safe again, this instance
+// serves the user from the database so the writer always reads its own writes.
+//
+// Concurrent misses on the same list are coalesced into one database query,
+// and expiries are jittered so entries filled together do not expire together.
+//
+// Decoded lists are also kept in a small in-process LRU in front of Redis.
+// Invalidations drop local entries synchronously on the writing instance and
+// reach the other instances through Redis Pub/Sub; the short local TTL bounds
+// how long an instance that missed a message can serve an old list.
 type CategoryService struct {
-	r *redis.RedisDatabase
+	r             cache.Store
+	events        *events.Publisher
+	queue         *worker.Queue
+	local         *cache.LRU
+	codec         cache.Codec
+	instanceId    string
+	loads         singleflight.Group
+	ttl           time.Duration
+	emptyTtl      time.Duration
+	jitter        float64
+	redisHits     atomic.Int64
+	redisMisses   atomic.Int64
+	databaseLoads atomic.Int64
+	mu            sync.Mutex
+	bypass        map[string]time.Time
+	// primaryReadWindow is how long after a write the user's reads go to
+	// the primary database, see WroteRecently.
+	primaryReadWindow time.Duration
+}
+
+type invalidationMessage struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+func NewCategoryService(r cache.Store, queue *worker.Queue) *CategoryService {
+	ttlSeconds, err := strconv.Atoi(os.Getenv("CACHE_TTL_BY_SECOND"))
+	if err != nil || ttlSeconds <= 0 {
+		ttlSeconds = 300
+	}
+	emptyTtlSeconds, err := strconv.Atoi(os.Getenv("CACHE_EMPTY_TTL_BY_SECOND"))
+	if err != nil || emptyTtlSeconds <= 0 {
+		emptyTtlSeconds = 60
+	}
+	jitterPercent, err := strconv.Atoi(os.Getenv("CACHE_TTL_JITTER_PERCENT"))
+	if err != nil || jitterPercent < 0 {
+		jitterPercent = 10
+	}
+	localMaxItems, err := strconv.Atoi(os.Getenv("CACHE_LOCAL_MAX_ITEMS"))
+	if err != nil || localMaxItems <= 0 {
+		localMaxItems = 50000
+	}
+	localTtlSeconds, err := strconv.Atoi(os.Getenv("CACHE_LOCAL_TTL_BY_SECOND"))
+	if err != nil || localTtlSeconds <= 0 {
+		localTtlSeconds = 10
+	}
+	primaryReadSeconds, err := strconv.Atoi(os.Getenv("READ_FROM_PRIMARY_AFTER_WRITE_BY_SECOND"))
+	if err != nil || primaryReadSeconds < 0 {
+		primaryReadSeconds = 5
+	}
+	codec, err := cache.NewCodecFromEnv()
+	if err != nil {
+		log.Error().Str("error", "error when build cache codec, using uncompressed msgpack: "+err.Error()).Msg("")
+		codec, _ = cache.NewMsgpackCodec(cache.CompressionNone, 0)
+	}
+	return &CategoryService{
+		r:          r,
+		codec:      codec,
+		events:     events.NewPublisher(r),
+		queue:      queue,
+		local:      cache.NewLRU(localMaxItems, time.Duration(localTtlSeconds)*time.Second),
+		instanceId: uuid.New().String(),
+		ttl:        time.Duration(ttlSeconds) * time.Second,
+		emptyTtl:   time.Duration(emptyTtlSeconds) * time.Second,
+		jitter:     float64(jitterPercent) / 100,
+		bypass:     make(map[string]time.Time),
+
+		primaryReadWindow: time.Duration(primaryReadSeconds) * time.Second,
+	}
+}
+
+// PublishEvent notifies the user's other devices about a change. Delivery is
+// best effort and runs on the worker queue.
+func (c *CategoryService) PublishEvent(event events.Event) {
+	accepted := c.queue.Submit("publish "+string(event.Type), func() error {
+		return c.events.Publish(event)
+	})
+	if !accepted {
+		log.Info().Msg("Failed to publish " + string(event.Type) + " event: worker queue is full")
+	}
+}
+
+// InvalidateCategories drops the cached category list of a user and the cached
+// flashcards of the given categories.
+func (c *CategoryService) InvalidateCategories(userId string, categoryIds ...string) {
+	keys := []string{redis.GetCategoriesKey(userId)}
+	for _, categoryId := range categoryIds {
+		keys = append(keys, redis.GetFlashcardsKey(userId, categoryId))
+	}
+	c.invalidate(userId, keys)
 }
 
-func (c *CategoryService) SaveCategoriesToRedisHash(userId string, categories []model.Category) error {
+// InvalidateFlashcards drops the cached flashcards of the given categories.
+func (c *CategoryService) InvalidateFlashcards(userId string, categoryIds ...string) {
+	keys := make([]string, 0, len(categoryIds))
+	for _, categoryId := range categoryIds {
+		keys = append(keys, redis.GetFlashcardsKey(userId, categoryId))
+	}
+	c.invalidate(userId, keys)
+}
+
+func (c *CategoryService) invalidate(userId string, keys []string) {
+	// The local entries go last: a reader that refills them from Redis before
+	// this point is rejected by the epoch, and one that starts after sees
+	// Redis already invalidated.
+	defer c.local.Delete(keys...)
+	generationKey := redis.GetCacheGenerationKey(userId)
+	invalidate := func() error {
+		err := c.markWrite(userId)
+		if err != nil {
+			return err
+		}
+		err = c.r.Invalidate(generationKey, generationExpiry, keys...)
+		if err != nil {
+			return err
+		}
+		return c.publishInvalidation(keys)
+	}
+	err := invalidate()
+	if err == nil {
+		return
+	}
+
+	log.Info().Msg("Failed to invalidate Redis cache, retrying in background: " + err.Error())
+	// Long enough for any entry cached before the failed invalidation to
+	// expire.
+	c.mu.Lock()
+	c.bypass[userId] = time.Now().Add(2 * c.maxTtl())
+	c.mu.Unlock()
+	c.queue.Submit("invalidate cache of user "+userId, invalidate)
+}
+
+// CacheGeneration returns the cache generation of the user. Read it before a
+// write whose result is passed to UpdateCachedCategory or
+// UpdateCachedFlashcard. It is -1 when Redis cannot tell, which makes the
+// update fall back to an invalidation.
+func (c *CategoryService) CacheGeneration(userId string) int64 {
+	generation, err := c.r.GetGeneration(redis.GetCacheGenerationKey(userId))
+	if err != nil {
+		return -1
+	}
+	return generation
+}
+
+// UpdateCachedCategory replaces the entry of category in the cached category
+// list of the user, keeping the rest of the list. If the user wrote anything
+// else since generation was read the list is dropped instead.
+func (c *CategoryService) UpdateCachedCategory(userId string, generation int64, category model.Category) {
+	c.patch(userId, generation, redis.GetCategoriesKey(userId), strconv.FormatInt(category.Id, 10), category)
+}
+
+// UpdateCachedFlashcard replaces the entry of flashcard in the cached deck of
+// its category, like UpdateCachedCategory. A card that moved to another
+// category must be invalidated instead.
+func (c *CategoryService) UpdateCachedFlashcard(userId string, generation int64, flashcard model.Flashcard) {
+	c.patch(userId, generation, redis.GetFlashcardsKey(userId, strconv.Itoa(flashcard.CategoryId)), strconv.FormatInt(flashcard.ID, 10), flashcard)
+}
+
+// patch writes one entry of a cached list in place. Like an invalidation it
+// bumps the generation, so fills loaded before the write are still rejected,
+// and drops the decoded list from the local tiers.
+func (c *CategoryService) patch(userId string, generation int64, key string, field string, value any) {
+	bytes, err := c.codec.Encode(value)
+	if err != nil {
+		log.Error().Str("error", "error when encode cache entry: "+err.Error()).Msg("")
+		c.invalidate(userId, []string{key})
+		return
+	}
+	defer c.local.Delete(key)
+	err = c.markWrite(userId)
+	if err == nil {
+		_, err = c.r.PatchHash(redis.GetCacheGenerationKey(userId), generation, generationExpiry, key, field, string(bytes))
+	}
+	if err == nil {
+		err = c.publishInvalidation([]string{key})
+	}
+	if err != nil {
+		log.Info().Msg("Failed to patch Redis cache, invalidating instead: " + err.Error())
+		c.invalidate(userId, []string{key})
+	}
+}
+
+// markWrite starts the primary read window of the user, see WroteRecently.
+func (c *CategoryService) markWrite(userId string) error {
+	if c.primaryReadWindow <= 0 {
+		return nil
+	}
+	return c.r.Set(redis.GetRecentWriteKey(userId), "1", int64(c.primaryReadWindow/time.Second))
+}
+
+func (c *CategoryService) publishInvalidation(keys []string) error {
+	payload, err := json.Marshal(invalidationMessage{Origin: c.instanceId, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return c.r.Publish(redis.GetCacheInvalidationChannel(), string(payload))
+}
+
+// ListenForInvalidations drops the local entries other instances invalidate,
+// resubscribing until ctx is done. The local tier is purged on every
+// (re)subscription since messages sent meanwhile are lost.
+func (c *CategoryService) ListenForInvalidations(ctx context.Context) {
+	for ctx.Err() == nil {
+		messages, closeSubscription, err := c.r.Subscribe(ctx, redis.GetCacheInvalidationChannel())
+		if err != nil {
+			log.Error().Str("error", "error when subscribe to cache invalidations: "+err.Error()).Msg("")
+			time.Sleep(time.Second)
+			continue
+		}
+		c.local.Purge()
+		for payload := range messages {
+			var message invalidationMessage
+			err = json.Unmarshal([]byte(payload), &message)
+			if err != nil || message.Origin == c.instanceId {
+				continue
+			}
+			c.local.Delete(message.Keys...)
+		}
+		closeSubscription()
+	}
+}
+
+// CacheStats reports hits per cache tier since the process started.
+func (c *CategoryService) CacheStats() objects.CacheStats {
+	return objects.CacheStats{
+		Local: c.local.Stats(),
+		Redis: objects.CacheTierStats{
+			Hits:   c.redisHits.Load(),
+			Misses: c.redisMisses.Load(),
+		},
+		DatabaseLoads: c.databaseLoads.Load(),
+	}
+}
+
+// CanReadCache reports whether the cached entries of the user can be served.
+// It is false for a while after an invalidation of that user failed.
+func (c *CategoryService) CanReadCache(userId string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	until, ok := c.bypass[userId]
+	if !ok {
+		return true
+	}
+	if time.Now().After(until) {
+		delete(c.bypass, userId)
+		return true
+	}
+	return false
+}
+
+// WroteRecently reports whether the user wrote within the primary read window,
+// in which case reads of the user's data should go to the primary so they see
+// the write even if the replicas lag.
+func (c *CategoryService) WroteRecently(userId string) bool {
+	if c.primaryReadWindow <= 0 {
+		return false
+	}
+	if !c.CanReadCache(userId) {
+		return true
+	}
+	wrote, err := c.r.Exists(redis.GetRecentWriteKey(userId))
+	if err != nil {
+		return true
+	}
+	return wrote
+}
+
+// GetCategories reads the categories of a user through the local and Redis
+// caches, calling load on a miss.
+func (c *CategoryService) GetCategories(userId string, load func() ([]model.Category, error)) ([]model.Category, error) {
+	return readThrough(c, userId, redis.GetCategoriesKey(userId),
+		func() ([]model.Category, bool, error) {
+			return c.GetCategoriesFromRedisHash(userId)
+		},
+		load,
+		func(generation int64, categories []model.Category) error {
+			return c.SaveCategoriesToRedisHash(userId, generation, categories)
+		},
+	)
+}
+
+// GetFlashcards reads the flashcards of a category through the local and Redis
+// caches, calling load on a miss.
+func (c *CategoryService) GetFlashcards(userId string, categoryId string, load func() ([]model.Flashcard, error)) ([]model.Flashcard, error) {
+	return readThrough(c, userId, redis.GetFlashcardsKey(userId, categoryId),
+		func() ([]model.Flashcard, bool, error) {
+			return c.GetFlashcardsFromRedisHash(userId, categoryId)
+		},
+		load,
+		func(generation int64, flashcards []model.Flashcard) error {
+			return c.SaveFlashcardsToRedisHash(userId, categoryId, generation, flashcards)
+		},
+	)
+}
+
+// readThrough serves key from the local tier, then Redis, then load. Loads
+// run once for concurrent misses on the same key and cache generation, so a
+// read that starts after a write never joins a load that started before it.
+// Without a generation the load is neither shared nor cached.
+func readThrough[T any](
+	c *CategoryService,
+	userId string,
+	key string,
+	fromRedis func() ([]T, bool, error),
+	load func() ([]T, error),
+	save func(generation int64, items []T) error,
+) ([]T, error) {
+	if c.CanReadCache(userId) {
+		if value, ok := c.local.Get(key); ok {
+			return value.([]T), nil
+		}
+		epoch := c.local.Epoch()
+		cached, found, err := fromRedis()
+		if err == nil && found {
+			c.redisHits.Add(1)
+			c.local.SetIfEpoch(key, cached, len(cached), epoch)
+			return cached, nil
+		}
+		c.redisMisses.Add(1)
+	}
+
+	generation, err := c.r.GetGeneration(redis.GetCacheGenerationKey(userId))
+	if err != nil {
+		c.databaseLoads.Add(1)
+		return load()
+	}
+	result, err, _ := c.loads.Do(key+"@"+strconv.FormatInt(generation, 10), func() (any, error) {
+		epoch := c.local.Epoch()
+		c.databaseLoads.Add(1)
+		items, err := load()
+		if err != nil {
+			return nil, err
+		}
+		err = save(generation, items)
+		if err != nil {
+			log.Info().Msg("Failed to fill Redis cache: " + err.Error())
+		}
+		c.local.SetIfEpoch(key, items, len(items), epoch)
+		return items, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]T), nil
+}
+
+// expiry returns the TTL for a cache entry in seconds, spread by up to the
+// configured jitter.
+func (c *CategoryService) expiry(empty bool) int64 {
+	ttl := c.ttl
+	if empty {
+		ttl = c.emptyTtl
+	}
+	if c.jitter > 0 {
+		ttl += time.Duration(rand.Float64() * c.jitter * float64(ttl))
+	}
+	return int64(ttl / time.Second)
+}
+
+func (c *CategoryService) maxTtl() time.Duration {
+	return time.Duration(float64(max(c.ttl, c.emptyTtl)) * (1 + c.jitter))
+}
+
+// SaveCategoriesToRedisHash fills the category cache with data loaded at the
+// given cache generation. Nothing is written if a write happened since.
+func (c *CategoryService) SaveCategoriesToRedisHash(userId string, generation int64, categories []model.Category) error {
 	key := redis.GetCategoriesKey(userId)
-	fields := make(map[string]any)
+	fields := map[string]any{}
 	for _, category := range categories {
-		bytes, err := json.Marshal(category)
+		bytes, err := c.codec.Encode(category)
 		if err != nil {
 			return err
 		}
 		strId := strconv.FormatInt(category.Id, 10)
 		fields[strId] = string(bytes)
 	}
-	return c.r.HMSetWithExpiry(key, fields, 300)
-}
-
-func (c *CategoryService) SaveCategoryToRedisHash(userId string, categoryId string, category model.Category) error {
-	key := redis.GetCategoriesKey(userId)
-	bytes, _ := json.Marshal(category)
-	return c.r.HSetWithExpiry(key, categoryId, string(bytes), 300)
+	if len(fields) == 0 {
+		fields[emptyHashField] = "1"
+	}
+	_, err := c.r.HMSetWithExpiryIfGeneration(redis.GetCacheGenerationKey(userId), generation, key, fields, c.expiry(len(categories) == 0))
+	return err
 }
 
 func (c *CategoryService) GetCategoryFromRedisHash(userId string, categoryId string) (model.Category, error) {
@@ -43,87 +442,120 @@ func (c *CategoryService) GetCategoryFromRedisHash(userId string, categoryId str
 		return model.Category{}, err
 	}
 	var category model.Category
-	err = json.Unmarshal([]byte(value), &category)
+	err = c.codec.Decode([]byte(value), &category)
 	if err != nil {
 		return model.Category{}, err
 	}
 	return category, nil
 }
 
-func (c *CategoryService) GetCategoriesFromRedisHash(userId string) ([]model.Category, error) {
+// GetCategoriesFromRedisHash returns the cached categories of a user and
+// whether they were cached at all. A cached empty list is found.
+func (c *CategoryService) GetCategoriesFromRedisHash(userId string) ([]model.Category, bool, error) {
 	key := redis.GetCategoriesKey(userId)
 	values, err := c.r.HGetAll(key)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if len(values) == 0 {
+		return nil, false, nil
 	}
 	categories := make([]model.Category, 0)
-	for _, value := range values {
+	for field, value := range values {
+		if field == emptyHashField {
+			continue
+		}
 		var category model.Category
-		err = json.Unmarshal([]byte(value), &category)
+		err = c.codec.Decode([]byte(value), &category)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		categories = append(categories, category)
 	}
-	return categories, nil
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Id < categories[j].Id
+	})
+	return categories, true, nil
 }
 
-func (c *CategoryService) SaveFlashcardsToRedisHash(userId string, categoryId string, flashcards []model.Flashcard) error {
+// SaveFlashcardsToRedisHash fills the flashcard cache of a category with data
+// loaded at the given cache generation. Nothing is written if a write
+// happened since.
+func (c *CategoryService) SaveFlashcardsToRedisHash(userId string, categoryId string, generation int64, flashcards []model.Flashcard) error {
 	key := redis.GetFlashcardsKey(userId, categoryId)
-	fields := make(map[string]any)
+	fields := map[string]any{}
 	for _, flashcard := range flashcards {
-		bytes, err := json.Marshal(flashcard)
+		bytes, err := c.codec.Encode(flashcard)
 		if err != nil {
 			return err
 		}
 		strId := strconv.FormatInt(flashcard.ID, 10)
 		fields[strId] = string(bytes)
 	}
-	return c.r.HMSetWithExpiry(key, fields, 300)
-}
-
-func (c *CategoryService) SaveFlashcardToRedisHash(userId string, categoryId string, flashcard model.Flashcard) error {
-	key := redis.GetFlashcardsKey(userId, categoryId)
-	bytes, _ := json.Marshal(flashcard)
-	return c.r.HSetWithExpiry(key, strconv.FormatInt(flashcard.ID, 10), string(bytes), 300)
+	if len(fields) == 0 {
+		fields[emptyHashField] = "1"
+	}
+	_, err := c.r.HMSetWithExpiryIfGeneration(redis.GetCacheGenerationKey(userId), generation, key, fields, c.expiry(len(flashcards) == 0))
+	return err
 }
 
-func (c *CategoryService) GetFlashcardsFromRedisHash(userId string, categoryId string) ([]model.Flashcard, error) {
+// GetFlashcardsFromRedisHash returns the cached flashcards of a category and
+// whether they were cached at all. A cached empty deck is found.
+func (c *CategoryService) GetFlashcardsFromRedisHash(userId string, categoryId string) ([]model.Flashcard, bool, error) {
 	key := redis.GetFlashcardsKey(userId, categoryId)
 	values, err := c.r.HGetAll(key)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if len(values) == 0 {
+		return nil, false, nil
 	}
 	flashcards := make([]model.Flashcard, 0)
-	for _, value := range values {
+	for field, value := range values {
+		if field == emptyHashField {
+			continue
+		}
 		var flashcard model.Flashcard
-		err = json.Unmarshal([]byte(value), &flashcard)
+		err = c.codec.Decode([]byte(value), &flashcard)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		flashcards = append(flashcards, flashcard)
 	}
-	return flashcards, nil
+	sort.Slice(flashcards, func(i, j int) bool {
+		return flashcards[i].ID < flashcards[j].ID
+	})
+	return flashcards, true, nil
 }
 
-func (c *CategoryService) DeleteFlashcardFromRedisHash(userId string, categoryId string, flashcardId string) error {
-	key := redis.GetFlashcardsKey(userId, categoryId)
-	return c.r.HDel(key, flashcardId)
-}
-
-func (c *CategoryService) isUserIdInvalid(userId string, r *http.Request, trackingId string) bool {
+func (c *CategoryService) IsUserIdInvalid(userId string, r *http.Request, trackingId string) bool {
 	if len(userId) == 0 {
 		msg := "userid invalid"
 		log.Error().
 			Str("trackingId", trackingId).
 			Str("error", msg).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, errors.New(msg))
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField(constant.UserIdHeader)), errors.New(msg))
 		return true
 	}
 	return false
 }
 
+// isRequestInvalid checks request against its validate tags.
+func (c *CategoryService) isRequestInvalid(request any, r *http.Request, trackingId string) bool {
+	details := validation.Validate(request)
+	if len(details) == 0 {
+		return false
+	}
+	msg := "request invalid"
+	log.Error().
+		Str("trackingId", trackingId).
+		Str("error", msg).
+		Msg("")
+	utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(details...), errors.New(msg))
+	return true
+}
+
 func (c *CategoryService) isFlashcardAndCategoryInvalid(categoryId string, flashcardId string, r *http.Request, trackingId string) bool {
 	if len(categoryId) == 0 || len(flashcardId) == 0 {
 		msg := "error when delete flashcard: category_id or flashcard_id is empty"
@@ -131,7 +563,14 @@ func (c *CategoryService) isFlashcardAndCategoryInvalid(categoryId string, flash
 			Str("trackingId", trackingId).
 			Str("error", msg).
 			Msg("")
-		utils.SetHttpReponseError(r, utils.ErrBadRequest, errors.New(msg))
+		details := make([]objects.FieldError, 0, 2)
+		if len(categoryId) == 0 {
+			details = append(details, utils.RequiredField("category_id"))
+		}
+		if len(flashcardId) == 0 {
+			details = append(details, utils.RequiredField("flashcard_id"))
+		}
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(details...), errors.New(msg))
 		return true
 	}
 	return false
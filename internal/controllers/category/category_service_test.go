@@ -0,0 +1,334 @@
+package category
+
+import (
+	"context"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/worker"
+	"flashcard_service/pkg/database/redis"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestCategoryService(t *testing.T) (*CategoryService, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	t.Setenv("REDIS_URL", server.Addr())
+	t.Setenv("REDIS_PASSWORD", "")
+	t.Setenv("REDIS_DB", "0")
+
+	r := redis.NewRedisClient()
+	err := r.Connect()
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	queue := worker.NewQueue(2, 100, 3, time.Millisecond)
+	t.Cleanup(func() {
+		queue.Close()
+		r.Close()
+	})
+	return NewCategoryService(r, queue), server
+}
+
+// newPeerCategoryService returns a service sharing the Redis of server, as
+// another instance of the API would.
+func newPeerCategoryService(t *testing.T, server *miniredis.Miniredis) *CategoryService {
+	t.Helper()
+	r := redis.NewRedisClient()
+	err := r.Connect()
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	queue := worker.NewQueue(2, 100, 3, time.Millisecond)
+	t.Cleanup(func() {
+		queue.Close()
+		r.Close()
+	})
+	return NewCategoryService(r, queue)
+}
+
+// fakeCategories stands in for the categories table of one user.
+type fakeCategories struct {
+	mu   sync.Mutex
+	name string
+}
+
+func (f *fakeCategories) set(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.name = name
+}
+
+func (f *fakeCategories) load() ([]model.Category, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return []model.Category{{Id: 1, Name: f.name}}, nil
+}
+
+func TestStaleFillIsDiscarded(t *testing.T) {
+	service, _ := newTestCategoryService(t)
+	db := &fakeCategories{name: "old"}
+
+	// A reader loads the old row, then a writer commits and invalidates
+	// before the reader gets to fill the cache.
+	_, err := service.GetCategories("1", func() ([]model.Category, error) {
+		categories, err := db.load()
+		db.set("new")
+		service.InvalidateCategories("1")
+		return categories, err
+	})
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+
+	categories, err := service.GetCategories("1", db.load)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	if categories[0].Name != "new" {
+		t.Fatalf("read %q after the write, want %q", categories[0].Name, "new")
+	}
+}
+
+func TestWriterReadsItsOwnWrites(t *testing.T) {
+	service, _ := newTestCategoryService(t)
+	db := &fakeCategories{name: "0"}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_, err := service.GetCategories("1", db.load)
+				if err != nil {
+					t.Errorf("GetCategories: %v", err)
+					return
+				}
+				time.Sleep(50 * time.Microsecond)
+			}
+		}()
+	}
+
+	for i := 1; i <= 200; i++ {
+		name := strconv.Itoa(i)
+		db.set(name)
+		service.InvalidateCategories("1")
+
+		categories, err := service.GetCategories("1", db.load)
+		if err != nil {
+			t.Fatalf("GetCategories: %v", err)
+		}
+		if categories[0].Name != name {
+			t.Fatalf("writer read %q after writing %q", categories[0].Name, name)
+		}
+	}
+	close(stop)
+	readers.Wait()
+}
+
+func TestFailedInvalidationBypassesCache(t *testing.T) {
+	service, server := newTestCategoryService(t)
+	db := &fakeCategories{name: "old"}
+
+	_, err := service.GetCategories("1", db.load)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+
+	server.SetError("unavailable")
+	db.set("new")
+	service.InvalidateCategories("1")
+	if service.CanReadCache("1") {
+		t.Fatal("cache still readable after a failed invalidation")
+	}
+	server.SetError("")
+
+	categories, err := service.GetCategories("1", db.load)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	if categories[0].Name != "new" {
+		t.Fatalf("read %q after the write, want %q", categories[0].Name, "new")
+	}
+	if !service.CanReadCache("2") {
+		t.Fatal("other users lost their cache")
+	}
+}
+
+func TestEmptyListIsCached(t *testing.T) {
+	service, _ := newTestCategoryService(t)
+	var loads atomic.Int32
+	load := func() ([]model.Flashcard, error) {
+		loads.Add(1)
+		return []model.Flashcard{}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		flashcards, err := service.GetFlashcards("1", "7", load)
+		if err != nil {
+			t.Fatalf("GetFlashcards: %v", err)
+		}
+		if len(flashcards) != 0 {
+			t.Fatalf("got %d flashcards, want none", len(flashcards))
+		}
+	}
+	if got := loads.Load(); got != 1 {
+		t.Fatalf("database loaded %d times, want 1", got)
+	}
+}
+
+func TestConcurrentMissesAreCoalesced(t *testing.T) {
+	service, _ := newTestCategoryService(t)
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func() ([]model.Category, error) {
+		loads.Add(1)
+		<-release
+		return []model.Category{{Id: 1, Name: "shared"}}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			categories, err := service.GetCategories("1", load)
+			if err != nil || len(categories) != 1 {
+				t.Errorf("GetCategories = %v, %v", categories, err)
+			}
+		}()
+	}
+	// Give every reader time to miss the cache and join the load.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Fatalf("database loaded %d times, want 1", got)
+	}
+}
+
+func TestExpiryIsJittered(t *testing.T) {
+	service, _ := newTestCategoryService(t)
+	service.ttl = 100 * time.Second
+	service.jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		expiry := service.expiry(false)
+		if expiry < 100 || expiry > 150 {
+			t.Fatalf("expiry %d outside [100, 150]", expiry)
+		}
+	}
+}
+
+func TestInvalidationReachesOtherInstances(t *testing.T) {
+	writer, server := newTestCategoryService(t)
+	reader := newPeerCategoryService(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reader.ListenForInvalidations(ctx)
+	// Wait for the subscription, which purges the local tier once.
+	deadline := time.Now().Add(time.Second)
+	for server.PubSubNumSub(redis.GetCacheInvalidationChannel())[redis.GetCacheInvalidationChannel()] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("reader never subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	db := &fakeCategories{name: "old"}
+	_, err := reader.GetCategories("1", db.load)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	_, err = reader.GetCategories("1", db.load)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	if hits := reader.CacheStats().Local.Hits; hits != 1 {
+		t.Fatalf("local hits = %d, want 1", hits)
+	}
+
+	db.set("new")
+	writer.InvalidateCategories("1")
+
+	deadline = time.Now().Add(time.Second)
+	for {
+		categories, err := reader.GetCategories("1", db.load)
+		if err != nil {
+			t.Fatalf("GetCategories: %v", err)
+		}
+		if categories[0].Name == "new" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reader kept serving the invalidated list")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUnreadableEntryIsReplaced(t *testing.T) {
+	service, server := newTestCategoryService(t)
+	key := redis.GetCategoriesKey("1")
+	server.HSet(key, "1", "\x7fgarbage")
+
+	db := &fakeCategories{name: "fresh"}
+	categories, err := service.GetCategories("1", db.load)
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	if categories[0].Name != "fresh" {
+		t.Fatalf("read %q, want %q", categories[0].Name, "fresh")
+	}
+	if value := server.HGet(key, "1"); value == "\x7fgarbage" {
+		t.Fatal("unreadable entry was left in Redis")
+	}
+}
+
+func TestLegacyJSONEntryIsRead(t *testing.T) {
+	service, server := newTestCategoryService(t)
+	server.HSet(redis.GetFlashcardsKey("1", "7"), "5", `{"id":5,"name":"legacy","categoryId":7}`)
+
+	flashcards, err := service.GetFlashcards("1", "7", func() ([]model.Flashcard, error) {
+		t.Fatal("loaded from the database although the entry was readable")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("GetFlashcards: %v", err)
+	}
+	if len(flashcards) != 1 || flashcards[0].Name != "legacy" {
+		t.Fatalf("flashcards = %+v", flashcards)
+	}
+}
+
+func TestWriterReadsFromPrimary(t *testing.T) {
+	service, server := newTestCategoryService(t)
+	if service.WroteRecently("1") {
+		t.Fatal("user who never wrote should read from replicas")
+	}
+
+	service.InvalidateCategories("1")
+	if !service.WroteRecently("1") {
+		t.Fatal("user who just wrote should read from the primary")
+	}
+	if service.WroteRecently("2") {
+		t.Fatal("another user should still read from replicas")
+	}
+
+	server.FastForward(service.primaryReadWindow + time.Second)
+	if service.WroteRecently("1") {
+		t.Fatal("primary reads should stop after the window")
+	}
+}
@@ -0,0 +1,422 @@
+package offline_sync
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/repositories_impl"
+	"flashcard_service/pkg/objects"
+	"flashcard_service/pkg/utils"
+	"flashcard_service/pkg/validation"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Rows are timestamped with second precision and a transaction may commit a
+// little after its timestamp, so the change feed stops this far behind now and
+// picks the rest up on the next pull.
+const syncSafetyWindow = 2 * time.Second
+
+const maxSyncMutations = 500
+
+type SyncController struct {
+	categoryRepo  repositories.CategoryRepository
+	flashcardRepo repositories.FlashcardRepository
+	retention     time.Duration
+	*category.CategoryService
+}
+
+func NewSyncController(db database.Database, categoryService *category.CategoryService) *SyncController {
+	retentionDays, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || retentionDays <= 0 {
+		retentionDays = 30
+	}
+	// A replica lagging past the safety window would make a pull skip rows
+	// for good once the cursor moves past them, so sync reads the primary.
+	primary := db.Primary()
+	return &SyncController{
+		categoryRepo:    repositories_impl.NewCategoryRepositoryImpl(primary),
+		flashcardRepo:   repositories_impl.NewFlashcardRepositoryImpl(primary),
+		retention:       time.Duration(retentionDays) * 24 * time.Hour,
+		CategoryService: categoryService,
+	}
+}
+
+// GetChanges returns the categories and flashcards that changed since the
+// cursor, deleted ones as tombstones, plus the cursor for the next pull. An
+// empty cursor starts a full sync. A cursor older than the trash retention may
+// have missed purged tombstones, so the client is told to reset and gets the
+// full state instead.
+func (s *SyncController) GetChanges(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if s.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	since, err := decodeCursor(r.URL.Query().Get("since"))
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse sync cursor: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.InvalidField("since")), err)
+		return
+	}
+
+	until := time.Now().Add(-syncSafetyWindow).Truncate(time.Second)
+	resetRequired := !since.IsZero() && since.Before(time.Now().Add(-s.retention))
+	if resetRequired {
+		since = time.Time{}
+	}
+	if until.Before(since) {
+		until = since
+	}
+
+	categories, err := s.categoryRepo.FindChangedSince(userId, since, until)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get changed categories: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	flashcards, err := s.flashcardRepo.FindChangedSince(userId, since, until)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get changed flashcards: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, objects.SyncChanges{
+		Cursor:        encodeCursor(until),
+		ResetRequired: resetRequired,
+		Categories:    categories,
+		Flashcards:    flashcards,
+	})
+}
+
+// Push applies a batch of offline mutations in order. Each mutation succeeds
+// or conflicts on its own; conflicts carry the current server copy so the
+// client can merge and retry.
+func (s *SyncController) Push(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if s.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	var pushRequest objects.SyncPushRequest
+	appErr, err := utils.DecodeJSON(w, r, &pushRequest)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse sync push request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	if len(pushRequest.Mutations) > maxSyncMutations {
+		msg := "error when sync push: too many mutations, max is " + strconv.Itoa(maxSyncMutations)
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.TooManyField("mutations", maxSyncMutations)), errors.New(msg))
+		return
+	}
+
+	result := objects.SyncPushResult{
+		Applied:   make([]objects.SyncApplied, 0),
+		Conflicts: make([]objects.SyncConflict, 0),
+	}
+	for _, mutation := range pushRequest.Mutations {
+		var applied *objects.SyncApplied
+		var conflict *objects.SyncConflict
+		switch mutation.Entity {
+		case objects.SyncEntityCategory:
+			applied, conflict, err = s.applyCategoryMutation(userId, mutation)
+		case objects.SyncEntityFlashcard:
+			applied, conflict, err = s.applyFlashcardMutation(userId, mutation)
+		default:
+			conflict = newConflict(mutation, objects.SyncConflictInvalid, nil)
+		}
+		if err != nil {
+			log.Error().
+				Str("trackingId", trackingId).
+				Str("error", "error when apply sync mutation: "+err.Error()).
+				Msg("")
+			utils.SetHttpReponseError(r, utils.ErrServerError, err)
+			return
+		}
+		if conflict != nil {
+			result.Conflicts = append(result.Conflicts, *conflict)
+		} else {
+			result.Applied = append(result.Applied, *applied)
+		}
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, result)
+}
+
+// isPayloadInvalid checks the payload a create or update mutation writes
+// against the rules of the matching REST request.
+func isPayloadInvalid(mutation objects.SyncMutation, payload any) bool {
+	if mutation.Op != objects.SyncOpCreate && mutation.Op != objects.SyncOpUpdate {
+		return false
+	}
+	return len(validation.Validate(payload)) > 0
+}
+
+func (s *SyncController) applyCategoryMutation(userId string, mutation objects.SyncMutation) (*objects.SyncApplied, *objects.SyncConflict, error) {
+	if isPayloadInvalid(mutation, objects.CreateCategory{Name: mutation.Name}) {
+		return nil, newConflict(mutation, objects.SyncConflictInvalid, nil), nil
+	}
+	id := strconv.FormatInt(mutation.Id, 10)
+	if mutation.Op == objects.SyncOpCreate {
+		newId, err := s.categoryRepo.Insert(userId, mutation.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.invalidateCategory(userId, "")
+		s.publishCategory(events.CategoryCreated, userId, strconv.FormatInt(newId, 10))
+		return newApplied(mutation, newId, 1), nil, nil
+	}
+	if mutation.Op != objects.SyncOpUpdate && mutation.Op != objects.SyncOpDelete {
+		return nil, newConflict(mutation, objects.SyncConflictInvalid, nil), nil
+	}
+
+	current, err := s.categoryRepo.FindOneById(userId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newConflict(mutation, objects.SyncConflictNotFound, nil), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	version, conflict := resolveVersion(mutation, current.Version, current.UpdatedAt, current.CreatedAt, current)
+	if conflict != nil {
+		return nil, conflict, nil
+	}
+
+	if mutation.Op == objects.SyncOpDelete {
+		err = s.categoryRepo.DeleteById(userId, id, version)
+	} else {
+		err = s.categoryRepo.UpdateById(userId, id, mutation.Name, version)
+	}
+	if conflict, err := s.categoryWriteConflict(userId, id, mutation, err); conflict != nil || err != nil {
+		return nil, conflict, err
+	}
+	s.invalidateCategory(userId, id)
+	if mutation.Op == objects.SyncOpDelete {
+		s.CategoryService.PublishEvent(events.NewEvent(events.CategoryDeleted, userId, id, "", nil))
+	} else {
+		s.publishCategory(events.CategoryUpdated, userId, id)
+	}
+	return newApplied(mutation, current.Id, version+1), nil, nil
+}
+
+func (s *SyncController) applyFlashcardMutation(userId string, mutation objects.SyncMutation) (*objects.SyncApplied, *objects.SyncConflict, error) {
+	if isPayloadInvalid(mutation, objects.CreateFlashcard{Name: mutation.Name, Content: mutation.Content}) {
+		return nil, newConflict(mutation, objects.SyncConflictInvalid, nil), nil
+	}
+	id := strconv.FormatInt(mutation.Id, 10)
+	categoryId := strconv.Itoa(mutation.CategoryId)
+	if mutation.Op == objects.SyncOpCreate {
+		_, err := s.categoryRepo.FindOneById(userId, categoryId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, newConflict(mutation, objects.SyncConflictNotFound, nil), nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		newId, err := s.flashcardRepo.Insert(userId, categoryId, objects.CreateFlashcard{
+			Name:    mutation.Name,
+			Content: mutation.Content,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		s.invalidateFlashcards(userId, categoryId)
+		s.publishFlashcard(events.FlashcardCreated, userId, strconv.FormatInt(newId, 10))
+		return newApplied(mutation, newId, 1), nil, nil
+	}
+	if mutation.Op != objects.SyncOpUpdate && mutation.Op != objects.SyncOpDelete {
+		return nil, newConflict(mutation, objects.SyncConflictInvalid, nil), nil
+	}
+
+	current, err := s.flashcardRepo.FindOneById(userId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newConflict(mutation, objects.SyncConflictNotFound, nil), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	version, conflict := resolveVersion(mutation, current.Version, current.UpdatedAt, current.CreatedAt, current)
+	if conflict != nil {
+		return nil, conflict, nil
+	}
+
+	if mutation.Op == objects.SyncOpDelete {
+		err = s.flashcardRepo.DeleteById(userId, id, version)
+	} else {
+		if mutation.CategoryId == 0 {
+			mutation.CategoryId = current.CategoryId
+		}
+		if mutation.CategoryId != current.CategoryId {
+			_, err := s.categoryRepo.FindOneById(userId, strconv.Itoa(mutation.CategoryId))
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, newConflict(mutation, objects.SyncConflictNotFound, current), nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		err = s.flashcardRepo.UpdateById(userId, id, model.Flashcard{
+			Name:       mutation.Name,
+			Content:    mutation.Content,
+			CategoryId: mutation.CategoryId,
+		}, version)
+	}
+	if conflict, err := s.flashcardWriteConflict(userId, id, mutation, err); conflict != nil || err != nil {
+		return nil, conflict, err
+	}
+	s.invalidateFlashcards(userId, strconv.Itoa(current.CategoryId))
+	if mutation.Op == objects.SyncOpDelete {
+		s.CategoryService.PublishEvent(events.NewEvent(events.FlashcardDeleted, userId, strconv.Itoa(current.CategoryId), id, nil))
+		return newApplied(mutation, current.ID, version+1), nil, nil
+	}
+	if mutation.CategoryId != current.CategoryId {
+		s.invalidateFlashcards(userId, strconv.Itoa(mutation.CategoryId))
+	}
+	s.publishFlashcard(events.FlashcardUpdated, userId, id)
+	return newApplied(mutation, current.ID, version+1), nil, nil
+}
+
+// publishCategory announces a written category as the REST API returns it,
+// read back from the primary. The write already succeeded, so a failed read
+// only costs the event.
+func (s *SyncController) publishCategory(eventType events.EventType, userId string, id string) {
+	category, err := s.categoryRepo.FindOneById(userId, id)
+	if err != nil {
+		log.Info().Msg("Failed to read category " + id + " for " + string(eventType) + " event: " + err.Error())
+		return
+	}
+	s.CategoryService.PublishEvent(events.NewEvent(eventType, userId, id, "", category))
+}
+
+// publishFlashcard is publishCategory for flashcards.
+func (s *SyncController) publishFlashcard(eventType events.EventType, userId string, id string) {
+	flashcard, err := s.flashcardRepo.FindOneById(userId, id)
+	if err != nil {
+		log.Info().Msg("Failed to read flashcard " + id + " for " + string(eventType) + " event: " + err.Error())
+		return
+	}
+	s.CategoryService.PublishEvent(events.NewEvent(eventType, userId, strconv.Itoa(flashcard.CategoryId), id, flashcard))
+}
+
+func (s *SyncController) categoryWriteConflict(userId string, id string, mutation objects.SyncMutation, err error) (*objects.SyncConflict, error) {
+	if errors.Is(err, repositories.ErrVersionMismatch) || errors.Is(err, sql.ErrNoRows) {
+		latest, findErr := s.categoryRepo.FindOneById(userId, id)
+		if findErr != nil {
+			return newConflict(mutation, objects.SyncConflictNotFound, nil), nil
+		}
+		return newConflict(mutation, objects.SyncConflictVersionMismatch, latest), nil
+	}
+	return nil, err
+}
+
+func (s *SyncController) flashcardWriteConflict(userId string, id string, mutation objects.SyncMutation, err error) (*objects.SyncConflict, error) {
+	if errors.Is(err, repositories.ErrVersionMismatch) || errors.Is(err, sql.ErrNoRows) {
+		latest, findErr := s.flashcardRepo.FindOneById(userId, id)
+		if findErr != nil {
+			return newConflict(mutation, objects.SyncConflictNotFound, nil), nil
+		}
+		return newConflict(mutation, objects.SyncConflictVersionMismatch, latest), nil
+	}
+	return nil, err
+}
+
+func (s *SyncController) invalidateCategory(userId string, categoryId string) {
+	if len(categoryId) == 0 {
+		s.CategoryService.InvalidateCategories(userId)
+		return
+	}
+	s.CategoryService.InvalidateCategories(userId, categoryId)
+}
+
+func (s *SyncController) invalidateFlashcards(userId string, categoryId string) {
+	s.CategoryService.InvalidateFlashcards(userId, categoryId)
+}
+
+// resolveVersion picks the version the write is conditioned on. With a client
+// version that is the version itself; without one the server copy is only
+// overwritten when the client edit is at least as recent (last writer wins).
+func resolveVersion(mutation objects.SyncMutation, version int64, updatedAt *time.Time, createdAt *time.Time, current any) (int64, *objects.SyncConflict) {
+	if mutation.Version > 0 {
+		if mutation.Version != version {
+			return 0, newConflict(mutation, objects.SyncConflictVersionMismatch, current)
+		}
+		return version, nil
+	}
+
+	serverUpdatedAt := updatedAt
+	if serverUpdatedAt == nil {
+		serverUpdatedAt = createdAt
+	}
+	if mutation.ClientUpdatedAt == nil || (serverUpdatedAt != nil && mutation.ClientUpdatedAt.Before(*serverUpdatedAt)) {
+		return 0, newConflict(mutation, objects.SyncConflictStale, current)
+	}
+	return version, nil
+}
+
+func newApplied(mutation objects.SyncMutation, id int64, version int64) *objects.SyncApplied {
+	return &objects.SyncApplied{
+		ClientMutationId: mutation.ClientMutationId,
+		Entity:           mutation.Entity,
+		Id:               id,
+		Version:          version,
+	}
+}
+
+func newConflict(mutation objects.SyncMutation, reason string, current any) *objects.SyncConflict {
+	return &objects.SyncConflict{
+		ClientMutationId: mutation.ClientMutationId,
+		Entity:           mutation.Entity,
+		Id:               mutation.Id,
+		Reason:           reason,
+		Current:          current,
+	}
+}
+
+func encodeCursor(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.Unix(), 10)))
+}
+
+func decodeCursor(cursor string) (time.Time, error) {
+	if len(cursor) == 0 {
+		return time.Time{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, errors.New("invalid sync cursor")
+	}
+	seconds, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("invalid sync cursor")
+	}
+	return time.Unix(seconds, 0), nil
+}
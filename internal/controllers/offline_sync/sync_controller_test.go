@@ -0,0 +1,266 @@
+package offline_sync_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flashcard_service/internal/controllers/apitest"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/pkg/objects"
+	"flashcard_service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+func push(s *apitest.Server, userId string, mutations ...objects.SyncMutation) objects.SyncPushResult {
+	s.T.Helper()
+	rec := s.Request(http.MethodPost, "/api/v1/sync/push", userId, objects.SyncPushRequest{Mutations: mutations})
+	apitest.Expect(s.T, rec, http.StatusOK)
+	return apitest.Decode[objects.SyncPushResult](s.T, rec)
+}
+
+func pull(s *apitest.Server, userId string, cursor string) *httptest.ResponseRecorder {
+	s.T.Helper()
+	return s.Request(http.MethodGet, "/api/v1/sync/changes?since="+url.QueryEscape(cursor), userId, nil)
+}
+
+// cursorAt builds the cursor a pull at t would have returned.
+func cursorAt(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.Unix(), 10)))
+}
+
+// age moves every row of table back to at, out of the safety window.
+func age(s *apitest.Server, table string, at time.Time) {
+	s.Exec("UPDATE "+table+" SET created_at = ?, updated_at = NULL", at.Format(timeFormat))
+}
+
+func TestPullReturnsChangesBehindTheSafetyWindow(t *testing.T) {
+	s := apitest.NewServer(t)
+	verbs := s.CreateCategory("1", "verbs")
+	s.CreateFlashcard("1", verbs.Id, "go", "went")
+
+	// Rows written in the last two seconds wait for the next pull.
+	changes := apitest.Decode[objects.SyncChanges](t, pull(s, "1", ""))
+	if len(changes.Categories) != 0 || len(changes.Flashcards) != 0 {
+		t.Fatalf("changes inside the safety window = %+v", changes)
+	}
+
+	age(s, "flash_category", time.Now().Add(-time.Minute))
+	age(s, "flashcard", time.Now().Add(-time.Minute))
+	changes = apitest.Decode[objects.SyncChanges](t, pull(s, "1", ""))
+	if len(changes.Categories) != 1 || len(changes.Flashcards) != 1 || changes.ResetRequired {
+		t.Fatalf("full sync = %+v", changes)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(changes.Cursor)
+	if err != nil {
+		t.Fatalf("cursor %q is not base64: %v", changes.Cursor, err)
+	}
+	seconds, _ := strconv.ParseInt(string(raw), 10, 64)
+	if lag := time.Since(time.Unix(seconds, 0)); lag < 2*time.Second || lag > 4*time.Second {
+		t.Fatalf("cursor is %v behind now, want the 2s safety window", lag)
+	}
+
+	// A later cursor skips what it already saw; another user sees nothing.
+	if changes := apitest.Decode[objects.SyncChanges](t, pull(s, "1", changes.Cursor)); len(changes.Categories) != 0 || len(changes.Flashcards) != 0 {
+		t.Fatalf("changes after the cursor = %+v", changes)
+	}
+	if changes := apitest.Decode[objects.SyncChanges](t, pull(s, "2", "")); len(changes.Categories) != 0 || len(changes.Flashcards) != 0 {
+		t.Fatalf("user 2 pulls %+v", changes)
+	}
+
+	// Deleted rows come back as tombstones.
+	s.Exec("UPDATE flashcard SET deleted_at = ?, updated_at = ?", time.Now().Add(-30*time.Second).Format(timeFormat), time.Now().Add(-30*time.Second).Format(timeFormat))
+	changes = apitest.Decode[objects.SyncChanges](t, pull(s, "1", cursorAt(time.Now().Add(-45*time.Second))))
+	if len(changes.Categories) != 0 || len(changes.Flashcards) != 1 || changes.Flashcards[0].DeletedAt == nil {
+		t.Fatalf("tombstones = %+v", changes)
+	}
+}
+
+func TestPullWithExpiredOrInvalidCursor(t *testing.T) {
+	s := apitest.NewServer(t)
+	s.CreateCategory("1", "verbs")
+	age(s, "flash_category", time.Now().Add(-time.Minute))
+
+	// Tombstones older than the trash retention may be purged, so an old
+	// cursor gets the full state and is told to reset.
+	changes := apitest.Decode[objects.SyncChanges](t, pull(s, "1", cursorAt(time.Now().Add(-31*24*time.Hour))))
+	if !changes.ResetRequired || len(changes.Categories) != 1 {
+		t.Fatalf("changes for an expired cursor = %+v", changes)
+	}
+
+	apitest.ExpectError(t, pull(s, "1", "not a cursor"), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+	apitest.ExpectError(t, pull(s, "1", base64.RawURLEncoding.EncodeToString([]byte("yesterday"))), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+}
+
+func TestPushCreatesAndMoves(t *testing.T) {
+	s := apitest.NewServer(t)
+
+	result := push(s, "1",
+		objects.SyncMutation{ClientMutationId: "c1", Entity: objects.SyncEntityCategory, Op: objects.SyncOpCreate, Name: "verbs"},
+		objects.SyncMutation{ClientMutationId: "c2", Entity: objects.SyncEntityCategory, Op: objects.SyncOpCreate, Name: "nouns"},
+	)
+	if len(result.Applied) != 2 || len(result.Conflicts) != 0 || result.Applied[0].Version != 1 {
+		t.Fatalf("push = %+v", result)
+	}
+	verbs, nouns := int(result.Applied[0].Id), int(result.Applied[1].Id)
+
+	result = push(s, "1", objects.SyncMutation{ClientMutationId: "f1", Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpCreate, CategoryId: verbs, Name: "go", Content: "went"})
+	if len(result.Applied) != 1 || result.Applied[0].ClientMutationId != "f1" {
+		t.Fatalf("push = %+v", result)
+	}
+	id := result.Applied[0].Id
+	verbsPath := "/api/v1/category/" + strconv.Itoa(verbs) + "/flashcards"
+	nounsPath := "/api/v1/category/" + strconv.Itoa(nouns) + "/flashcards"
+	if flashcards := apitest.Decode[[]model.Flashcard](t, s.Request(http.MethodGet, verbsPath, "1", nil)); len(flashcards) != 1 {
+		t.Fatalf("verbs lists %+v", flashcards)
+	}
+
+	// Moving the card drops it from the cached deck it left.
+	result = push(s, "1", objects.SyncMutation{ClientMutationId: "m1", Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpUpdate, Id: id, Version: 1, CategoryId: nouns, Name: "go", Content: "went"})
+	if len(result.Applied) != 1 || result.Applied[0].Version != 2 {
+		t.Fatalf("move = %+v", result)
+	}
+	if flashcards := apitest.Decode[[]model.Flashcard](t, s.Request(http.MethodGet, verbsPath, "1", nil)); len(flashcards) != 0 {
+		t.Fatalf("verbs still lists %+v", flashcards)
+	}
+	if flashcards := apitest.Decode[[]model.Flashcard](t, s.Request(http.MethodGet, nounsPath, "1", nil)); len(flashcards) != 1 || flashcards[0].ID != id {
+		t.Fatalf("nouns lists %+v", flashcards)
+	}
+
+	// An update without a category keeps the card where it is.
+	result = push(s, "1", objects.SyncMutation{Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpUpdate, Id: id, Version: 2, Name: "go", Content: "gone"})
+	if len(result.Applied) != 1 {
+		t.Fatalf("update = %+v", result)
+	}
+	if flashcards := apitest.Decode[[]model.Flashcard](t, s.Request(http.MethodGet, nounsPath, "1", nil)); len(flashcards) != 1 || flashcards[0].Content != "gone" {
+		t.Fatalf("nouns lists %+v", flashcards)
+	}
+}
+
+func TestPushConflicts(t *testing.T) {
+	s := apitest.NewServer(t)
+	verbs := s.CreateCategory("1", "verbs")
+	flashcard := s.CreateFlashcard("1", verbs.Id, "go", "went")
+	// The server copy was last written a minute ago.
+	age(s, "flashcard", time.Now().Add(-time.Minute))
+
+	before := time.Now().Add(-2 * time.Minute)
+	after := time.Now()
+	update := func(clientMutationId string, version int64, clientUpdatedAt *time.Time) objects.SyncMutation {
+		return objects.SyncMutation{
+			ClientMutationId: clientMutationId,
+			Entity:           objects.SyncEntityFlashcard,
+			Op:               objects.SyncOpUpdate,
+			Id:               flashcard.ID,
+			Version:          version,
+			ClientUpdatedAt:  clientUpdatedAt,
+			Name:             "go",
+			Content:          clientMutationId,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		mutation objects.SyncMutation
+		reason   string
+	}{
+		{"stale version", update("v0", 5, nil), objects.SyncConflictVersionMismatch},
+		{"no version and no client time", update("t0", 0, nil), objects.SyncConflictStale},
+		{"client edit older than the server copy", update("t1", 0, &before), objects.SyncConflictStale},
+		{"missing flashcard", objects.SyncMutation{Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpUpdate, Id: 999, Version: 1, Name: "x"}, objects.SyncConflictNotFound},
+		{"missing category", objects.SyncMutation{Entity: objects.SyncEntityCategory, Op: objects.SyncOpDelete, Id: 999, Version: 1}, objects.SyncConflictNotFound},
+		{"create in a missing category", objects.SyncMutation{Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpCreate, CategoryId: 999, Name: "x"}, objects.SyncConflictNotFound},
+		{"move to a missing category", objects.SyncMutation{Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpUpdate, Id: flashcard.ID, Version: 1, CategoryId: 999, Name: "x"}, objects.SyncConflictNotFound},
+		{"unknown entity", objects.SyncMutation{Entity: "deck", Op: objects.SyncOpCreate, Name: "x"}, objects.SyncConflictInvalid},
+		{"unknown op", objects.SyncMutation{Entity: objects.SyncEntityCategory, Op: "rename", Id: verbs.Id, Name: "x"}, objects.SyncConflictInvalid},
+		{"invalid payload", objects.SyncMutation{Entity: objects.SyncEntityCategory, Op: objects.SyncOpCreate}, objects.SyncConflictInvalid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := push(s, "1", test.mutation)
+			if len(result.Applied) != 0 || len(result.Conflicts) != 1 || result.Conflicts[0].Reason != test.reason {
+				t.Fatalf("push = %+v, want a %s conflict", result, test.reason)
+			}
+		})
+	}
+
+	// A version conflict carries the server copy to merge with.
+	result := push(s, "1", update("v1", 5, nil))
+	current, _ := result.Conflicts[0].Current.(map[string]any)
+	if current["content"] != "went" || current["version"] != float64(1) {
+		t.Fatalf("conflict current = %+v", result.Conflicts[0].Current)
+	}
+
+	// Without a version the newer edit wins.
+	result = push(s, "1", update("t2", 0, &after))
+	if len(result.Applied) != 1 || result.Applied[0].Version != 2 {
+		t.Fatalf("last writer = %+v", result)
+	}
+	// And with the matching version the edit applies.
+	result = push(s, "1", update("v2", 2, nil))
+	if len(result.Applied) != 1 || result.Applied[0].Version != 3 {
+		t.Fatalf("versioned update = %+v", result)
+	}
+
+	result = push(s, "1", objects.SyncMutation{Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpDelete, Id: flashcard.ID, Version: 3})
+	if len(result.Applied) != 1 {
+		t.Fatalf("delete = %+v", result)
+	}
+	apitest.Expect(t, s.Request(http.MethodGet, "/api/v1/category/"+strconv.FormatInt(verbs.Id, 10)+"/flashcards/"+strconv.FormatInt(flashcard.ID, 10), "1", nil), http.StatusNotFound)
+}
+
+func TestPushRejectsLargeBatches(t *testing.T) {
+	s := apitest.NewServer(t)
+	mutations := make([]objects.SyncMutation, 501)
+	rec := s.Request(http.MethodPost, "/api/v1/sync/push", "1", objects.SyncPushRequest{Mutations: mutations})
+	apitest.ExpectError(t, rec, http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+	apitest.ExpectError(t, s.Request(http.MethodPost, "/api/v1/sync/push", "", objects.SyncPushRequest{}), http.StatusBadRequest, utils.ErrValidationFailed.ErrorCode)
+}
+
+func TestPushPublishesStoredRows(t *testing.T) {
+	s := apitest.NewServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	published, closeEvents, err := events.NewPublisher(s.Store).Subscribe(ctx, "1")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer closeEvents()
+
+	result := push(s, "1", objects.SyncMutation{Entity: objects.SyncEntityCategory, Op: objects.SyncOpCreate, Name: "verbs"})
+	push(s, "1", objects.SyncMutation{Entity: objects.SyncEntityFlashcard, Op: objects.SyncOpCreate, CategoryId: int(result.Applied[0].Id), Name: "go", Content: "went"})
+
+	// Events carry the rows as the REST API returns them, timestamps and
+	// version included.
+	seen := make(map[events.EventType]map[string]any)
+	for len(seen) < 2 {
+		select {
+		case payload := <-published:
+			var event struct {
+				Type events.EventType `json:"type"`
+				Data map[string]any   `json:"data"`
+			}
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				t.Fatalf("event = %s", payload)
+			}
+			seen[event.Type] = event.Data
+		case <-time.After(time.Second):
+			t.Fatalf("events = %+v, want category and flashcard created", seen)
+		}
+	}
+	for eventType, data := range seen {
+		if data["createdAt"] == nil || data["version"] != float64(1) || data["userId"] != float64(1) {
+			t.Fatalf("%s data = %+v", eventType, data)
+		}
+	}
+	if seen[events.FlashcardCreated]["content"] != "went" {
+		t.Fatalf("flashcard data = %+v", seen[events.FlashcardCreated])
+	}
+}
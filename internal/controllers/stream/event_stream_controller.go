@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"flashcard_service/internal/events"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const heartbeatInterval = 15 * time.Second
+
+type EventStreamController struct {
+	publisher *events.Publisher
+}
+
+func NewEventStreamController(store cache.Store) *EventStreamController {
+	return &EventStreamController{
+		publisher: events.NewPublisher(store),
+	}
+}
+
+// StreamEvents pushes the category and flashcard changes of the calling user
+// as Server-Sent Events. Events are not buffered, so a client that reconnects
+// should resync before relying on the stream again.
+func (e *EventStreamController) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if len(userId) == 0 {
+		msg := "userid invalid"
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField(constant.UserIdHeader)), errors.New(msg))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		msg := "streaming is not supported by the response writer"
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, errors.New(msg))
+		return
+	}
+
+	messages, closeSubscription, err := e.publisher.Subscribe(r.Context(), userId)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when subscribe to events: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	defer closeSubscription()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event events.Event
+			err := json.Unmarshal([]byte(message), &event)
+			if err != nil {
+				log.Info().Msg("Failed to decode event from Redis: " + err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, message)
+			flusher.Flush()
+		}
+	}
+}
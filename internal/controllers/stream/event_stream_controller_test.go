@@ -0,0 +1,134 @@
+package stream_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flashcard_service/internal/controllers/apitest"
+	"flashcard_service/internal/events"
+	"flashcard_service/pkg/constant"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// frame is one Server-Sent Events frame, the lines up to a blank line.
+type frame []string
+
+func (f frame) field(name string) string {
+	for _, line := range f {
+		if value, ok := strings.CutPrefix(line, name+": "); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// readFrames sends every frame read from body to the returned channel, which
+// is closed when the body ends.
+func readFrames(body *bufio.Reader) <-chan frame {
+	frames := make(chan frame)
+	go func() {
+		defer close(frames)
+		var current frame
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line != "" {
+				current = append(current, line)
+				continue
+			}
+			frames <- current
+			current = nil
+		}
+	}()
+	return frames
+}
+
+func nextFrame(t *testing.T, frames <-chan frame) frame {
+	t.Helper()
+	select {
+	case f, ok := <-frames:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return f
+	case <-time.After(time.Second):
+		t.Fatal("no frame received")
+	}
+	return nil
+}
+
+func TestStreamEventsDeliversTheUsersEvents(t *testing.T) {
+	s := apitest.NewServer(t)
+	returned := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Handler.ServeHTTP(w, r)
+		if r.URL.Path == "/api/v1/events" {
+			close(returned)
+		}
+	}))
+	defer server.Close()
+
+	ctx, disconnect := context.WithCancel(context.Background())
+	defer disconnect()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/events", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set(constant.UserIdHeader, "1")
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, Content-Type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	frames := readFrames(bufio.NewReader(res.Body))
+	if connected := nextFrame(t, frames); len(connected) != 1 || connected[0] != ": connected" {
+		t.Fatalf("first frame = %q, want the connected comment", connected)
+	}
+
+	publisher := events.NewPublisher(s.Store)
+	other := events.NewEvent(events.CategoryCreated, "2", "7", "", nil)
+	own := events.NewEvent(events.CategoryCreated, "1", "8", "", map[string]string{"name": "verbs"})
+	for _, event := range []events.Event{other, own} {
+		err = publisher.Publish(event)
+		if err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	received := nextFrame(t, frames)
+	if received.field("id") != own.Id || received.field("event") != string(events.CategoryCreated) {
+		t.Fatalf("received frame %q, want event %s", received, own.Id)
+	}
+	var event events.Event
+	err = json.Unmarshal([]byte(received.field("data")), &event)
+	if err != nil {
+		t.Fatalf("decode data of %q: %v", received, err)
+	}
+	if event.UserId != "1" || event.CategoryId != "8" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	disconnect()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("stream kept running after the client disconnected")
+	}
+}
+
+func TestStreamEventsRequiresAUser(t *testing.T) {
+	s := apitest.NewServer(t)
+	rec := s.Request(http.MethodGet, "/api/v1/events", "", nil)
+	apitest.ExpectError(t, rec, http.StatusBadRequest, "VALIDATION_FAILED")
+}
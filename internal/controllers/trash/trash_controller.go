@@ -0,0 +1,150 @@
+package trash
+
+import (
+	"database/sql"
+	"errors"
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/repositories_impl"
+	"flashcard_service/pkg/objects"
+	"flashcard_service/pkg/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CategoryType  = "category"
+	FlashcardType = "flashcard"
+)
+
+type TrashController struct {
+	categoryRepo  repositories.CategoryRepository
+	flashcardRepo repositories.FlashcardRepository
+	*category.CategoryService
+}
+
+func NewTrashController(db database.Database, categoryService *category.CategoryService) *TrashController {
+	// The trash is mostly opened right after deleting something, read it
+	// from the primary.
+	primary := db.Primary()
+	return &TrashController{
+		categoryRepo:    repositories_impl.NewCategoryRepositoryImpl(primary),
+		flashcardRepo:   repositories_impl.NewFlashcardRepositoryImpl(primary),
+		CategoryService: categoryService,
+	}
+}
+
+func (t *TrashController) GetTrash(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if t.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	categories, err := t.categoryRepo.FindDeleted(userId)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get deleted categories: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	flashcards, err := t.flashcardRepo.FindDeleted(userId)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get deleted flashcards: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, objects.Trash{
+		Categories: categories,
+		Flashcards: flashcards,
+	})
+}
+
+func (t *TrashController) Restore(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if t.IsUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if len(id) == 0 {
+		msg := "error when restore from trash: id is empty"
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField("id")), errors.New(msg))
+		return
+	}
+
+	switch vars["type"] {
+	case CategoryType:
+		t.restoreCategory(w, r, userId, id, trackingId)
+	case FlashcardType:
+		t.restoreFlashcard(w, r, userId, id, trackingId)
+	default:
+		msg := "error when restore from trash: unknown type " + vars["type"]
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.InvalidField("type")), errors.New(msg))
+		return
+	}
+}
+
+func (t *TrashController) restoreCategory(w http.ResponseWriter, r *http.Request, userId string, id string, trackingId string) {
+	restored, err := t.categoryRepo.RestoreById(userId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrCategoryNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when restore category: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	t.CategoryService.InvalidateCategories(userId, id)
+	t.CategoryService.PublishEvent(events.NewEvent(events.CategoryRestored, userId, id, "", restored))
+	utils.WriteJSON(w, r, http.StatusOK, restored)
+}
+
+func (t *TrashController) restoreFlashcard(w http.ResponseWriter, r *http.Request, userId string, id string, trackingId string) {
+	restored, err := t.flashcardRepo.RestoreById(userId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrFlashcardNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when restore flashcard: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	categoryId := strconv.Itoa(restored.CategoryId)
+	t.CategoryService.InvalidateFlashcards(userId, categoryId)
+	t.CategoryService.PublishEvent(events.NewEvent(events.FlashcardRestored, userId, categoryId, id, restored))
+	utils.WriteJSON(w, r, http.StatusOK, restored)
+}
@@ -0,0 +1,133 @@
+package trash_test
+
+import (
+	"flashcard_service/internal/controllers/apitest"
+	"flashcard_service/internal/jobs"
+	"flashcard_service/internal/model"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/objects"
+	"flashcard_service/pkg/utils"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func categoryPath(id int64) string {
+	return "/api/v1/category/" + strconv.FormatInt(id, 10)
+}
+
+func flashcardPath(categoryId int64, id int64) string {
+	return categoryPath(categoryId) + "/flashcards/" + strconv.FormatInt(id, 10)
+}
+
+func restorePath(kind string, id int64) string {
+	return "/api/v1/trash/" + kind + "/" + strconv.FormatInt(id, 10) + "/restore"
+}
+
+func trash(s *apitest.Server, userId string) objects.Trash {
+	s.T.Helper()
+	rec := s.Request(http.MethodGet, "/api/v1/trash", userId, nil)
+	apitest.Expect(s.T, rec, http.StatusOK)
+	return apitest.Decode[objects.Trash](s.T, rec)
+}
+
+func TestTrashListsAndRestores(t *testing.T) {
+	s := apitest.NewServer(t)
+	verbs := s.CreateCategory("1", "verbs")
+	went := s.CreateFlashcard("1", verbs.Id, "go", "went")
+	nouns := s.CreateCategory("1", "nouns")
+	s.CreateFlashcard("1", nouns.Id, "cat", "cats")
+
+	rec := s.Request(http.MethodDelete, flashcardPath(verbs.Id, went.ID), "1", nil, constant.IfMatchHeader, utils.VersionETag(1))
+	apitest.Expect(t, rec, http.StatusOK)
+	rec = s.Request(http.MethodDelete, categoryPath(nouns.Id), "1", nil, constant.IfMatchHeader, utils.VersionETag(1))
+	apitest.Expect(t, rec, http.StatusOK)
+
+	// Cards trashed with their category come back with it and are not
+	// listed on their own.
+	listed := trash(s, "1")
+	if len(listed.Categories) != 1 || listed.Categories[0].Id != nouns.Id || listed.Categories[0].DeletedAt == nil {
+		t.Fatalf("unexpected trashed categories %+v", listed.Categories)
+	}
+	if len(listed.Flashcards) != 1 || listed.Flashcards[0].ID != went.ID || listed.Flashcards[0].DeletedAt == nil {
+		t.Fatalf("unexpected trashed flashcards %+v", listed.Flashcards)
+	}
+	if other := trash(s, "2"); len(other.Categories) != 0 || len(other.Flashcards) != 0 {
+		t.Fatalf("trash of another user = %+v", other)
+	}
+
+	rec = s.Request(http.MethodPost, restorePath("flashcard", went.ID), "2", nil)
+	apitest.ExpectError(t, rec, http.StatusNotFound, "FLASHCARD_NOT_FOUND")
+	rec = s.Request(http.MethodPost, restorePath("flashcard", went.ID), "1", nil)
+	apitest.Expect(t, rec, http.StatusOK)
+	flashcard := apitest.Decode[model.Flashcard](t, rec)
+	if flashcard.ID != went.ID || flashcard.DeletedAt != nil || flashcard.Version != 3 {
+		t.Fatalf("unexpected restored flashcard %+v", flashcard)
+	}
+	rec = s.Request(http.MethodPost, restorePath("flashcard", went.ID), "1", nil)
+	apitest.ExpectError(t, rec, http.StatusNotFound, "FLASHCARD_NOT_FOUND")
+
+	rec = s.Request(http.MethodPost, restorePath("category", nouns.Id), "1", nil)
+	apitest.Expect(t, rec, http.StatusOK)
+	category := apitest.Decode[model.Category](t, rec)
+	if category.Id != nouns.Id || category.DeletedAt != nil {
+		t.Fatalf("unexpected restored category %+v", category)
+	}
+	rec = s.Request(http.MethodGet, categoryPath(nouns.Id)+"/flashcards", "1", nil)
+	apitest.Expect(t, rec, http.StatusOK)
+	if flashcards := apitest.Decode[[]model.Flashcard](t, rec); len(flashcards) != 1 || flashcards[0].Name != "cat" {
+		t.Fatalf("flashcards of the restored category = %+v", flashcards)
+	}
+	rec = s.Request(http.MethodPost, restorePath("category", nouns.Id), "1", nil)
+	apitest.ExpectError(t, rec, http.StatusNotFound, "CATEGORY_NOT_FOUND")
+
+	listed = trash(s, "1")
+	if len(listed.Categories) != 0 || len(listed.Flashcards) != 0 {
+		t.Fatalf("trash after restoring everything = %+v", listed)
+	}
+
+	rec = s.Request(http.MethodPost, restorePath("webhook", 1), "1", nil)
+	apitest.ExpectError(t, rec, http.StatusBadRequest, "VALIDATION_FAILED")
+}
+
+func TestTrashPurge(t *testing.T) {
+	s := apitest.NewServer(t)
+	verbs := s.CreateCategory("1", "verbs")
+	went := s.CreateFlashcard("1", verbs.Id, "go", "goed")
+	been := s.CreateFlashcard("1", verbs.Id, "be", "been")
+
+	rec := s.Request(http.MethodPut, flashcardPath(verbs.Id, went.ID), "1",
+		map[string]any{"name": "go", "content": "went", "categoryId": verbs.Id}, constant.IfMatchHeader, utils.VersionETag(1))
+	apitest.Expect(t, rec, http.StatusOK)
+	rec = s.Request(http.MethodDelete, flashcardPath(verbs.Id, went.ID), "1", nil, constant.IfMatchHeader, utils.VersionETag(2))
+	apitest.Expect(t, rec, http.StatusOK)
+	rec = s.Request(http.MethodDelete, flashcardPath(verbs.Id, been.ID), "1", nil, constant.IfMatchHeader, utils.VersionETag(1))
+	apitest.Expect(t, rec, http.StatusOK)
+	// Only the card trashed longer than the retention period is purged.
+	s.Exec("UPDATE flashcard SET deleted_at = ? WHERE id = ?",
+		time.Now().AddDate(0, 0, -31).Format("2006-01-02 15:04:05"), went.ID)
+
+	jobs.NewTrashPurgeJob(s.DB).Purge()
+
+	listed := trash(s, "1")
+	if len(listed.Flashcards) != 1 || listed.Flashcards[0].ID != been.ID {
+		t.Fatalf("trash after purge = %+v", listed.Flashcards)
+	}
+	rec = s.Request(http.MethodPost, restorePath("flashcard", went.ID), "1", nil)
+	apitest.ExpectError(t, rec, http.StatusNotFound, "FLASHCARD_NOT_FOUND")
+
+	row, cancel, err := s.DB.QueryRow("SELECT COUNT(*) FROM flashcard_revision WHERE flashcard_id = ?", went.ID)
+	defer cancel()
+	if err != nil {
+		t.Fatalf("count revisions: %v", err)
+	}
+	var revisions int
+	err = row.Scan(&revisions)
+	if err != nil {
+		t.Fatalf("count revisions: %v", err)
+	}
+	if revisions != 0 {
+		t.Fatalf("purge left %d revisions of the purged card", revisions)
+	}
+}
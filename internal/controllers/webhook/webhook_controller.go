@@ -0,0 +1,329 @@
+package webhook
+
+import (
+	"database/sql"
+	"errors"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/internal/webhook"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/repositories_impl"
+	"flashcard_service/pkg/objects"
+	"flashcard_service/pkg/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type WebhookController struct {
+	subscriptionRepo repositories.WebhookSubscriptionRepository
+	deliveryRepo     repositories.WebhookDeliveryRepository
+}
+
+func NewWebhookController(db database.Database) *WebhookController {
+	return &WebhookController{
+		subscriptionRepo: repositories_impl.NewWebhookSubscriptionRepositoryImpl(db),
+		deliveryRepo:     repositories_impl.NewWebhookDeliveryRepositoryImpl(db),
+	}
+}
+
+func (c *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if isUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	var createWebhookRequest objects.CreateWebhook
+	appErr, err := utils.DecodeJSON(w, r, &createWebhookRequest)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse create webhook request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	details := validateWebhook(createWebhookRequest.Url, createWebhookRequest.EventTypes)
+	if len(details) > 0 {
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(details...), errors.New("invalid webhook"))
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when generate webhook secret: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	subscription := model.WebhookSubscription{
+		UserId:     userId,
+		Url:        createWebhookRequest.Url,
+		Secret:     secret,
+		EventTypes: createWebhookRequest.EventTypes,
+		Active:     true,
+	}
+	subscription.Id, err = c.subscriptionRepo.Insert(subscription)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when create webhook: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusCreated, objects.CreatedWebhook{
+		WebhookSubscription: subscription,
+		Secret:              secret,
+	})
+}
+
+func (c *WebhookController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if isUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	subscriptions, err := c.subscriptionRepo.FindAll(userId)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get webhooks: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, subscriptions)
+}
+
+func (c *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if isUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	subscription, err := c.subscriptionRepo.FindOneById(userId, mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrWebhookNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get webhook: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, subscription)
+}
+
+func (c *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if isUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	var updateWebhookRequest objects.UpdateWebhook
+	appErr, err := utils.DecodeJSON(w, r, &updateWebhookRequest)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when parse update webhook request: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, appErr, err)
+		return
+	}
+	details := validateWebhook(updateWebhookRequest.Url, updateWebhookRequest.EventTypes)
+	if len(details) > 0 {
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(details...), errors.New("invalid webhook"))
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	subscription, err := c.subscriptionRepo.FindOneById(userId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrWebhookNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get webhook: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	subscription.Url = updateWebhookRequest.Url
+	subscription.EventTypes = updateWebhookRequest.EventTypes
+	subscription.Active = updateWebhookRequest.Active
+	err = c.subscriptionRepo.UpdateById(userId, id, subscription)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when update webhook: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, subscription)
+}
+
+func (c *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if isUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	err := c.subscriptionRepo.DeleteById(userId, mux.Vars(r)["id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrWebhookNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when delete webhook: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, nil)
+}
+
+// GetWebhookDeliveries returns the most recent deliveries of a subscription,
+// newest first. The page size is taken from the limit query parameter.
+func (c *WebhookController) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if isUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed <= 0 {
+			utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.InvalidField("limit")), errors.New("limit must be a positive integer"))
+			return
+		}
+		limit = min(parsed, maxDeliveryLimit)
+	}
+
+	id := mux.Vars(r)["id"]
+	_, err := c.subscriptionRepo.FindOneById(userId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrWebhookNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get webhook: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	deliveries, err := c.deliveryRepo.FindBySubscriptionId(userId, id, limit)
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when get webhook deliveries: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusOK, deliveries)
+}
+
+// RetryWebhookDelivery puts a delivery, usually a dead one, back in the queue
+// with a fresh attempt budget.
+func (c *WebhookController) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	trackingId := r.Context().Value(constant.TrackingIdContextKey).(string)
+	userId := r.Header.Get(constant.UserIdHeader)
+	if isUserIdInvalid(userId, r, trackingId) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	err := c.deliveryRepo.Requeue(userId, vars["id"], vars["delivery_id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SetHttpReponseError(r, utils.ErrWebhookDeliveryNotFound, err)
+		return
+	}
+	if err != nil {
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", "error when retry webhook delivery: "+err.Error()).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, r, http.StatusAccepted, nil)
+}
+
+// validateWebhook lists what is wrong with a subscription: the url must be an
+// absolute http or https url whose host is not an internal address, and every
+// event type must be known.
+func validateWebhook(rawUrl string, eventTypes []string) []objects.FieldError {
+	details := make([]objects.FieldError, 0)
+	parsed, err := url.Parse(rawUrl)
+	if len(rawUrl) == 0 {
+		details = append(details, utils.RequiredField("url"))
+	} else if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		details = append(details, utils.InvalidField("url"))
+	} else if err := webhook.CheckHost(parsed.Hostname()); errors.Is(err, webhook.ErrForbiddenAddress) {
+		details = append(details, utils.ForbiddenField("url"))
+	} else if err != nil {
+		details = append(details, utils.InvalidField("url"))
+	}
+	if len(eventTypes) == 0 {
+		details = append(details, utils.RequiredField("eventTypes"))
+	}
+	for i, eventType := range eventTypes {
+		if eventType != "*" && !events.IsKnownEventType(eventType) {
+			details = append(details, utils.InvalidField("eventTypes["+strconv.Itoa(i)+"]"))
+		}
+	}
+	return details
+}
+
+func isUserIdInvalid(userId string, r *http.Request, trackingId string) bool {
+	if len(userId) == 0 {
+		msg := "userid invalid"
+		log.Error().
+			Str("trackingId", trackingId).
+			Str("error", msg).
+			Msg("")
+		utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.RequiredField(constant.UserIdHeader)), errors.New(msg))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"flashcard_service/pkg/validation"
+	"testing"
+)
+
+func TestValidateWebhookRejectsInternalHosts(t *testing.T) {
+	urls := []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://10.0.0.1/hook",
+		"http://172.16.0.1/hook",
+		"https://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+		"http://[::1]/hook",
+		"http://[fd00:ec2::254]/hook",
+	}
+	for _, url := range urls {
+		details := validateWebhook(url, []string{"category.created"})
+		if len(details) != 1 || details[0].Field != "url" || details[0].Code != validation.Forbidden {
+			t.Errorf("validateWebhook(%q) = %+v, want url forbidden", url, details)
+		}
+	}
+
+	if details := validateWebhook("https://93.184.216.34/hook", []string{"category.created"}); len(details) != 0 {
+		t.Fatalf("validateWebhook(public) = %+v", details)
+	}
+}
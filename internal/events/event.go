@@ -0,0 +1,62 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	CategoryCreated   EventType = "category.created"
+	CategoryUpdated   EventType = "category.updated"
+	CategoryDeleted   EventType = "category.deleted"
+	CategoryRestored  EventType = "category.restored"
+	FlashcardCreated  EventType = "flashcard.created"
+	FlashcardUpdated  EventType = "flashcard.updated"
+	FlashcardDeleted  EventType = "flashcard.deleted"
+	FlashcardRestored EventType = "flashcard.restored"
+)
+
+var EventTypes = []EventType{
+	CategoryCreated,
+	CategoryUpdated,
+	CategoryDeleted,
+	CategoryRestored,
+	FlashcardCreated,
+	FlashcardUpdated,
+	FlashcardDeleted,
+	FlashcardRestored,
+}
+
+func IsKnownEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if string(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	Id          string    `json:"id"`
+	Type        EventType `json:"type"`
+	UserId      string    `json:"userId"`
+	CategoryId  string    `json:"categoryId,omitempty"`
+	FlashcardId string    `json:"flashcardId,omitempty"`
+	Data        any       `json:"data,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+func NewEvent(eventType EventType, userId string, categoryId string, flashcardId string, data any) Event {
+	return Event{
+		Id:          uuid.New().String(),
+		Type:        eventType,
+		UserId:      userId,
+		CategoryId:  categoryId,
+		FlashcardId: flashcardId,
+		Data:        data,
+		OccurredAt:  time.Now(),
+	}
+}
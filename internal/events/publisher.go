@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/database/redis"
+)
+
+// Publisher fans deck changes out to every instance through Redis Pub/Sub,
+// one channel per user.
+type Publisher struct {
+	store cache.Store
+}
+
+func NewPublisher(store cache.Store) *Publisher {
+	return &Publisher{
+		store: store,
+	}
+}
+
+func (p *Publisher) Publish(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.store.Publish(redis.GetUserEventsChannel(event.UserId), string(payload))
+}
+
+// Subscribe streams the events of one user until the returned close function
+// is called.
+func (p *Publisher) Subscribe(ctx context.Context, userId string) (<-chan string, func() error, error) {
+	return p.store.Subscribe(ctx, redis.GetUserEventsChannel(userId))
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"flashcard_service/pkg/database/redis"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	streamReadCount = 50
+	streamReadBlock = 5 * time.Second
+	streamClaimIdle = time.Minute
+)
+
+// StreamConsumer reads the domain events stream as one member of a consumer
+// group. A message is acknowledged only after handle returns nil; messages a
+// crashed member left unacknowledged are claimed once they have been idle for
+// a minute, so handle must be idempotent on Event.Id.
+type StreamConsumer struct {
+	redis    *redis.RedisDatabase
+	group    string
+	consumer string
+}
+
+func NewStreamConsumer(redis *redis.RedisDatabase, group string, consumer string) *StreamConsumer {
+	return &StreamConsumer{
+		redis:    redis,
+		group:    group,
+		consumer: consumer,
+	}
+}
+
+// Run consumes events until ctx is done.
+func (s *StreamConsumer) Run(ctx context.Context, handle func(event Event) error) error {
+	stream := redis.GetDomainEventsStreamKey()
+	err := s.redis.XGroupCreate(stream, s.group)
+	if err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		claimed, err := s.redis.XAutoClaim(stream, s.group, s.consumer, streamClaimIdle, streamReadCount)
+		if err != nil {
+			log.Error().Str("error", "error when claim pending events: "+err.Error()).Msg("")
+		}
+		s.handleMessages(stream, claimed, handle)
+
+		messages, err := s.redis.XReadGroup(stream, s.group, s.consumer, streamReadCount, streamReadBlock)
+		if err != nil {
+			log.Error().Str("error", "error when read events: "+err.Error()).Msg("")
+			time.Sleep(time.Second)
+			continue
+		}
+		s.handleMessages(stream, messages, handle)
+	}
+	return ctx.Err()
+}
+
+func (s *StreamConsumer) handleMessages(stream string, messages []redis.StreamMessage, handle func(event Event) error) {
+	for _, message := range messages {
+		payload, _ := message.Values["payload"].(string)
+		var event Event
+		err := json.Unmarshal([]byte(payload), &event)
+		if err != nil {
+			// A payload that cannot be decoded never will be, acknowledge it so
+			// it does not block the group.
+			log.Error().Str("messageId", message.Id).Str("error", "error when decode event: "+err.Error()).Msg("")
+		} else {
+			err = handle(event)
+			if err != nil {
+				log.Error().Str("eventId", event.Id).Str("error", "error when handle event: "+err.Error()).Msg("")
+				continue
+			}
+		}
+
+		err = s.redis.XAck(stream, s.group, message.Id)
+		if err != nil {
+			log.Error().Str("messageId", message.Id).Str("error", "error when ack event: "+err.Error()).Msg("")
+		}
+	}
+}
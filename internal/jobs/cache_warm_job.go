@@ -0,0 +1,342 @@
+package jobs
+
+import (
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/database/repositories_impl"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CacheDriftMissing = "missing"
+	CacheDriftExtra   = "extra"
+	CacheDriftStale   = "stale"
+)
+
+type CacheWarmOptions struct {
+	ActiveWithin time.Duration
+	MaxUsers     int
+	Concurrency  int
+}
+
+// CacheWarmReport counts the users visited and what was written for them:
+// the categories of the category lists filled, and the decks filled.
+type CacheWarmReport struct {
+	Users      int
+	Categories int
+	Decks      int
+	Failed     int
+}
+
+// CacheDrift is one cached entry that does not match the database. Field is
+// the category or flashcard id inside the hash at Key.
+type CacheDrift struct {
+	UserId string
+	Key    string
+	Field  string
+	Kind   string
+}
+
+type CacheVerifyReport struct {
+	Users       int
+	Keys        int
+	MissingKeys int
+	Failed      int
+	Drifts      []CacheDrift
+}
+
+// CacheWarmJob fills the category and flashcard caches of recently active
+// users, so that after a Redis flush or failover their first requests do not
+// all fall through to MySQL at once. The background loop notices a flush by
+// the warm marker key disappearing; only the instance that sets it again
+// warms.
+type CacheWarmJob struct {
+	categoryRepo  repositories.CategoryRepository
+	flashcardRepo repositories.FlashcardRepository
+	// Verify compares with the primary so replica lag is not reported as
+	// drift.
+	primaryCategoryRepo  repositories.CategoryRepository
+	primaryFlashcardRepo repositories.FlashcardRepository
+	categoryService      *category.CategoryService
+	store                cache.Store
+	options              CacheWarmOptions
+	interval             time.Duration
+}
+
+func CacheWarmOptionsFromEnv() CacheWarmOptions {
+	activeWithinHours, err := strconv.Atoi(os.Getenv("CACHE_WARM_ACTIVE_WITHIN_HOURS"))
+	if err != nil || activeWithinHours <= 0 {
+		activeWithinHours = 24
+	}
+	maxUsers, err := strconv.Atoi(os.Getenv("CACHE_WARM_MAX_USERS"))
+	if err != nil || maxUsers <= 0 {
+		maxUsers = 10000
+	}
+	concurrency, err := strconv.Atoi(os.Getenv("CACHE_WARM_CONCURRENCY"))
+	if err != nil || concurrency <= 0 {
+		concurrency = 8
+	}
+	return CacheWarmOptions{
+		ActiveWithin: time.Duration(activeWithinHours) * time.Hour,
+		MaxUsers:     maxUsers,
+		Concurrency:  concurrency,
+	}
+}
+
+func NewCacheWarmJob(db database.Database, store cache.Store, categoryService *category.CategoryService, options CacheWarmOptions) *CacheWarmJob {
+	intervalSeconds, err := strconv.Atoi(os.Getenv("CACHE_WARM_CHECK_INTERVAL_BY_SECOND"))
+	if err != nil || intervalSeconds <= 0 {
+		intervalSeconds = 30
+	}
+	return &CacheWarmJob{
+		categoryRepo:  repositories_impl.NewCategoryRepositoryImpl(db),
+		flashcardRepo: repositories_impl.NewFlashcardRepositoryImpl(db),
+
+		primaryCategoryRepo:  repositories_impl.NewCategoryRepositoryImpl(db.Primary()),
+		primaryFlashcardRepo: repositories_impl.NewFlashcardRepositoryImpl(db.Primary()),
+		categoryService:      categoryService,
+		store:                store,
+		options:              options,
+		interval:             time.Duration(intervalSeconds) * time.Second,
+	}
+}
+
+func (j *CacheWarmJob) Start() {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			j.warmIfFlushed()
+			<-ticker.C
+		}
+	}()
+}
+
+func (j *CacheWarmJob) warmIfFlushed() {
+	claimed, err := j.store.SetNX(redis.GetCacheWarmedKey(), time.Now().Format(time.RFC3339), 0)
+	if err != nil || !claimed {
+		return
+	}
+	log.Info().Msg("Cache warm marker missing, warming cache")
+	report, err := j.Warm()
+	if err != nil {
+		log.Error().Str("error", "error when warm cache: "+err.Error()).Msg("")
+		return
+	}
+	log.Info().
+		Int("users", report.Users).
+		Int("categories", report.Categories).
+		Int("decks", report.Decks).
+		Int("failed", report.Failed).
+		Msg("Warmed cache")
+}
+
+// Warm writes the category list and every deck of each recently active user
+// that is missing from Redis. It fills Redis directly rather than reading
+// through the category service, whose local tier outlives a Redis flush and
+// would answer the reads without writing anything back.
+func (j *CacheWarmJob) Warm() (CacheWarmReport, error) {
+	var mu sync.Mutex
+	var report CacheWarmReport
+	users, failed, err := j.forEachActiveUser(func(userId string) error {
+		// A replica may not have the user's last write yet; their own next
+		// read fills the cache from the primary instead.
+		if j.categoryService.WroteRecently(userId) {
+			return nil
+		}
+		categories, decks, err := j.warmUser(userId)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		report.Categories += categories
+		report.Decks += decks
+		mu.Unlock()
+		return nil
+	})
+	report.Users = users
+	report.Failed = failed
+	return report, err
+}
+
+// warmUser fills the missing lists of one user and returns how many
+// categories and decks it wrote. Everything is loaded at the cache generation
+// read first, so a write of the user during the warm keeps its invalidation.
+func (j *CacheWarmJob) warmUser(userId string) (int, int, error) {
+	generation, err := j.store.GetGeneration(redis.GetCacheGenerationKey(userId))
+	if err != nil {
+		return 0, 0, err
+	}
+	categories, err := j.categoryRepo.FindAll(userId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	warmedCategories := 0
+	cached, err := j.store.Exists(redis.GetCategoriesKey(userId))
+	if err != nil {
+		return 0, 0, err
+	}
+	if !cached {
+		err = j.categoryService.SaveCategoriesToRedisHash(userId, generation, categories)
+		if err != nil {
+			return 0, 0, err
+		}
+		warmedCategories = len(categories)
+	}
+
+	decks := 0
+	for _, c := range categories {
+		categoryId := strconv.FormatInt(c.Id, 10)
+		cached, err = j.store.Exists(redis.GetFlashcardsKey(userId, categoryId))
+		if err != nil {
+			return warmedCategories, decks, err
+		}
+		if cached {
+			continue
+		}
+		flashcards, err := j.flashcardRepo.FindByCategoryId(userId, categoryId)
+		if err != nil {
+			return warmedCategories, decks, err
+		}
+		err = j.categoryService.SaveFlashcardsToRedisHash(userId, categoryId, generation, flashcards)
+		if err != nil {
+			return warmedCategories, decks, err
+		}
+		decks++
+	}
+	return warmedCategories, decks, nil
+}
+
+// Verify compares the cached lists of each recently active user with the
+// database and reports every entry that differs. Lists that are not cached
+// are counted but are not drift.
+func (j *CacheWarmJob) Verify() (CacheVerifyReport, error) {
+	var mu sync.Mutex
+	var report CacheVerifyReport
+	users, failed, err := j.forEachActiveUser(func(userId string) error {
+		drifts := make([]CacheDrift, 0)
+		keys, missingKeys := 0, 0
+
+		categories, err := j.primaryCategoryRepo.FindAll(userId)
+		if err != nil {
+			return err
+		}
+		cachedCategories, found, err := j.categoryService.GetCategoriesFromRedisHash(userId)
+		if err != nil {
+			return err
+		}
+		keys++
+		if found {
+			drifts = append(drifts, compareCached(userId, redis.GetCategoriesKey(userId), cachedCategories, categories,
+				func(c model.Category) int64 { return c.Id },
+				func(a, b model.Category) bool { return a.Name == b.Name && a.Version == b.Version },
+			)...)
+		} else {
+			missingKeys++
+		}
+
+		for _, c := range categories {
+			categoryId := strconv.FormatInt(c.Id, 10)
+			flashcards, err := j.primaryFlashcardRepo.FindByCategoryId(userId, categoryId)
+			if err != nil {
+				return err
+			}
+			cachedFlashcards, found, err := j.categoryService.GetFlashcardsFromRedisHash(userId, categoryId)
+			if err != nil {
+				return err
+			}
+			keys++
+			if !found {
+				missingKeys++
+				continue
+			}
+			drifts = append(drifts, compareCached(userId, redis.GetFlashcardsKey(userId, categoryId), cachedFlashcards, flashcards,
+				func(f model.Flashcard) int64 { return f.ID },
+				func(a, b model.Flashcard) bool {
+					return a.Name == b.Name && a.Content == b.Content && a.CategoryId == b.CategoryId && a.Version == b.Version
+				},
+			)...)
+		}
+
+		mu.Lock()
+		report.Keys += keys
+		report.MissingKeys += missingKeys
+		report.Drifts = append(report.Drifts, drifts...)
+		mu.Unlock()
+		return nil
+	})
+	report.Users = users
+	report.Failed = failed
+	return report, err
+}
+
+// forEachActiveUser runs fn for every recently active user on at most
+// Concurrency goroutines. It returns how many users were visited and for how
+// many fn failed.
+func (j *CacheWarmJob) forEachActiveUser(fn func(userId string) error) (int, int, error) {
+	userIds, err := j.categoryRepo.FindActiveUserIds(time.Now().Add(-j.options.ActiveWithin), j.options.MaxUsers)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var mu sync.Mutex
+	failed := 0
+	userIdChan := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < j.options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for userId := range userIdChan {
+				err := fn(userId)
+				if err != nil {
+					log.Error().Str("userId", userId).Str("error", "error when process cache of user: "+err.Error()).Msg("")
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, userId := range userIds {
+		userIdChan <- userId
+	}
+	close(userIdChan)
+	wg.Wait()
+	return len(userIds), failed, nil
+}
+
+func compareCached[T any](userId string, key string, cached []T, stored []T, id func(T) int64, equal func(a, b T) bool) []CacheDrift {
+	drifts := make([]CacheDrift, 0)
+	storedById := make(map[int64]T, len(stored))
+	for _, item := range stored {
+		storedById[id(item)] = item
+	}
+	for _, item := range cached {
+		field := strconv.FormatInt(id(item), 10)
+		current, ok := storedById[id(item)]
+		if !ok {
+			drifts = append(drifts, CacheDrift{UserId: userId, Key: key, Field: field, Kind: CacheDriftExtra})
+			continue
+		}
+		delete(storedById, id(item))
+		if !equal(item, current) {
+			drifts = append(drifts, CacheDrift{UserId: userId, Key: key, Field: field, Kind: CacheDriftStale})
+		}
+	}
+	for itemId := range storedById {
+		drifts = append(drifts, CacheDrift{UserId: userId, Key: key, Field: strconv.FormatInt(itemId, 10), Kind: CacheDriftMissing})
+	}
+	return drifts
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/worker"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/database/repositories_impl"
+	"flashcard_service/pkg/database/sqlite"
+	"flashcard_service/pkg/objects"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCompareCachedReportsDrift(t *testing.T) {
+	cached := []model.Category{
+		{Id: 1, Name: "same", Version: 1},
+		{Id: 2, Name: "old", Version: 1},
+		{Id: 3, Name: "deleted", Version: 1},
+	}
+	stored := []model.Category{
+		{Id: 1, Name: "same", Version: 1},
+		{Id: 2, Name: "new", Version: 2},
+		{Id: 4, Name: "created", Version: 1},
+	}
+
+	drifts := compareCached("7", "category:{7}", cached, stored,
+		func(c model.Category) int64 { return c.Id },
+		func(a, b model.Category) bool { return a.Name == b.Name && a.Version == b.Version },
+	)
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Field < drifts[j].Field })
+
+	want := []CacheDrift{
+		{UserId: "7", Key: "category:{7}", Field: "2", Kind: CacheDriftStale},
+		{UserId: "7", Key: "category:{7}", Field: "3", Kind: CacheDriftExtra},
+		{UserId: "7", Key: "category:{7}", Field: "4", Kind: CacheDriftMissing},
+	}
+	if len(drifts) != len(want) {
+		t.Fatalf("drifts = %+v, want %+v", drifts, want)
+	}
+	for i := range want {
+		if drifts[i] != want[i] {
+			t.Fatalf("drifts[%d] = %+v, want %+v", i, drifts[i], want[i])
+		}
+	}
+}
+
+func TestWarmRefillsRedisBehindTheLocalTier(t *testing.T) {
+	db := sqlite.NewSQLiteWithPath(":memory:")
+	err := db.Connect()
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	store := cache.NewMemoryStore()
+	queue := worker.NewQueue(2, 100, 3, time.Millisecond)
+	t.Cleanup(func() {
+		queue.Close()
+		db.Close()
+	})
+	service := category.NewCategoryService(store, queue)
+	job := NewCacheWarmJob(db, store, service, CacheWarmOptions{ActiveWithin: time.Hour, MaxUsers: 10, Concurrency: 2})
+
+	categories := repositories_impl.NewCategoryRepositoryImpl(db)
+	flashcards := repositories_impl.NewFlashcardRepositoryImpl(db)
+	categoryIds := make([]string, 0, 2)
+	for _, name := range []string{"verbs", "nouns"} {
+		id, err := categories.Insert("1", name)
+		if err != nil {
+			t.Fatalf("insert category: %v", err)
+		}
+		categoryIds = append(categoryIds, strconv.FormatInt(id, 10))
+	}
+	_, err = flashcards.Insert("1", categoryIds[0], objects.CreateFlashcard{Name: "go", Content: "went"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+
+	report, err := job.Warm()
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if report.Users != 1 || report.Categories != 2 || report.Decks != 2 {
+		t.Fatalf("first warm = %+v", report)
+	}
+	// Serving the lists puts them in the local tier of this instance.
+	_, err = service.GetCategories("1", func() ([]model.Category, error) { return categories.FindAll("1") })
+	if err != nil {
+		t.Fatalf("read categories: %v", err)
+	}
+	for _, categoryId := range categoryIds {
+		_, err = service.GetFlashcards("1", categoryId, func() ([]model.Flashcard, error) { return flashcards.FindByCategoryId("1", categoryId) })
+		if err != nil {
+			t.Fatalf("read flashcards: %v", err)
+		}
+	}
+
+	report, err = job.Warm()
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if report.Categories != 0 || report.Decks != 0 {
+		t.Fatalf("warm rewrote cached lists: %+v", report)
+	}
+
+	// A flush empties Redis but not the local tier.
+	for _, key := range []string{redis.GetCategoriesKey("1"), redis.GetFlashcardsKey("1", categoryIds[0]), redis.GetFlashcardsKey("1", categoryIds[1])} {
+		err = store.Del(key)
+		if err != nil {
+			t.Fatalf("flush %s: %v", key, err)
+		}
+	}
+	report, err = job.Warm()
+	if err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	if report.Categories != 2 || report.Decks != 2 {
+		t.Fatalf("warm after flush = %+v", report)
+	}
+	cached, found, err := service.GetCategoriesFromRedisHash("1")
+	if err != nil || !found || len(cached) != 2 {
+		t.Fatalf("cached categories = %+v, found %v, err %v", cached, found, err)
+	}
+	deck, found, err := service.GetFlashcardsFromRedisHash("1", categoryIds[0])
+	if err != nil || !found || len(deck) != 1 || deck[0].Name != "go" {
+		t.Fatalf("cached deck = %+v, found %v, err %v", deck, found, err)
+	}
+	_, found, err = service.GetFlashcardsFromRedisHash("1", categoryIds[1])
+	if err != nil || !found {
+		t.Fatalf("empty deck cached %v, err %v", found, err)
+	}
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/database/repositories_impl"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// outboxCleanupInterval is how often the relay deletes expired entries.
+const outboxCleanupInterval = time.Hour
+
+// OutboxRelayJob publishes outbox entries to the domain events stream. An
+// entry is only marked published after XADD succeeded, so a crash between the
+// two publishes it again and consumers must tolerate duplicates by event_id.
+// Published entries are kept for the retention period so they can be
+// replayed, then deleted.
+type OutboxRelayJob struct {
+	outboxRepo repositories.OutboxRepository
+	redis      *redis.RedisDatabase
+	interval   time.Duration
+	batchSize  int
+	maxLen     int64
+	groups     []string
+	retention  time.Duration
+	cleanedAt  time.Time
+}
+
+func NewOutboxRelayJob(db database.Database, redis *redis.RedisDatabase) *OutboxRelayJob {
+	intervalMilliseconds, err := strconv.Atoi(os.Getenv("OUTBOX_RELAY_INTERVAL_BY_MILLISECOND"))
+	if err != nil || intervalMilliseconds <= 0 {
+		intervalMilliseconds = 1000
+	}
+	batchSize, err := strconv.Atoi(os.Getenv("OUTBOX_RELAY_BATCH_SIZE"))
+	if err != nil || batchSize <= 0 {
+		batchSize = 100
+	}
+	maxLen, err := strconv.ParseInt(os.Getenv("OUTBOX_STREAM_MAX_LENGTH"), 10, 64)
+	if err != nil || maxLen <= 0 {
+		maxLen = 100000
+	}
+	retentionHours, err := strconv.Atoi(os.Getenv("OUTBOX_RETENTION_HOURS"))
+	if err != nil || retentionHours <= 0 {
+		retentionHours = 168
+	}
+	groups := make([]string, 0)
+	for _, group := range strings.Split(os.Getenv("OUTBOX_STREAM_CONSUMER_GROUPS"), ",") {
+		group = strings.TrimSpace(group)
+		if group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return &OutboxRelayJob{
+		outboxRepo: repositories_impl.NewOutboxRepositoryImpl(db),
+		redis:      redis,
+		interval:   time.Duration(intervalMilliseconds) * time.Millisecond,
+		batchSize:  batchSize,
+		maxLen:     maxLen,
+		groups:     groups,
+		retention:  time.Duration(retentionHours) * time.Hour,
+	}
+}
+
+func (j *OutboxRelayJob) Start() {
+	for _, group := range j.groups {
+		err := j.redis.XGroupCreate(redis.GetDomainEventsStreamKey(), group)
+		if err != nil {
+			log.Error().Str("error", "error when create consumer group "+group+": "+err.Error()).Msg("")
+		}
+	}
+
+	go func() {
+		for {
+			published, err := j.Relay()
+			if err != nil {
+				log.Error().Str("error", "error when relay outbox: "+err.Error()).Msg("")
+			}
+			// A full batch means there is probably more waiting.
+			if err == nil && published == j.batchSize {
+				continue
+			}
+			if time.Since(j.cleanedAt) >= outboxCleanupInterval {
+				j.Cleanup()
+			}
+			time.Sleep(j.interval)
+		}
+	}()
+}
+
+// Relay publishes one batch of pending entries and returns how many were sent.
+func (j *OutboxRelayJob) Relay() (int, error) {
+	return j.outboxRepo.PublishPending(j.batchSize, j.publish)
+}
+
+// Cleanup deletes the entries published longer ago than the retention period.
+func (j *OutboxRelayJob) Cleanup() {
+	j.cleanedAt = time.Now()
+	deleted, err := j.outboxRepo.DeletePublishedBefore(j.cleanedAt.Add(-j.retention))
+	if err != nil {
+		log.Error().Str("error", "error when delete published outbox entries: "+err.Error()).Msg("")
+		return
+	}
+	log.Info().Int64("entries", deleted).Msg("Deleted published outbox entries")
+}
+
+// Replay publishes the entries with ids in [fromId, toId] again, whether or
+// not they were published before. Entries deleted by Cleanup are skipped.
+func (j *OutboxRelayJob) Replay(fromId int64, toId int64) (int, error) {
+	replayed := 0
+	for fromId <= toId {
+		entries, err := j.outboxRepo.FindRange(fromId, toId, j.batchSize)
+		if err != nil {
+			return replayed, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		err = j.publish(entries)
+		if err != nil {
+			return replayed, err
+		}
+		replayed += len(entries)
+		fromId = entries[len(entries)-1].Id + 1
+	}
+	return replayed, nil
+}
+
+func (j *OutboxRelayJob) publish(entries []model.OutboxEntry) error {
+	for _, entry := range entries {
+		_, err := j.redis.XAdd(redis.GetDomainEventsStreamKey(), j.maxLen, map[string]any{
+			"outbox_id":  entry.Id,
+			"event_id":   entry.EventId,
+			"event_type": entry.EventType,
+			"user_id":    entry.UserId,
+			"payload":    entry.Payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
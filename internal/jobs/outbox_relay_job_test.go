@@ -0,0 +1,173 @@
+package jobs
+
+import (
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/database/repositories_impl"
+	"flashcard_service/pkg/database/sqlite"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestOutboxRelayJob(t *testing.T) (*OutboxRelayJob, database.Database, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	t.Setenv("REDIS_URL", server.Addr())
+	t.Setenv("REDIS_PASSWORD", "")
+	t.Setenv("REDIS_DB", "0")
+	t.Setenv("OUTBOX_RELAY_BATCH_SIZE", "2")
+	t.Setenv("OUTBOX_RETENTION_HOURS", "24")
+
+	r := redis.NewRedisClient()
+	err := r.Connect()
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	db := sqlite.NewSQLiteWithPath(":memory:")
+	err = db.Connect()
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+		db.Close()
+	})
+	return NewOutboxRelayJob(db, r), db, server
+}
+
+// streamEventIds returns the event_id of every entry of the domain events
+// stream, oldest first.
+func streamEventIds(t *testing.T, server *miniredis.Miniredis) []string {
+	t.Helper()
+	entries, err := server.Stream(redis.GetDomainEventsStreamKey())
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		for i := 0; i+1 < len(entry.Values); i += 2 {
+			if entry.Values[i] == "event_id" {
+				ids = append(ids, entry.Values[i+1])
+			}
+		}
+	}
+	return ids
+}
+
+func insertCategories(t *testing.T, db database.Database, names ...string) {
+	t.Helper()
+	categories := repositories_impl.NewCategoryRepositoryImpl(db)
+	for _, name := range names {
+		_, err := categories.Insert("1", name)
+		if err != nil {
+			t.Fatalf("insert category: %v", err)
+		}
+	}
+}
+
+func outboxEntries(t *testing.T, outbox repositories.OutboxRepository) []string {
+	t.Helper()
+	entries, err := outbox.FindRange(0, math.MaxInt64, 100)
+	if err != nil {
+		t.Fatalf("find outbox entries: %v", err)
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.EventId)
+	}
+	return ids
+}
+
+func TestRelayPublishesEachEntryOnce(t *testing.T) {
+	job, db, server := newTestOutboxRelayJob(t)
+	insertCategories(t, db, "verbs", "nouns", "adjectives")
+
+	for _, want := range []int{2, 1, 0} {
+		published, err := job.Relay()
+		if err != nil {
+			t.Fatalf("relay: %v", err)
+		}
+		if published != want {
+			t.Fatalf("relayed %d entries, want %d", published, want)
+		}
+	}
+
+	stored := outboxEntries(t, job.outboxRepo)
+	if len(stored) != 3 {
+		t.Fatalf("outbox holds %d entries, want 3", len(stored))
+	}
+	if relayed := streamEventIds(t, server); !slices.Equal(relayed, stored) {
+		t.Fatalf("stream holds %v, want %v", relayed, stored)
+	}
+}
+
+func TestReplayPublishesTheRangeAgain(t *testing.T) {
+	job, db, server := newTestOutboxRelayJob(t)
+	insertCategories(t, db, "verbs", "nouns", "adjectives")
+	_, err := job.Relay()
+	if err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+
+	entries, err := job.outboxRepo.FindRange(0, math.MaxInt64, 100)
+	if err != nil {
+		t.Fatalf("find outbox entries: %v", err)
+	}
+	// The last entry was never relayed, Replay publishes it all the same.
+	replayed, err := job.Replay(entries[1].Id, entries[2].Id)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed != 2 {
+		t.Fatalf("replayed %d entries, want 2", replayed)
+	}
+	want := []string{entries[0].EventId, entries[1].EventId, entries[1].EventId, entries[2].EventId}
+	if relayed := streamEventIds(t, server); !slices.Equal(relayed, want) {
+		t.Fatalf("stream holds %v, want %v", relayed, want)
+	}
+
+	replayed, err = job.Replay(entries[2].Id+1, entries[2].Id+10)
+	if err != nil || replayed != 0 {
+		t.Fatalf("replay past the last entry: replayed %d, err %v", replayed, err)
+	}
+}
+
+func TestCleanupDeletesExpiredPublishedEntries(t *testing.T) {
+	job, db, _ := newTestOutboxRelayJob(t)
+	insertCategories(t, db, "verbs", "nouns")
+	_, err := job.Relay()
+	if err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	insertCategories(t, db, "adjectives")
+	entries, err := job.outboxRepo.FindRange(0, math.MaxInt64, 100)
+	if err != nil {
+		t.Fatalf("find outbox entries: %v", err)
+	}
+
+	// Only the first entry was published longer ago than the retention
+	// period; the last one is old but still pending.
+	old := time.Now().Add(-25 * time.Hour).Format("2006-01-02 15:04:05")
+	_, cancel, err := db.Exec("UPDATE outbox SET published_at = ? WHERE id = ?", old, entries[0].Id)
+	cancel()
+	if err != nil {
+		t.Fatalf("age published entry: %v", err)
+	}
+	_, cancel, err = db.Exec("UPDATE outbox SET created_at = ? WHERE id = ?", old, entries[2].Id)
+	cancel()
+	if err != nil {
+		t.Fatalf("age pending entry: %v", err)
+	}
+
+	job.Cleanup()
+
+	want := []string{entries[1].EventId, entries[2].EventId}
+	if stored := outboxEntries(t, job.outboxRepo); !slices.Equal(stored, want) {
+		t.Fatalf("outbox holds %v, want %v", stored, want)
+	}
+}
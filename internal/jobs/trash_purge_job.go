@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/repositories_impl"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TrashPurgeJob permanently removes trashed categories and flashcards once
+// they have been in the trash longer than the retention period.
+type TrashPurgeJob struct {
+	categoryRepo  repositories.CategoryRepository
+	flashcardRepo repositories.FlashcardRepository
+	retention     time.Duration
+	interval      time.Duration
+}
+
+func NewTrashPurgeJob(db database.Database) *TrashPurgeJob {
+	retentionDays, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || retentionDays <= 0 {
+		retentionDays = 30
+	}
+	intervalMinutes, err := strconv.Atoi(os.Getenv("TRASH_PURGE_INTERVAL_BY_MINUTE"))
+	if err != nil || intervalMinutes <= 0 {
+		intervalMinutes = 60
+	}
+	return &TrashPurgeJob{
+		categoryRepo:  repositories_impl.NewCategoryRepositoryImpl(db),
+		flashcardRepo: repositories_impl.NewFlashcardRepositoryImpl(db),
+		retention:     time.Duration(retentionDays) * 24 * time.Hour,
+		interval:      time.Duration(intervalMinutes) * time.Minute,
+	}
+}
+
+func (j *TrashPurgeJob) Start() {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			j.Purge()
+			<-ticker.C
+		}
+	}()
+}
+
+func (j *TrashPurgeJob) Purge() {
+	before := time.Now().Add(-j.retention)
+
+	flashcards, err := j.flashcardRepo.PurgeDeletedBefore(before)
+	if err != nil {
+		log.Error().Str("error", "error when purge deleted flashcards: "+err.Error()).Msg("")
+		return
+	}
+	categories, err := j.categoryRepo.PurgeDeletedBefore(before)
+	if err != nil {
+		log.Error().Str("error", "error when purge deleted categories: "+err.Error()).Msg("")
+		return
+	}
+	log.Info().
+		Int64("flashcards", flashcards).
+		Int64("categories", categories).
+		Msg("Purged trash")
+}
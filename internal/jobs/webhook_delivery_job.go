@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"database/sql"
+	"errors"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/internal/webhook"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/repositories_impl"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// WebhookDeliveryJob sends queued webhook deliveries. A failed attempt is
+// retried with exponential backoff until maxAttempts, after which the delivery
+// is left in the dead state until it is requeued through the API.
+type WebhookDeliveryJob struct {
+	subscriptionRepo repositories.WebhookSubscriptionRepository
+	deliveryRepo     repositories.WebhookDeliveryRepository
+	sender           *webhook.Sender
+	interval         time.Duration
+	batchSize        int
+	timeout          time.Duration
+	maxAttempts      int
+	retryBase        time.Duration
+	retryMax         time.Duration
+}
+
+func NewWebhookDeliveryJob(db database.Database) *WebhookDeliveryJob {
+	intervalMilliseconds, err := strconv.Atoi(os.Getenv("WEBHOOK_DELIVERY_INTERVAL_BY_MILLISECOND"))
+	if err != nil || intervalMilliseconds <= 0 {
+		intervalMilliseconds = 1000
+	}
+	batchSize, err := strconv.Atoi(os.Getenv("WEBHOOK_DELIVERY_BATCH_SIZE"))
+	if err != nil || batchSize <= 0 {
+		batchSize = 50
+	}
+	timeoutSeconds, err := strconv.Atoi(os.Getenv("WEBHOOK_TIMEOUT_BY_SECOND"))
+	if err != nil || timeoutSeconds <= 0 {
+		timeoutSeconds = 10
+	}
+	maxAttempts, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	retryBaseSeconds, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_BASE_BY_SECOND"))
+	if err != nil || retryBaseSeconds <= 0 {
+		retryBaseSeconds = 30
+	}
+	retryMaxSeconds, err := strconv.Atoi(os.Getenv("WEBHOOK_RETRY_MAX_BY_SECOND"))
+	if err != nil || retryMaxSeconds <= 0 {
+		retryMaxSeconds = 6 * 60 * 60
+	}
+	timeout := time.Duration(timeoutSeconds) * time.Second
+	return &WebhookDeliveryJob{
+		subscriptionRepo: repositories_impl.NewWebhookSubscriptionRepositoryImpl(db),
+		deliveryRepo:     repositories_impl.NewWebhookDeliveryRepositoryImpl(db),
+		sender:           webhook.NewSender(timeout),
+		interval:         time.Duration(intervalMilliseconds) * time.Millisecond,
+		batchSize:        batchSize,
+		timeout:          timeout,
+		maxAttempts:      maxAttempts,
+		retryBase:        time.Duration(retryBaseSeconds) * time.Second,
+		retryMax:         time.Duration(retryMaxSeconds) * time.Second,
+	}
+}
+
+func (j *WebhookDeliveryJob) Start() {
+	go func() {
+		for {
+			sent, err := j.Deliver()
+			if err != nil {
+				log.Error().Str("error", "error when deliver webhooks: "+err.Error()).Msg("")
+			}
+			if err == nil && sent == j.batchSize {
+				continue
+			}
+			time.Sleep(j.interval)
+		}
+	}()
+}
+
+// Deliver attempts one batch of due deliveries concurrently and returns how
+// many were attempted.
+func (j *WebhookDeliveryJob) Deliver() (int, error) {
+	// The lease outlives the slowest possible attempt so no other worker picks
+	// a delivery up while it is in flight.
+	deliveries, err := j.deliveryRepo.ClaimDue(j.batchSize, 2*j.timeout+time.Minute)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery model.WebhookDelivery) {
+			defer wg.Done()
+			j.attempt(delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+func (j *WebhookDeliveryJob) attempt(delivery model.WebhookDelivery) {
+	subscription, err := j.subscriptionRepo.FindOneById(delivery.UserId, strconv.FormatInt(delivery.SubscriptionId, 10))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !subscription.Active) {
+		j.fail(delivery, 0, errors.New("subscription is inactive"), true)
+		return
+	}
+	if err != nil {
+		log.Error().Int64("deliveryId", delivery.Id).Str("error", "error when get webhook subscription: "+err.Error()).Msg("")
+		return
+	}
+
+	statusCode, err := j.sender.Send(subscription.Url, subscription.Secret, delivery)
+	if err != nil {
+		j.fail(delivery, statusCode, err, false)
+		return
+	}
+	err = j.deliveryRepo.MarkSucceeded(delivery.Id, statusCode)
+	if err != nil {
+		log.Error().Int64("deliveryId", delivery.Id).Str("error", "error when mark webhook delivered: "+err.Error()).Msg("")
+	}
+}
+
+func (j *WebhookDeliveryJob) fail(delivery model.WebhookDelivery, statusCode int, cause error, dead bool) {
+	attempts := delivery.Attempts + 1
+	status := model.WebhookDeliveryPending
+	if dead || attempts >= j.maxAttempts {
+		status = model.WebhookDeliveryDead
+	}
+	nextAttemptAt := time.Now().Add(webhook.Backoff(attempts, j.retryBase, j.retryMax))
+
+	err := j.deliveryRepo.MarkFailed(delivery.Id, status, nextAttemptAt, statusCode, cause.Error())
+	if err != nil {
+		log.Error().Int64("deliveryId", delivery.Id).Str("error", "error when mark webhook failed: "+err.Error()).Msg("")
+	}
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/database/repositories_impl"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+const webhookConsumerGroup = "webhooks"
+
+// WebhookDispatchJob turns domain events from the outbox stream into one
+// queued delivery per matching webhook subscription.
+type WebhookDispatchJob struct {
+	subscriptionRepo repositories.WebhookSubscriptionRepository
+	deliveryRepo     repositories.WebhookDeliveryRepository
+	consumer         *events.StreamConsumer
+}
+
+func NewWebhookDispatchJob(db database.Database, redis *redis.RedisDatabase) *WebhookDispatchJob {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "flashcard_service"
+	}
+	return &WebhookDispatchJob{
+		subscriptionRepo: repositories_impl.NewWebhookSubscriptionRepositoryImpl(db),
+		deliveryRepo:     repositories_impl.NewWebhookDeliveryRepositoryImpl(db),
+		consumer:         events.NewStreamConsumer(redis, webhookConsumerGroup, hostname),
+	}
+}
+
+func (j *WebhookDispatchJob) Start() {
+	go func() {
+		err := j.consumer.Run(context.Background(), j.Dispatch)
+		if err != nil {
+			log.Error().Str("error", "webhook dispatcher stopped: "+err.Error()).Msg("")
+		}
+	}()
+}
+
+// Dispatch queues the event for every active subscription of its user that
+// asked for its type. Queuing is idempotent, so a redelivered event is safe.
+func (j *WebhookDispatchJob) Dispatch(event events.Event) error {
+	subscriptions, err := j.subscriptionRepo.FindActiveByUserId(event.UserId)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, subscription := range subscriptions {
+		if !subscription.Matches(string(event.Type)) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(event)
+			if err != nil {
+				return err
+			}
+		}
+		err = j.deliveryRepo.Insert(model.WebhookDelivery{
+			SubscriptionId: subscription.Id,
+			UserId:         event.UserId,
+			EventId:        event.Id,
+			EventType:      string(event.Type),
+			Payload:        string(payload),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,8 +9,9 @@ import (
 func CorsMiddleware(next http.Handler) http.Handler {
 	cors := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "If-Match", "If-None-Match", "Idempotency-Key"},
+		ExposedHeaders:   []string{"ETag", "Location", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           300,
 		Debug:            false,
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/utils"
+	"io"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+const idempotencyTtlInSec = 24 * 60 * 60
+const maxIdempotencyKeyLength = 255
+
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// IdempotencyMiddleware makes create endpoints safe to retry. The first
+// successful response for an Idempotency-Key is kept in Redis for 24 hours and
+// replayed for every repeat of the same request.
+type IdempotencyMiddleware struct {
+	store cache.Store
+}
+
+func NewIdempotencyMiddleware(store cache.Store) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		store: store,
+	}
+}
+
+func (m *IdempotencyMiddleware) Do(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(constant.IdempotencyKeyHeader)
+		if len(idempotencyKey) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			utils.SetHttpReponseError(r, utils.ErrValidationFailed.WithDetails(utils.TooLongField(constant.IdempotencyKeyHeader, maxIdempotencyKeyLength)), errors.New("idempotency key is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, utils.MaxRequestBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.SetHttpReponseError(r, utils.ErrRequestTooLarge, err)
+			return
+		}
+		if err != nil {
+			utils.SetHttpReponseError(r, utils.ErrMalformedBody, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := redis.GetIdempotencyKey(r.Header.Get(constant.UserIdHeader), idempotencyKey)
+		fingerprint := requestFingerprint(r, body)
+		pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := m.store.SetNX(key, string(pending), idempotencyTtlInSec)
+		if err != nil {
+			utils.SetHttpReponseError(r, utils.ErrServerError, err)
+			return
+		}
+		if !acquired {
+			m.replay(w, r, key, fingerprint)
+			return
+		}
+
+		// A panicking handler must not leave the key in progress until it
+		// expires, so it is released before the panic goes on.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				m.release(key)
+				panic(recovered)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// Failed requests did not create anything, so the key is released and
+		// the client may retry with it.
+		if _, failed := r.Context().Value(constant.AppErrorContextKey).(utils.AppError); failed {
+			m.release(key)
+			return
+		}
+
+		completed, _ := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Completed:   true,
+			Status:      recorder.status,
+			ContentType: w.Header().Get("Content-Type"),
+			Location:    w.Header().Get(constant.LocationHeader),
+			Body:        recorder.body.Bytes(),
+		})
+		err = m.store.Set(key, string(completed), idempotencyTtlInSec)
+		if err != nil {
+			log.Info().Msg("Failed to store idempotent response: " + err.Error())
+		}
+	})
+}
+
+func (m *IdempotencyMiddleware) release(key string) {
+	err := m.store.Del(key)
+	if err != nil {
+		log.Info().Msg("Failed to release idempotency key: " + err.Error())
+	}
+}
+
+func (m *IdempotencyMiddleware) replay(w http.ResponseWriter, r *http.Request, key string, fingerprint string) {
+	value, err := m.store.Get(key)
+	if err != nil {
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+	var record idempotencyRecord
+	err = json.Unmarshal([]byte(value), &record)
+	if err != nil {
+		utils.SetHttpReponseError(r, utils.ErrServerError, err)
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		utils.SetHttpReponseError(r, utils.ErrIdempotencyKeyReused, errors.New("idempotency key was used with a different request"))
+		return
+	}
+	if !record.Completed {
+		utils.SetHttpReponseError(r, utils.ErrRequestInProgress, errors.New("request with this idempotency key is still in progress"))
+		return
+	}
+
+	if len(record.ContentType) > 0 {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	if len(record.Location) > 0 {
+		w.Header().Set(constant.LocationHeader, record.Location)
+	}
+	w.Header().Set(constant.IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newIdempotentRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/category", strings.NewReader(body))
+	req.Header.Set(constant.UserIdHeader, "1")
+	req.Header.Set(constant.IdempotencyKeyHeader, "key")
+	return req
+}
+
+func TestIdempotencyReleasesKeyWhenHandlerPanics(t *testing.T) {
+	store := cache.NewMemoryStore()
+	handler := NewIdempotencyMiddleware(store).Do(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Fatalf("recovered %v, want the handler's panic", recovered)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), newIdempotentRequest(`{}`))
+	}()
+
+	if _, err := store.Get(redis.GetIdempotencyKey("1", "key")); err != cache.ErrNotFound {
+		t.Fatalf("key still held after panic: %v", err)
+	}
+}
+
+func TestIdempotencyRejectsLargeBodies(t *testing.T) {
+	called := false
+	handler := NewIdempotencyMiddleware(cache.NewMemoryStore()).Do(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := newIdempotentRequest(strings.Repeat("a", utils.MaxRequestBodyBytes+1))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	appErr, _ := req.Context().Value(constant.AppErrorContextKey).(utils.AppError)
+	if called || appErr.ErrorCode != utils.ErrRequestTooLarge.ErrorCode {
+		t.Fatalf("called = %v, error = %+v, want REQUEST_TOO_LARGE", called, appErr)
+	}
+}
@@ -6,6 +6,8 @@ type Category struct {
 	Id        int64      `json:"id,omitempty"`
 	Name      string     `json:"name,omitempty"`
 	UserID    int        `json:"userId,omitempty"`
+	Version   int64      `json:"version,omitempty"`
 	CreatedAt *time.Time `json:"createdAt,omitempty"`
 	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
@@ -8,8 +8,10 @@ type Flashcard struct {
 	Content    string     `json:"content,omitempty"`
 	CategoryId int        `json:"categoryId,omitempty"`
 	UserId     int        `json:"userId,omitempty"`
+	Version    int64      `json:"version,omitempty"`
 	CreatedAt  *time.Time `json:"createdAt,omitempty"`
 	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
 }
 
 func (f Flashcard) IsExisted() bool {
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// FlashcardRevision records one update of a flashcard. Name, Content and
+// CategoryId hold the values the card had before that update, so restoring a
+// revision undoes it together with every later update.
+type FlashcardRevision struct {
+	ID          int64         `json:"id,omitempty"`
+	FlashcardId int64         `json:"flashcardId,omitempty"`
+	Revision    int           `json:"revision"`
+	Name        string        `json:"name,omitempty"`
+	Content     string        `json:"content,omitempty"`
+	CategoryId  int           `json:"categoryId,omitempty"`
+	Diff        []FieldChange `json:"diff"`
+	CreatedAt   *time.Time    `json:"createdAt,omitempty"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffFlashcards lists the editable fields that differ between two versions of
+// a flashcard.
+func DiffFlashcards(from Flashcard, to Flashcard) []FieldChange {
+	changes := make([]FieldChange, 0)
+	if from.Name != to.Name {
+		changes = append(changes, FieldChange{Field: "name", From: from.Name, To: to.Name})
+	}
+	if from.Content != to.Content {
+		changes = append(changes, FieldChange{Field: "content", From: from.Content, To: to.Content})
+	}
+	if from.CategoryId != to.CategoryId {
+		changes = append(changes, FieldChange{Field: "categoryId", From: from.CategoryId, To: to.CategoryId})
+	}
+	return changes
+}
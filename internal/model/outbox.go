@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type OutboxEntry struct {
+	Id          int64      `json:"id"`
+	EventId     string     `json:"eventId"`
+	EventType   string     `json:"eventType"`
+	UserId      string     `json:"userId"`
+	Payload     string     `json:"payload"`
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+type WebhookSubscription struct {
+	Id         int64      `json:"id"`
+	UserId     string     `json:"userId"`
+	Url        string     `json:"url"`
+	Secret     string     `json:"-"`
+	EventTypes []string   `json:"eventTypes"`
+	Active     bool       `json:"active"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+}
+
+// Matches reports whether the subscription wants events of eventType. "*"
+// subscribes to every event.
+func (w WebhookSubscription) Matches(eventType string) bool {
+	for _, t := range w.EventTypes {
+		if t == "*" || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	Id             int64      `json:"id"`
+	SubscriptionId int64      `json:"subscriptionId"`
+	UserId         string     `json:"userId"`
+	EventId        string     `json:"eventId"`
+	EventType      string     `json:"eventType"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	LastStatusCode *int       `json:"lastStatusCode,omitempty"`
+	LastError      *string    `json:"lastError,omitempty"`
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt      *time.Time `json:"updatedAt,omitempty"`
+}
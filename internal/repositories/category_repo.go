@@ -1,10 +1,25 @@
 package repositories
 
-import "flashcard_service/internal/model"
+import (
+	"flashcard_service/internal/model"
+	"flashcard_service/pkg/objects"
+	"time"
+)
 
 type CategoryRepository interface {
 	Insert(userId string, name string) (int64, error)
-	UpdateById(userId string, id string, name string) error
-	DeleteById(userId string, id string) error
+	UpdateById(userId string, id string, name string, version int64) error
+	// PatchById writes the fields set in patch and returns the updated
+	// category.
+	PatchById(userId string, id string, patch objects.PatchCategory, version int64) (model.Category, error)
+	DeleteById(userId string, id string, version int64) error
 	FindAll(userId string) ([]model.Category, error)
+	FindOneById(userId string, id string) (model.Category, error)
+	FindDeleted(userId string) ([]model.Category, error)
+	RestoreById(userId string, id string) (model.Category, error)
+	PurgeDeletedBefore(before time.Time) (int64, error)
+	FindChangedSince(userId string, since time.Time, until time.Time) ([]model.Category, error)
+	// FindActiveUserIds returns up to limit users who changed a category or
+	// flashcard since the given time, most recent first.
+	FindActiveUserIds(since time.Time, limit int) ([]string, error)
 }
@@ -0,0 +1,7 @@
+package repositories
+
+import "errors"
+
+// ErrVersionMismatch is returned by conditional writes when the stored row has
+// moved past the version the caller based its change on.
+var ErrVersionMismatch = errors.New("version mismatch")
@@ -3,12 +3,24 @@ package repositories
 import (
 	"flashcard_service/internal/model"
 	"flashcard_service/pkg/objects"
+	"time"
 )
 
 type FlashcardRepository interface {
-	InsertManyByUserId(userId string, categoryId string, flashcards []objects.CreateFlashcard) error
+	Insert(userId string, categoryId string, flashcard objects.CreateFlashcard) (int64, error)
+	InsertManyByUserId(userId string, categoryId string, flashcards []objects.CreateFlashcard) ([]model.Flashcard, error)
 	FindOneById(userId string, id string) (model.Flashcard, error)
 	FindByCategoryId(userId string, categoryId string) ([]model.Flashcard, error)
-	DeleteById(userId string, id string) error
-	UpdateById(userId string, id string, flashcard model.Flashcard) error
+	DeleteById(userId string, id string, version int64) error
+	UpdateById(userId string, id string, flashcard model.Flashcard, version int64) error
+	// PatchById writes the fields set in patch and returns the updated
+	// flashcard.
+	PatchById(userId string, id string, patch objects.PatchFlashcard, version int64) (model.Flashcard, error)
+	// ApplyBulk runs one operation on many flashcards in a transaction and
+	// reports the outcome for each id.
+	ApplyBulk(userId string, bulk objects.BulkFlashcards) ([]objects.BulkFlashcardResult, error)
+	FindDeleted(userId string) ([]model.Flashcard, error)
+	RestoreById(userId string, id string) (model.Flashcard, error)
+	PurgeDeletedBefore(before time.Time) (int64, error)
+	FindChangedSince(userId string, since time.Time, until time.Time) ([]model.Flashcard, error)
 }
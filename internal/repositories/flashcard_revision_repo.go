@@ -0,0 +1,8 @@
+package repositories
+
+import "flashcard_service/internal/model"
+
+type FlashcardRevisionRepository interface {
+	FindByFlashcardId(userId string, flashcardId string) ([]model.FlashcardRevision, error)
+	FindOne(userId string, flashcardId string, revision string) (model.FlashcardRevision, error)
+}
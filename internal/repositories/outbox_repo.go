@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"flashcard_service/internal/model"
+	"time"
+)
+
+type OutboxRepository interface {
+	// PublishPending hands up to limit unpublished entries to publish and marks
+	// them published once it returns nil. Entries claimed by another relay are
+	// skipped.
+	PublishPending(limit int, publish func(entries []model.OutboxEntry) error) (int, error)
+	FindRange(fromId int64, toId int64, limit int) ([]model.OutboxEntry, error)
+	// DeletePublishedBefore removes the entries published before the given
+	// time. Pending entries are kept however old they are.
+	DeletePublishedBefore(before time.Time) (int64, error)
+}
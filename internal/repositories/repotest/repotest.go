@@ -0,0 +1,652 @@
+// Package repotest is the contract every database backend of the
+// repositories must satisfy. Each backend runs it from its own tests.
+package repotest
+
+import (
+	"database/sql"
+	"errors"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/database/repositories_impl"
+	"flashcard_service/pkg/objects"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// tables lists the tables the suite writes to, children first.
+var tables = []string{
+	"webhook_delivery",
+	"webhook_subscription",
+	"flashcard_revision",
+	"outbox",
+	"flashcard",
+	"flash_category",
+}
+
+type suite struct {
+	db         database.Database
+	categories repositories.CategoryRepository
+	flashcards repositories.FlashcardRepository
+	revisions  repositories.FlashcardRevisionRepository
+	outbox     repositories.OutboxRepository
+	webhooks   repositories.WebhookSubscriptionRepository
+	deliveries repositories.WebhookDeliveryRepository
+}
+
+// Run checks the repositories built on db. db must be connected and have the
+// current schema; the suite empties every table it uses before each test.
+func Run(t *testing.T, db database.Database) {
+	s := &suite{
+		db:         db,
+		categories: repositories_impl.NewCategoryRepositoryImpl(db),
+		flashcards: repositories_impl.NewFlashcardRepositoryImpl(db),
+		revisions:  repositories_impl.NewFlashcardRevisionRepositoryImpl(db),
+		outbox:     repositories_impl.NewOutboxRepositoryImpl(db),
+		webhooks:   repositories_impl.NewWebhookSubscriptionRepositoryImpl(db),
+		deliveries: repositories_impl.NewWebhookDeliveryRepositoryImpl(db),
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"CategoryInsertAndUpdate", s.testCategoryInsertAndUpdate},
+		{"CategoryDeleteAndRestore", s.testCategoryDeleteAndRestore},
+		{"CategoryPurge", s.testCategoryPurge},
+		{"FlashcardInsertAndFind", s.testFlashcardInsertAndFind},
+		{"FlashcardUpdateRecordsRevision", s.testFlashcardUpdateRecordsRevision},
+		{"FlashcardPatch", s.testFlashcardPatch},
+		{"FlashcardDeleteAndRestore", s.testFlashcardDeleteAndRestore},
+		{"FlashcardPurge", s.testFlashcardPurge},
+		{"FlashcardApplyBulk", s.testFlashcardApplyBulk},
+		{"OtherUsersCannotWrite", s.testOtherUsersCannotWrite},
+		{"FindChangedSince", s.testFindChangedSince},
+		{"FindActiveUserIds", s.testFindActiveUserIds},
+		{"OutboxPublishPending", s.testOutboxPublishPending},
+		{"OutboxDeletePublishedBefore", s.testOutboxDeletePublishedBefore},
+		{"WebhookDeliveryIsQueuedOnce", s.testWebhookDeliveryIsQueuedOnce},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s.reset(t)
+			test.run(t)
+		})
+	}
+}
+
+func (s *suite) reset(t *testing.T) {
+	t.Helper()
+	for _, table := range tables {
+		_, cancel, err := s.db.Exec("DELETE FROM " + table)
+		cancel()
+		if err != nil {
+			t.Fatalf("empty %s: %v", table, err)
+		}
+	}
+}
+
+func (s *suite) insertCategory(t *testing.T, userId string, name string) string {
+	t.Helper()
+	id, err := s.categories.Insert(userId, name)
+	if err != nil {
+		t.Fatalf("insert category: %v", err)
+	}
+	if id <= 0 {
+		t.Fatalf("insert category returned id %d", id)
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+func (s *suite) testCategoryInsertAndUpdate(t *testing.T) {
+	id := s.insertCategory(t, "1", "verbs")
+
+	category, err := s.categories.FindOneById("1", id)
+	if err != nil {
+		t.Fatalf("find category: %v", err)
+	}
+	if category.Name != "verbs" || category.Version != 1 || category.CreatedAt == nil {
+		t.Fatalf("unexpected category %+v", category)
+	}
+
+	err = s.categories.UpdateById("1", id, "nouns", 1)
+	if err != nil {
+		t.Fatalf("update category: %v", err)
+	}
+	err = s.categories.UpdateById("1", id, "adjectives", 1)
+	if !errors.Is(err, repositories.ErrVersionMismatch) {
+		t.Fatalf("update with a stale version: got %v, want ErrVersionMismatch", err)
+	}
+
+	categories, err := s.categories.FindAll("1")
+	if err != nil {
+		t.Fatalf("find categories: %v", err)
+	}
+	if len(categories) != 1 || categories[0].Name != "nouns" || categories[0].Version != 2 {
+		t.Fatalf("unexpected categories %+v", categories)
+	}
+
+	others, err := s.categories.FindAll("2")
+	if err != nil {
+		t.Fatalf("find categories of another user: %v", err)
+	}
+	// An empty list is cached and served as [], never null.
+	if others == nil || len(others) != 0 {
+		t.Fatalf("another user sees %+v", others)
+	}
+}
+
+func (s *suite) testCategoryDeleteAndRestore(t *testing.T) {
+	id := s.insertCategory(t, "1", "verbs")
+	created, err := s.flashcards.InsertManyByUserId("1", id, []objects.CreateFlashcard{
+		{Name: "go", Content: "went"},
+		{Name: "see", Content: "saw"},
+	})
+	if err != nil {
+		t.Fatalf("insert flashcards: %v", err)
+	}
+	if len(created) != 2 || created[0].ID <= 0 || created[1].ID <= created[0].ID || created[1].Name != "see" || created[0].CreatedAt == nil {
+		t.Fatalf("unexpected created flashcards %+v", created)
+	}
+
+	err = s.categories.DeleteById("1", id, 0)
+	if err != nil {
+		t.Fatalf("delete category: %v", err)
+	}
+	_, err = s.categories.FindOneById("1", id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("find deleted category: got %v, want sql.ErrNoRows", err)
+	}
+	flashcards, err := s.flashcards.FindByCategoryId("1", id)
+	if err != nil {
+		t.Fatalf("find flashcards: %v", err)
+	}
+	if len(flashcards) != 0 {
+		t.Fatalf("flashcards of a deleted category are visible: %+v", flashcards)
+	}
+	deleted, err := s.categories.FindDeleted("1")
+	if err != nil {
+		t.Fatalf("find deleted categories: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Fatalf("unexpected trash %+v", deleted)
+	}
+
+	restored, err := s.categories.RestoreById("1", id)
+	if err != nil {
+		t.Fatalf("restore category: %v", err)
+	}
+	if restored.Name != "verbs" {
+		t.Fatalf("unexpected restored category %+v", restored)
+	}
+	flashcards, err = s.flashcards.FindByCategoryId("1", id)
+	if err != nil {
+		t.Fatalf("find restored flashcards: %v", err)
+	}
+	if len(flashcards) != 2 {
+		t.Fatalf("restored %d flashcards, want 2", len(flashcards))
+	}
+
+	_, err = s.categories.RestoreById("1", id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("restore a category not in the trash: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func (s *suite) testCategoryPurge(t *testing.T) {
+	id := s.insertCategory(t, "1", "verbs")
+	s.insertCategory(t, "1", "nouns")
+	err := s.categories.DeleteById("1", id, 0)
+	if err != nil {
+		t.Fatalf("delete category: %v", err)
+	}
+
+	purged, err := s.categories.PurgeDeletedBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("purge categories: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d categories, want 1", purged)
+	}
+	categories, err := s.categories.FindAll("1")
+	if err != nil {
+		t.Fatalf("find categories: %v", err)
+	}
+	if len(categories) != 1 {
+		t.Fatalf("purge touched live categories: %+v", categories)
+	}
+}
+
+func (s *suite) testFlashcardInsertAndFind(t *testing.T) {
+	categoryId := s.insertCategory(t, "1", "verbs")
+	id, err := s.flashcards.Insert("1", categoryId, objects.CreateFlashcard{Name: "go", Content: "went"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	if id <= 0 {
+		t.Fatalf("insert flashcard returned id %d", id)
+	}
+
+	flashcard, err := s.flashcards.FindOneById("1", strconv.FormatInt(id, 10))
+	if err != nil {
+		t.Fatalf("find flashcard: %v", err)
+	}
+	if flashcard.Name != "go" || flashcard.Content != "went" || strconv.Itoa(flashcard.CategoryId) != categoryId || flashcard.Version != 1 {
+		t.Fatalf("unexpected flashcard %+v", flashcard)
+	}
+
+	_, err = s.flashcards.FindOneById("2", strconv.FormatInt(id, 10))
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("find flashcard of another user: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func (s *suite) testFlashcardUpdateRecordsRevision(t *testing.T) {
+	categoryId := s.insertCategory(t, "1", "verbs")
+	id, err := s.flashcards.Insert("1", categoryId, objects.CreateFlashcard{Name: "go", Content: "goed"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	flashcardId := strconv.FormatInt(id, 10)
+	category, _ := strconv.Atoi(categoryId)
+
+	err = s.flashcards.UpdateById("1", flashcardId, model.Flashcard{Name: "go", Content: "went", CategoryId: category}, 1)
+	if err != nil {
+		t.Fatalf("update flashcard: %v", err)
+	}
+	err = s.flashcards.UpdateById("1", flashcardId, model.Flashcard{Name: "go", Content: "gone", CategoryId: category}, 1)
+	if !errors.Is(err, repositories.ErrVersionMismatch) {
+		t.Fatalf("update with a stale version: got %v, want ErrVersionMismatch", err)
+	}
+
+	revisions, err := s.revisions.FindByFlashcardId("1", flashcardId)
+	if err != nil {
+		t.Fatalf("find revisions: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Content != "goed" {
+		t.Fatalf("unexpected revisions %+v", revisions)
+	}
+	flashcard, err := s.flashcards.FindOneById("1", flashcardId)
+	if err != nil {
+		t.Fatalf("find flashcard: %v", err)
+	}
+	if revisions[0].CreatedAt == nil || flashcard.UpdatedAt == nil || !revisions[0].CreatedAt.Equal(*flashcard.UpdatedAt) {
+		t.Fatalf("revision created at %v, flashcard updated at %v", revisions[0].CreatedAt, flashcard.UpdatedAt)
+	}
+}
+
+func (s *suite) testFlashcardPatch(t *testing.T) {
+	categoryId := s.insertCategory(t, "1", "verbs")
+	id, err := s.flashcards.Insert("1", categoryId, objects.CreateFlashcard{Name: "go", Content: "goed"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	flashcardId := strconv.FormatInt(id, 10)
+
+	flashcard, err := s.flashcards.PatchById("1", flashcardId, objects.PatchFlashcard{Content: objects.Some("went")}, 1)
+	if err != nil {
+		t.Fatalf("patch flashcard: %v", err)
+	}
+	if flashcard.Name != "go" || flashcard.Content != "went" || strconv.Itoa(flashcard.CategoryId) != categoryId || flashcard.Version != 2 {
+		t.Fatalf("unexpected flashcard %+v", flashcard)
+	}
+	_, err = s.flashcards.PatchById("1", flashcardId, objects.PatchFlashcard{Name: objects.Some("be")}, 1)
+	if !errors.Is(err, repositories.ErrVersionMismatch) {
+		t.Fatalf("patch with a stale version: got %v, want ErrVersionMismatch", err)
+	}
+
+	// An empty patch writes nothing.
+	flashcard, err = s.flashcards.PatchById("1", flashcardId, objects.PatchFlashcard{}, 2)
+	if err != nil {
+		t.Fatalf("empty patch: %v", err)
+	}
+	if flashcard.Version != 2 {
+		t.Fatalf("empty patch moved the version to %d", flashcard.Version)
+	}
+
+	revisions, err := s.revisions.FindByFlashcardId("1", flashcardId)
+	if err != nil {
+		t.Fatalf("find revisions: %v", err)
+	}
+	if len(revisions) != 1 || len(revisions[0].Diff) != 1 || revisions[0].Diff[0].Field != "content" {
+		t.Fatalf("unexpected revisions %+v", revisions)
+	}
+
+	category, err := s.categories.PatchById("1", categoryId, objects.PatchCategory{Name: objects.Some("nouns")}, 1)
+	if err != nil {
+		t.Fatalf("patch category: %v", err)
+	}
+	if category.Name != "nouns" || category.Version != 2 {
+		t.Fatalf("unexpected category %+v", category)
+	}
+}
+
+func (s *suite) testFlashcardDeleteAndRestore(t *testing.T) {
+	categoryId := s.insertCategory(t, "1", "verbs")
+	id, err := s.flashcards.Insert("1", categoryId, objects.CreateFlashcard{Name: "go", Content: "went"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	flashcardId := strconv.FormatInt(id, 10)
+
+	err = s.flashcards.DeleteById("1", flashcardId, 2)
+	if !errors.Is(err, repositories.ErrVersionMismatch) {
+		t.Fatalf("delete with a stale version: got %v, want ErrVersionMismatch", err)
+	}
+	err = s.flashcards.DeleteById("1", flashcardId, 1)
+	if err != nil {
+		t.Fatalf("delete flashcard: %v", err)
+	}
+	_, err = s.flashcards.FindOneById("1", flashcardId)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("find deleted flashcard: got %v, want sql.ErrNoRows", err)
+	}
+	deleted, err := s.flashcards.FindDeleted("1")
+	if err != nil {
+		t.Fatalf("find deleted flashcards: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Fatalf("unexpected trash %+v", deleted)
+	}
+
+	restored, err := s.flashcards.RestoreById("1", flashcardId)
+	if err != nil {
+		t.Fatalf("restore flashcard: %v", err)
+	}
+	if restored.Content != "went" {
+		t.Fatalf("unexpected restored flashcard %+v", restored)
+	}
+	flashcards, err := s.flashcards.FindByCategoryId("1", categoryId)
+	if err != nil {
+		t.Fatalf("find flashcards: %v", err)
+	}
+	if len(flashcards) != 1 {
+		t.Fatalf("found %d flashcards after restore, want 1", len(flashcards))
+	}
+}
+
+func (s *suite) testFlashcardPurge(t *testing.T) {
+	categoryId := s.insertCategory(t, "1", "verbs")
+	category, _ := strconv.Atoi(categoryId)
+	id, err := s.flashcards.Insert("1", categoryId, objects.CreateFlashcard{Name: "go", Content: "goed"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	flashcardId := strconv.FormatInt(id, 10)
+	_, err = s.flashcards.Insert("1", categoryId, objects.CreateFlashcard{Name: "be", Content: "was"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	err = s.flashcards.UpdateById("1", flashcardId, model.Flashcard{Name: "go", Content: "went", CategoryId: category}, 1)
+	if err != nil {
+		t.Fatalf("update flashcard: %v", err)
+	}
+	err = s.flashcards.DeleteById("1", flashcardId, 2)
+	if err != nil {
+		t.Fatalf("delete flashcard: %v", err)
+	}
+
+	purged, err := s.flashcards.PurgeDeletedBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("purge flashcards: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d flashcards, want 1", purged)
+	}
+	flashcards, err := s.flashcards.FindByCategoryId("1", categoryId)
+	if err != nil {
+		t.Fatalf("find flashcards: %v", err)
+	}
+	if len(flashcards) != 1 || flashcards[0].Name != "be" {
+		t.Fatalf("purge touched live flashcards: %+v", flashcards)
+	}
+	revisions, err := s.revisions.FindByFlashcardId("1", flashcardId)
+	if err != nil {
+		t.Fatalf("find revisions: %v", err)
+	}
+	if len(revisions) != 0 {
+		t.Fatalf("purge left revisions %+v", revisions)
+	}
+}
+
+func (s *suite) testFlashcardApplyBulk(t *testing.T) {
+	from := s.insertCategory(t, "1", "verbs")
+	to := s.insertCategory(t, "1", "nouns")
+	toId, _ := strconv.Atoi(to)
+	ids := make([]int64, 0, 2)
+	for _, name := range []string{"go", "be"} {
+		id, err := s.flashcards.Insert("1", from, objects.CreateFlashcard{Name: name})
+		if err != nil {
+			t.Fatalf("insert flashcard: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	results, err := s.flashcards.ApplyBulk("1", objects.BulkFlashcards{Op: objects.BulkOpMove, Ids: append(ids, 999), CategoryId: toId})
+	if err != nil {
+		t.Fatalf("bulk move: %v", err)
+	}
+	if len(results) != 3 || results[0].Status != objects.BulkStatusApplied || results[2].Status != objects.BulkStatusNotFound {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if strconv.Itoa(results[0].PreviousCategoryId) != from || results[0].Flashcard == nil || results[0].Flashcard.CategoryId != toId {
+		t.Fatalf("unexpected move result %+v", results[0])
+	}
+	moved, err := s.flashcards.FindByCategoryId("1", to)
+	if err != nil {
+		t.Fatalf("find flashcards: %v", err)
+	}
+	if len(moved) != 2 {
+		t.Fatalf("moved flashcards %+v", moved)
+	}
+
+	results, err = s.flashcards.ApplyBulk("1", objects.BulkFlashcards{Op: objects.BulkOpDelete, Ids: []int64{ids[0], ids[0]}})
+	if err != nil {
+		t.Fatalf("bulk delete: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != objects.BulkStatusApplied {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	deleted, err := s.flashcards.FindDeleted("1")
+	if err != nil {
+		t.Fatalf("find deleted flashcards: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != ids[0] {
+		t.Fatalf("deleted flashcards %+v", deleted)
+	}
+}
+
+func (s *suite) testOtherUsersCannotWrite(t *testing.T) {
+	categoryId := s.insertCategory(t, "1", "verbs")
+	id, err := s.flashcards.Insert("1", categoryId, objects.CreateFlashcard{Name: "go", Content: "went"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	flashcardId := strconv.FormatInt(id, 10)
+
+	if err := s.categories.UpdateById("2", categoryId, "nouns", 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("update category of another user: got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.categories.DeleteById("2", categoryId, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("delete category of another user: got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.flashcards.DeleteById("2", flashcardId, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("delete flashcard of another user: got %v, want sql.ErrNoRows", err)
+	}
+	flashcards, err := s.flashcards.FindByCategoryId("2", categoryId)
+	if err != nil {
+		t.Fatalf("find flashcards of another user: %v", err)
+	}
+	if flashcards == nil || len(flashcards) != 0 {
+		t.Fatalf("another user sees %+v", flashcards)
+	}
+
+	category, err := s.categories.FindOneById("1", categoryId)
+	if err != nil {
+		t.Fatalf("find category: %v", err)
+	}
+	if category.Name != "verbs" || category.Version != 1 {
+		t.Fatalf("another user changed %+v", category)
+	}
+}
+
+func (s *suite) testFindChangedSince(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	id := s.insertCategory(t, "1", "verbs")
+	_, err := s.flashcards.Insert("1", id, objects.CreateFlashcard{Name: "go", Content: "went"})
+	if err != nil {
+		t.Fatalf("insert flashcard: %v", err)
+	}
+	until := time.Now().Add(time.Hour)
+
+	categories, err := s.categories.FindChangedSince("1", since, until)
+	if err != nil {
+		t.Fatalf("find changed categories: %v", err)
+	}
+	if len(categories) != 1 {
+		t.Fatalf("found %d changed categories, want 1", len(categories))
+	}
+	flashcards, err := s.flashcards.FindChangedSince("1", since, until)
+	if err != nil {
+		t.Fatalf("find changed flashcards: %v", err)
+	}
+	if len(flashcards) != 1 {
+		t.Fatalf("found %d changed flashcards, want 1", len(flashcards))
+	}
+
+	categories, err = s.categories.FindChangedSince("1", until, until.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("find changed categories: %v", err)
+	}
+	if len(categories) != 0 {
+		t.Fatalf("found changes after they happened: %+v", categories)
+	}
+}
+
+func (s *suite) testFindActiveUserIds(t *testing.T) {
+	s.insertCategory(t, "1", "verbs")
+	s.insertCategory(t, "2", "nouns")
+
+	userIds, err := s.categories.FindActiveUserIds(time.Now().Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatalf("find active users: %v", err)
+	}
+	if len(userIds) != 2 {
+		t.Fatalf("found active users %v, want 1 and 2", userIds)
+	}
+	userIds, err = s.categories.FindActiveUserIds(time.Now().Add(-time.Hour), 1)
+	if err != nil {
+		t.Fatalf("find active users: %v", err)
+	}
+	if len(userIds) != 1 {
+		t.Fatalf("limit ignored: %v", userIds)
+	}
+}
+
+func (s *suite) testOutboxPublishPending(t *testing.T) {
+	s.insertCategory(t, "1", "verbs")
+
+	var published []model.OutboxEntry
+	count, err := s.outbox.PublishPending(10, func(entries []model.OutboxEntry) error {
+		published = entries
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("publish pending: %v", err)
+	}
+	if count != 1 || len(published) != 1 || published[0].UserId != "1" || published[0].Payload == "" {
+		t.Fatalf("unexpected published entries %+v", published)
+	}
+
+	count, err = s.outbox.PublishPending(10, func(entries []model.OutboxEntry) error {
+		t.Fatalf("published entries twice: %+v", entries)
+		return nil
+	})
+	if err != nil || count != 0 {
+		t.Fatalf("publish again: count %d, err %v", count, err)
+	}
+}
+
+func (s *suite) testOutboxDeletePublishedBefore(t *testing.T) {
+	s.insertCategory(t, "1", "verbs")
+	_, err := s.outbox.PublishPending(10, func(entries []model.OutboxEntry) error { return nil })
+	if err != nil {
+		t.Fatalf("publish pending: %v", err)
+	}
+	s.insertCategory(t, "1", "nouns")
+
+	deleted, err := s.outbox.DeletePublishedBefore(time.Now().Add(-time.Hour))
+	if err != nil || deleted != 0 {
+		t.Fatalf("delete entries published an hour ago: deleted %d, err %v", deleted, err)
+	}
+	deleted, err = s.outbox.DeletePublishedBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("delete published entries: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("deleted %d entries, want 1", deleted)
+	}
+
+	entries, err := s.outbox.FindRange(0, math.MaxInt64, 10)
+	if err != nil {
+		t.Fatalf("find entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].PublishedAt != nil {
+		t.Fatalf("delete touched pending entries: %+v", entries)
+	}
+}
+
+func (s *suite) testWebhookDeliveryIsQueuedOnce(t *testing.T) {
+	subscriptionId, err := s.webhooks.Insert(model.WebhookSubscription{
+		UserId:     "1",
+		Url:        "https://example.com/hook",
+		Secret:     "secret",
+		EventTypes: []string{"*"},
+		Active:     true,
+	})
+	if err != nil {
+		t.Fatalf("insert subscription: %v", err)
+	}
+	if subscriptionId <= 0 {
+		t.Fatalf("insert subscription returned id %d", subscriptionId)
+	}
+
+	delivery := model.WebhookDelivery{
+		SubscriptionId: subscriptionId,
+		UserId:         "1",
+		EventId:        "00000000-0000-0000-0000-000000000001",
+		EventType:      "category.created",
+		Payload:        `{"id":1}`,
+	}
+	for i := 0; i < 2; i++ {
+		err = s.deliveries.Insert(delivery)
+		if err != nil {
+			t.Fatalf("insert delivery: %v", err)
+		}
+	}
+
+	deliveries, err := s.deliveries.FindBySubscriptionId("1", strconv.FormatInt(subscriptionId, 10), 10)
+	if err != nil {
+		t.Fatalf("find deliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != model.WebhookDeliveryPending {
+		t.Fatalf("unexpected deliveries %+v", deliveries)
+	}
+
+	claimed, err := s.deliveries.ClaimDue(10, time.Minute)
+	if err != nil {
+		t.Fatalf("claim deliveries: %v", err)
+	}
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d deliveries, want 1", len(claimed))
+	}
+	claimed, err = s.deliveries.ClaimDue(10, time.Minute)
+	if err != nil {
+		t.Fatalf("claim deliveries again: %v", err)
+	}
+	if len(claimed) != 0 {
+		t.Fatalf("claimed a leased delivery: %+v", claimed)
+	}
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"flashcard_service/internal/model"
+	"time"
+)
+
+type WebhookSubscriptionRepository interface {
+	Insert(subscription model.WebhookSubscription) (int64, error)
+	FindAll(userId string) ([]model.WebhookSubscription, error)
+	FindOneById(userId string, id string) (model.WebhookSubscription, error)
+	FindActiveByUserId(userId string) ([]model.WebhookSubscription, error)
+	UpdateById(userId string, id string, subscription model.WebhookSubscription) error
+	DeleteById(userId string, id string) error
+}
+
+type WebhookDeliveryRepository interface {
+	// Insert queues a delivery. Queuing the same event twice for a subscription
+	// is a no-op.
+	Insert(delivery model.WebhookDelivery) error
+	// ClaimDue returns up to limit pending deliveries whose next attempt is due
+	// and pushes their next attempt lease into the future so other workers skip
+	// them meanwhile.
+	ClaimDue(limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	MarkSucceeded(id int64, statusCode int) error
+	MarkFailed(id int64, status string, nextAttemptAt time.Time, statusCode int, lastError string) error
+	FindBySubscriptionId(userId string, subscriptionId string, limit int) ([]model.WebhookDelivery, error)
+	// Requeue schedules a delivery for an immediate new round of attempts,
+	// typically one that went dead.
+	Requeue(userId string, subscriptionId string, id string) error
+}
@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for a subscriber host that resolves to an
+// address inside the service's own network. Posting there would let any user
+// reach internal services with signed requests.
+var ErrForbiddenAddress = errors.New("webhook address is not allowed")
+
+// forbiddenNetworks are the ranges IsForbiddenIP rejects on top of loopback,
+// private, link-local, multicast and unspecified addresses.
+var forbiddenNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	// Carrier-grade NAT, which also holds some cloud metadata endpoints
+	// such as 100.100.100.200.
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("255.255.255.255/32"),
+}
+
+// lookupIP resolves subscriber hosts at registration; tests replace it.
+var lookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// IsForbiddenIP reports whether a webhook must not be delivered to ip:
+// loopback, private, link-local (which holds the 169.254.169.254 metadata
+// endpoint), multicast, unspecified and the ranges above.
+func IsForbiddenIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckHost rejects a subscriber host that is, or resolves to, a forbidden
+// address. A host that does not resolve is rejected too.
+func CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if IsForbiddenIP(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ips, err := lookupIP(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if IsForbiddenIP(ip) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// guardDial is the net.Dialer Control of the sender. It checks the address
+// actually dialed, so a host that passed CheckHost and was later pointed at
+// an internal address (DNS rebinding) is still refused.
+func guardDial(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsForbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
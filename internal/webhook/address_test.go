@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCheckHostRejectsInternalAddresses(t *testing.T) {
+	hosts := []string{
+		"127.0.0.1",
+		"localhost",
+		"api.localhost",
+		"10.0.0.1",
+		"172.16.0.1",
+		"192.168.1.1",
+		"169.254.169.254",
+		"100.100.100.200",
+		"0.0.0.0",
+		"::1",
+		"::",
+		"fe80::1",
+		"fd00:ec2::254",
+		"::ffff:127.0.0.1",
+	}
+	for _, host := range hosts {
+		if err := CheckHost(host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%q) = %v, want ErrForbiddenAddress", host, err)
+		}
+	}
+}
+
+func TestCheckHostResolvesNames(t *testing.T) {
+	resolved := map[string][]net.IP{
+		"public.example":   {net.ParseIP("93.184.216.34")},
+		"internal.example": {net.ParseIP("93.184.216.34"), net.ParseIP("10.1.2.3")},
+	}
+	previous := lookupIP
+	lookupIP = func(_ context.Context, host string) ([]net.IP, error) {
+		if ips, ok := resolved[host]; ok {
+			return ips, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	defer func() { lookupIP = previous }()
+
+	if err := CheckHost("public.example"); err != nil {
+		t.Fatalf("CheckHost(public) = %v", err)
+	}
+	if err := CheckHost("93.184.216.34"); err != nil {
+		t.Fatalf("CheckHost(public ip) = %v", err)
+	}
+	if err := CheckHost("internal.example"); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("CheckHost(internal) = %v, want ErrForbiddenAddress", err)
+	}
+	if err := CheckHost("missing.example"); err == nil {
+		t.Fatal("CheckHost(missing) = nil, want an error")
+	}
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"bytes"
+	"flashcard_service/internal/model"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Sender posts deliveries to subscriber endpoints.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender returns a sender that refuses to connect to internal addresses,
+// see IsForbiddenIP.
+func NewSender(timeout time.Duration) *Sender {
+	return newSender(timeout, guardDial)
+}
+
+// newSender dials with control; tests pass nil to reach local servers.
+func newSender(timeout time.Duration, control func(network string, address string, c syscall.RawConn) error) *Sender {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would dial the subscriber itself, out of reach of control.
+	transport.Proxy = nil
+	return &Sender{
+		client: &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+// Send posts the delivery payload signed with secret. It returns the response
+// status code, or 0 when no response was received, and an error unless the
+// receiver answered with a 2xx.
+func (s *Sender) Send(url string, secret string, delivery model.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(EventIdHeader, delivery.EventId)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns how long to wait before the next attempt after the given
+// number of failed attempts: base doubled per attempt, capped at max.
+func Backoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"errors"
+	"flashcard_service/internal/model"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSendSignsPayload(t *testing.T) {
+	const secret = "test-secret"
+	delivery := model.WebhookDelivery{
+		Id:        7,
+		EventId:   "3f1c2b1e-0000-4000-8000-000000000001",
+		EventType: "category.created",
+		Payload:   `{"type":"category.created"}`,
+	}
+
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	statusCode, err := newSender(time.Second, nil).Send(server.URL, secret, delivery)
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	if statusCode != http.StatusNoContent {
+		t.Fatalf("status code = %d, want %d", statusCode, http.StatusNoContent)
+	}
+
+	r := <-received
+	body := <-bodies
+	if string(body) != delivery.Payload {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	if r.Header.Get(EventTypeHeader) != delivery.EventType {
+		t.Errorf("%s = %q", EventTypeHeader, r.Header.Get(EventTypeHeader))
+	}
+	if r.Header.Get(EventIdHeader) != delivery.EventId {
+		t.Errorf("%s = %q", EventIdHeader, r.Header.Get(EventIdHeader))
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", TimestampHeader, err)
+	}
+	if !Verify(secret, timestamp, body, r.Header.Get(SignatureHeader)) {
+		t.Errorf("signature %q does not verify", r.Header.Get(SignatureHeader))
+	}
+	if Verify("other-secret", timestamp, body, r.Header.Get(SignatureHeader)) {
+		t.Error("signature verifies with the wrong secret")
+	}
+}
+
+func TestSendFailsOnNon2xx(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	statusCode, err := newSender(time.Second, nil).Send(server.URL, "secret", model.WebhookDelivery{Payload: "{}"})
+	if err == nil {
+		t.Fatal("Send returned nil error for a 503")
+	}
+	if statusCode != http.StatusServiceUnavailable {
+		t.Errorf("status code = %d, want %d", statusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSendFailsWithoutResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	statusCode, err := newSender(time.Second, nil).Send(url, "secret", model.WebhookDelivery{Payload: "{}"})
+	if err == nil {
+		t.Fatal("Send returned nil error for a closed server")
+	}
+	if statusCode != 0 {
+		t.Errorf("status code = %d, want 0", statusCode)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 30 * time.Second
+	max := 10 * time.Minute
+	cases := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		5:  8 * time.Minute,
+		6:  max,
+		20: max,
+	}
+	for attempts, want := range cases {
+		if got := Backoff(attempts, base, max); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := NewSender(time.Second).Send(server.URL, "secret", model.WebhookDelivery{Id: 1, EventType: "category.created", Payload: `{}`})
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Send error = %v, want ErrForbiddenAddress", err)
+	}
+	if called {
+		t.Fatal("the internal server was reached")
+	}
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type task struct {
+	name string
+	fn   func() error
+}
+
+// Queue runs background tasks on a fixed pool of workers. A task that returns
+// an error is retried with a linear backoff up to maxAttempts times. Submit
+// never blocks a request: when the buffer is full the task is rejected and the
+// caller decides what to do.
+type Queue struct {
+	tasks       chan task
+	maxAttempts int
+	backoff     time.Duration
+	mu          sync.RWMutex
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+func NewQueue(workers int, size int, maxAttempts int, backoff time.Duration) *Queue {
+	q := &Queue{
+		tasks:       make(chan task, size),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+func NewQueueFromEnv() *Queue {
+	workers, err := strconv.Atoi(os.Getenv("WORKER_QUEUE_WORKERS"))
+	if err != nil || workers <= 0 {
+		workers = 4
+	}
+	size, err := strconv.Atoi(os.Getenv("WORKER_QUEUE_SIZE"))
+	if err != nil || size <= 0 {
+		size = 1000
+	}
+	maxAttempts, err := strconv.Atoi(os.Getenv("WORKER_QUEUE_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	backoffMilliseconds, err := strconv.Atoi(os.Getenv("WORKER_QUEUE_RETRY_BACKOFF_BY_MILLISECOND"))
+	if err != nil || backoffMilliseconds <= 0 {
+		backoffMilliseconds = 200
+	}
+	return NewQueue(workers, size, maxAttempts, time.Duration(backoffMilliseconds)*time.Millisecond)
+}
+
+// Submit queues fn and reports whether it was accepted.
+func (q *Queue) Submit(name string, fn func() error) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.tasks <- task{name: name, fn: fn}:
+		return true
+	default:
+		log.Error().Str("task", name).Str("error", "worker queue is full").Msg("")
+		return false
+	}
+}
+
+// Close stops accepting tasks and waits for the queued ones to finish.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for t := range q.tasks {
+		q.run(t)
+	}
+}
+
+func (q *Queue) run(t task) {
+	for attempt := 1; ; attempt++ {
+		err := t.fn()
+		if err == nil {
+			return
+		}
+		if attempt >= q.maxAttempts {
+			log.Error().Str("task", t.name).Int("attempts", attempt).Str("error", "task failed: "+err.Error()).Msg("")
+			return
+		}
+		time.Sleep(time.Duration(attempt) * q.backoff)
+	}
+}
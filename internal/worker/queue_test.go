@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueueRetriesFailedTasks(t *testing.T) {
+	q := NewQueue(1, 10, 3, time.Millisecond)
+	var calls atomic.Int32
+	q.Submit("flaky", func() error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	q.Close()
+
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("task ran %d times, want 3", got)
+	}
+}
+
+func TestQueueGivesUpAfterMaxAttempts(t *testing.T) {
+	q := NewQueue(1, 10, 2, time.Millisecond)
+	var calls atomic.Int32
+	q.Submit("broken", func() error {
+		calls.Add(1)
+		return errors.New("always")
+	})
+	q.Close()
+
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("task ran %d times, want 2", got)
+	}
+}
+
+func TestQueueRejectsWhenFull(t *testing.T) {
+	q := NewQueue(1, 1, 1, time.Millisecond)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	q.Submit("blocker", func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	if !q.Submit("buffered", func() error { return nil }) {
+		t.Fatal("task rejected while the buffer had room")
+	}
+	if q.Submit("overflow", func() error { return nil }) {
+		t.Fatal("task accepted while the buffer was full")
+	}
+	close(release)
+	q.Close()
+}
+
+func TestQueueConcurrentSubmitAndClose(t *testing.T) {
+	q := NewQueue(4, 100, 1, time.Millisecond)
+	var ran atomic.Int32
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if q.Submit("count", func() error {
+				ran.Add(1)
+				return nil
+			}) {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	q.Close()
+
+	if q.Submit("late", func() error { return nil }) {
+		t.Fatal("task accepted after Close")
+	}
+	if ran.Load() != accepted.Load() {
+		t.Fatalf("%d tasks ran, %d were accepted", ran.Load(), accepted.Load())
+	}
+}
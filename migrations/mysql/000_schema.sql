@@ -0,0 +1,17 @@
+CREATE TABLE flash_category (
+    id         BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    user_id    BIGINT       NOT NULL,
+    created_at DATETIME     NOT NULL,
+    updated_at DATETIME     NULL DEFAULT NULL
+);
+
+CREATE TABLE flashcard (
+    id          BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    content     TEXT         NOT NULL,
+    category_id BIGINT       NOT NULL,
+    user_id     BIGINT       NOT NULL,
+    created_at  DATETIME     NOT NULL,
+    updated_at  DATETIME     NULL DEFAULT NULL
+);
@@ -0,0 +1,8 @@
+ALTER TABLE flash_category
+    ADD COLUMN deleted_at DATETIME NULL DEFAULT NULL,
+    ADD INDEX idx_flash_category_user_deleted (user_id, deleted_at);
+
+ALTER TABLE flashcard
+    ADD COLUMN deleted_at DATETIME NULL DEFAULT NULL,
+    ADD INDEX idx_flashcard_user_category_deleted (user_id, category_id, deleted_at),
+    ADD INDEX idx_flashcard_deleted (deleted_at);
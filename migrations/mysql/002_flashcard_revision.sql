@@ -0,0 +1,14 @@
+CREATE TABLE flashcard_revision (
+    id           BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    flashcard_id BIGINT       NOT NULL,
+    user_id      BIGINT       NOT NULL,
+    revision     INT          NOT NULL,
+    author       VARCHAR(64)  NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    content      TEXT         NOT NULL,
+    category_id  BIGINT       NOT NULL,
+    diff         JSON         NOT NULL,
+    created_at   DATETIME     NOT NULL,
+    UNIQUE KEY uq_flashcard_revision (flashcard_id, revision),
+    INDEX idx_flashcard_revision_user (user_id, flashcard_id)
+);
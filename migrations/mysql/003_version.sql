@@ -0,0 +1,5 @@
+ALTER TABLE flash_category
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE flashcard
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,11 @@
+CREATE TABLE outbox (
+    id           BIGINT      NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    event_id     CHAR(36)    NOT NULL,
+    event_type   VARCHAR(64) NOT NULL,
+    user_id      BIGINT      NOT NULL,
+    payload      JSON        NOT NULL,
+    created_at   DATETIME    NOT NULL,
+    published_at DATETIME    NULL DEFAULT NULL,
+    UNIQUE KEY uq_outbox_event (event_id),
+    INDEX idx_outbox_unpublished (published_at, id)
+);
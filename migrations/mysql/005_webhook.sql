@@ -0,0 +1,31 @@
+CREATE TABLE webhook_subscription (
+    id          BIGINT        NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id     BIGINT        NOT NULL,
+    url         VARCHAR(2048) NOT NULL,
+    secret      VARCHAR(128)  NOT NULL,
+    event_types JSON          NOT NULL,
+    active      BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at  DATETIME      NOT NULL,
+    updated_at  DATETIME      NULL DEFAULT NULL,
+    INDEX idx_webhook_subscription_user (user_id, active)
+);
+
+CREATE TABLE webhook_delivery (
+    id               BIGINT        NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    subscription_id  BIGINT        NOT NULL,
+    user_id          BIGINT        NOT NULL,
+    event_id         CHAR(36)      NOT NULL,
+    event_type       VARCHAR(64)   NOT NULL,
+    payload          JSON          NOT NULL,
+    status           VARCHAR(16)   NOT NULL,
+    attempts         INT           NOT NULL DEFAULT 0,
+    next_attempt_at  DATETIME      NOT NULL,
+    last_status_code INT           NULL DEFAULT NULL,
+    last_error       VARCHAR(1024) NULL DEFAULT NULL,
+    created_at       DATETIME      NOT NULL,
+    updated_at       DATETIME      NULL DEFAULT NULL,
+    UNIQUE KEY uq_webhook_delivery_event (subscription_id, event_id),
+    INDEX idx_webhook_delivery_due (status, next_attempt_at),
+    CONSTRAINT fk_webhook_delivery_subscription FOREIGN KEY (subscription_id)
+        REFERENCES webhook_subscription (id) ON DELETE CASCADE
+);
@@ -0,0 +1,81 @@
+CREATE TABLE flash_category (
+    id         BIGSERIAL    PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    user_id    BIGINT       NOT NULL,
+    version    BIGINT       NOT NULL DEFAULT 1,
+    created_at TIMESTAMP    NOT NULL,
+    updated_at TIMESTAMP    NULL DEFAULT NULL,
+    deleted_at TIMESTAMP    NULL DEFAULT NULL
+);
+CREATE INDEX idx_flash_category_user_deleted ON flash_category (user_id, deleted_at);
+
+CREATE TABLE flashcard (
+    id          BIGSERIAL    PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    content     TEXT         NOT NULL,
+    category_id BIGINT       NOT NULL,
+    user_id     BIGINT       NOT NULL,
+    version     BIGINT       NOT NULL DEFAULT 1,
+    created_at  TIMESTAMP    NOT NULL,
+    updated_at  TIMESTAMP    NULL DEFAULT NULL,
+    deleted_at  TIMESTAMP    NULL DEFAULT NULL
+);
+CREATE INDEX idx_flashcard_user_category_deleted ON flashcard (user_id, category_id, deleted_at);
+CREATE INDEX idx_flashcard_deleted ON flashcard (deleted_at);
+
+CREATE TABLE flashcard_revision (
+    id           BIGSERIAL    PRIMARY KEY,
+    flashcard_id BIGINT       NOT NULL,
+    user_id      BIGINT       NOT NULL,
+    revision     INT          NOT NULL,
+    author       VARCHAR(64)  NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    content      TEXT         NOT NULL,
+    category_id  BIGINT       NOT NULL,
+    diff         JSONB        NOT NULL,
+    created_at   TIMESTAMP    NOT NULL,
+    CONSTRAINT uq_flashcard_revision UNIQUE (flashcard_id, revision)
+);
+CREATE INDEX idx_flashcard_revision_user ON flashcard_revision (user_id, flashcard_id);
+
+CREATE TABLE outbox (
+    id           BIGSERIAL   PRIMARY KEY,
+    event_id     CHAR(36)    NOT NULL,
+    event_type   VARCHAR(64) NOT NULL,
+    user_id      BIGINT      NOT NULL,
+    payload      JSONB       NOT NULL,
+    created_at   TIMESTAMP   NOT NULL,
+    published_at TIMESTAMP   NULL DEFAULT NULL,
+    CONSTRAINT uq_outbox_event UNIQUE (event_id)
+);
+CREATE INDEX idx_outbox_unpublished ON outbox (published_at, id);
+
+CREATE TABLE webhook_subscription (
+    id          BIGSERIAL     PRIMARY KEY,
+    user_id     BIGINT        NOT NULL,
+    url         VARCHAR(2048) NOT NULL,
+    secret      VARCHAR(128)  NOT NULL,
+    event_types JSONB         NOT NULL,
+    active      BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMP     NOT NULL,
+    updated_at  TIMESTAMP     NULL DEFAULT NULL
+);
+CREATE INDEX idx_webhook_subscription_user ON webhook_subscription (user_id, active);
+
+CREATE TABLE webhook_delivery (
+    id               BIGSERIAL     PRIMARY KEY,
+    subscription_id  BIGINT        NOT NULL REFERENCES webhook_subscription (id) ON DELETE CASCADE,
+    user_id          BIGINT        NOT NULL,
+    event_id         CHAR(36)      NOT NULL,
+    event_type       VARCHAR(64)   NOT NULL,
+    payload          JSONB         NOT NULL,
+    status           VARCHAR(16)   NOT NULL,
+    attempts         INT           NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP     NOT NULL,
+    last_status_code INT           NULL DEFAULT NULL,
+    last_error       VARCHAR(1024) NULL DEFAULT NULL,
+    created_at       TIMESTAMP     NOT NULL,
+    updated_at       TIMESTAMP     NULL DEFAULT NULL,
+    CONSTRAINT uq_webhook_delivery_event UNIQUE (subscription_id, event_id)
+);
+CREATE INDEX idx_webhook_delivery_due ON webhook_delivery (status, next_attempt_at);
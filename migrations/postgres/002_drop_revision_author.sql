@@ -0,0 +1,2 @@
+ALTER TABLE flashcard_revision
+    DROP COLUMN author;
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Every entry written by the binary codec starts with one of these format
+// bytes. Entries written before the codec existed are plain JSON objects and
+// start with '{', which no format byte uses.
+const (
+	formatMsgpack       byte = 0x01
+	formatMsgpackZstd   byte = 0x02
+	formatMsgpackSnappy byte = 0x03
+)
+
+const (
+	CompressionNone   = "none"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+)
+
+var ErrUnknownFormat = errors.New("cache entry has an unknown format")
+
+// Codec turns cached values into the bytes stored in Redis and back. Decode
+// accepts every format any codec may have written, so the encoding can be
+// switched without flushing the cache; an entry it cannot read returns
+// ErrUnknownFormat and should be treated as a miss.
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(data []byte, v any) error
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+type jsonCodec struct{}
+
+// NewJSONCodec writes entries in the JSON format used before the binary
+// codec, for rolling back to a build that cannot read anything else.
+func NewJSONCodec() Codec {
+	return jsonCodec{}
+}
+
+func (jsonCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(data []byte, v any) error {
+	return decode(data, v)
+}
+
+type msgpackCodec struct {
+	format           byte
+	minCompressBytes int
+}
+
+// NewMsgpackCodec encodes entries with MessagePack, using the json tags of the
+// models as field names. Entries of at least minCompressBytes are compressed
+// with compression.
+func NewMsgpackCodec(compression string, minCompressBytes int) (Codec, error) {
+	switch compression {
+	case CompressionNone, "":
+		return msgpackCodec{format: formatMsgpack}, nil
+	case CompressionZstd:
+		return msgpackCodec{format: formatMsgpackZstd, minCompressBytes: minCompressBytes}, nil
+	case CompressionSnappy:
+		return msgpackCodec{format: formatMsgpackSnappy, minCompressBytes: minCompressBytes}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache compression %q", compression)
+	}
+}
+
+// NewCodecFromEnv builds the codec configured by CACHE_CODEC (msgpack or
+// json), CACHE_COMPRESSION and CACHE_COMPRESSION_MIN_BYTES.
+func NewCodecFromEnv() (Codec, error) {
+	if os.Getenv("CACHE_CODEC") == "json" {
+		return NewJSONCodec(), nil
+	}
+	compression := os.Getenv("CACHE_COMPRESSION")
+	if compression == "" {
+		compression = CompressionZstd
+	}
+	minCompressBytes, err := strconv.Atoi(os.Getenv("CACHE_COMPRESSION_MIN_BYTES"))
+	if err != nil || minCompressBytes < 0 {
+		minCompressBytes = 512
+	}
+	return NewMsgpackCodec(compression, minCompressBytes)
+}
+
+func (m msgpackCodec) Encode(v any) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte(formatMsgpack)
+	encoder := msgpack.NewEncoder(&buffer)
+	encoder.SetCustomStructTag("json")
+	encoder.UseCompactInts(true)
+	err := encoder.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	data := buffer.Bytes()
+
+	if m.format == formatMsgpack || len(data)-1 < m.minCompressBytes {
+		return data, nil
+	}
+	var compressed []byte
+	switch m.format {
+	case formatMsgpackZstd:
+		compressed = zstdEncoder.EncodeAll(data[1:], []byte{formatMsgpackZstd})
+	case formatMsgpackSnappy:
+		compressed = append([]byte{formatMsgpackSnappy}, snappy.Encode(nil, data[1:])...)
+	}
+	// Small or random payloads can grow, keep whichever is shorter.
+	if len(compressed) >= len(data) {
+		return data, nil
+	}
+	return compressed, nil
+}
+
+func (m msgpackCodec) Decode(data []byte, v any) error {
+	return decode(data, v)
+}
+
+func decode(data []byte, v any) error {
+	if len(data) == 0 {
+		return ErrUnknownFormat
+	}
+	switch data[0] {
+	case '{':
+		return json.Unmarshal(data, v)
+	case formatMsgpack:
+		return decodeMsgpack(data[1:], v)
+	case formatMsgpackZstd:
+		payload, err := zstdDecoder.DecodeAll(data[1:], nil)
+		if err != nil {
+			return err
+		}
+		return decodeMsgpack(payload, v)
+	case formatMsgpackSnappy:
+		payload, err := snappy.Decode(nil, data[1:])
+		if err != nil {
+			return err
+		}
+		return decodeMsgpack(payload, v)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+func decodeMsgpack(data []byte, v any) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(v)
+}
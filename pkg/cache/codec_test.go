@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	Id        int64      `json:"id,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	values := map[string]entry{
+		"small": {Id: 1, Content: "hello", CreatedAt: &createdAt},
+		"large": {Id: 2, Content: strings.Repeat("lorem ipsum ", 500), CreatedAt: &createdAt},
+	}
+	codecs := map[string]Codec{"json": NewJSONCodec()}
+	for _, compression := range []string{CompressionNone, CompressionZstd, CompressionSnappy} {
+		codec, err := NewMsgpackCodec(compression, 256)
+		if err != nil {
+			t.Fatalf("NewMsgpackCodec(%q): %v", compression, err)
+		}
+		codecs[compression] = codec
+	}
+
+	for codecName, codec := range codecs {
+		for valueName, value := range values {
+			data, err := codec.Encode(value)
+			if err != nil {
+				t.Fatalf("%s/%s: Encode: %v", codecName, valueName, err)
+			}
+			var decoded entry
+			err = codec.Decode(data, &decoded)
+			if err != nil {
+				t.Fatalf("%s/%s: Decode: %v", codecName, valueName, err)
+			}
+			if decoded.Id != value.Id || decoded.Content != value.Content || !decoded.CreatedAt.Equal(*value.CreatedAt) {
+				t.Fatalf("%s/%s: decoded %+v, want %+v", codecName, valueName, decoded, value)
+			}
+		}
+	}
+}
+
+func TestCodecCompressesOnlyLargeEntries(t *testing.T) {
+	codec, _ := NewMsgpackCodec(CompressionZstd, 256)
+
+	small, _ := codec.Encode(entry{Id: 1, Content: "hello"})
+	if small[0] != formatMsgpack {
+		t.Fatalf("small entry has format %#x, want %#x", small[0], formatMsgpack)
+	}
+	large, _ := codec.Encode(entry{Id: 2, Content: strings.Repeat("lorem ipsum ", 500)})
+	if large[0] != formatMsgpackZstd {
+		t.Fatalf("large entry has format %#x, want %#x", large[0], formatMsgpackZstd)
+	}
+	if len(large) > 500 {
+		t.Fatalf("large entry is %d bytes, compression did not apply", len(large))
+	}
+}
+
+func TestCodecReadsAnyFormat(t *testing.T) {
+	zstdCodec, _ := NewMsgpackCodec(CompressionZstd, 0)
+	data, _ := zstdCodec.Encode(entry{Id: 3, Content: strings.Repeat("x", 100)})
+
+	var decoded entry
+	err := NewJSONCodec().Decode(data, &decoded)
+	if err != nil || decoded.Id != 3 {
+		t.Fatalf("json codec could not read a zstd entry: %+v, %v", decoded, err)
+	}
+
+	decoded = entry{}
+	err = zstdCodec.Decode([]byte(`{"id":4,"content":"legacy"}`), &decoded)
+	if err != nil || decoded.Id != 4 || decoded.Content != "legacy" {
+		t.Fatalf("msgpack codec could not read a legacy entry: %+v, %v", decoded, err)
+	}
+}
+
+func TestCodecRejectsUnknownFormat(t *testing.T) {
+	codec, _ := NewMsgpackCodec(CompressionNone, 0)
+	for _, data := range [][]byte{nil, {0x7f, 0x01}} {
+		var decoded entry
+		err := codec.Decode(data, &decoded)
+		if !errors.Is(err, ErrUnknownFormat) {
+			t.Fatalf("Decode(%v) = %v, want ErrUnknownFormat", data, err)
+		}
+	}
+}
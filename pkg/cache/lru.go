@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type LRUStats struct {
+	Entries   int   `json:"entries"`
+	Items     int   `json:"items"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// LRU is an in-process cache that evicts the least recently used entries once
+// the summed size of its entries exceeds maxItems, and drops entries older
+// than ttl. Values are shared between callers and must not be modified.
+//
+// Every Delete bumps an epoch. A value read from a slower tier is only stored
+// with SetIfEpoch if no Delete happened since the caller took Epoch, so a
+// concurrent invalidation cannot be undone by a stale fill.
+type LRU struct {
+	mu        sync.Mutex
+	maxItems  int
+	ttl       time.Duration
+	items     map[string]*list.Element
+	order     *list.List
+	size      int
+	epoch     uint64
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type lruEntry struct {
+	key       string
+	value     any
+	size      int
+	expiresAt time.Time
+}
+
+func NewLRU(maxItems int, ttl time.Duration) *LRU {
+	return &LRU{
+		maxItems: maxItems,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (l *LRU) Get(key string) (any, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.items[key]
+	if !ok {
+		l.misses++
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.remove(element)
+		l.misses++
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	l.hits++
+	return entry.value, true
+}
+
+func (l *LRU) Epoch() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.epoch
+}
+
+// SetIfEpoch stores value under key, counting size items toward maxItems, and
+// reports whether it did. Values larger than maxItems are never stored.
+func (l *LRU) SetIfEpoch(key string, value any, size int, epoch uint64) bool {
+	size = max(size, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if epoch != l.epoch || size > l.maxItems {
+		return false
+	}
+
+	if element, ok := l.items[key]; ok {
+		l.remove(element)
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{
+		key:       key,
+		value:     value,
+		size:      size,
+		expiresAt: time.Now().Add(l.ttl),
+	})
+	l.size += size
+	for l.size > l.maxItems {
+		l.remove(l.order.Back())
+		l.evictions++
+	}
+	return true
+}
+
+func (l *LRU) Delete(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.epoch++
+	for _, key := range keys {
+		if element, ok := l.items[key]; ok {
+			l.remove(element)
+		}
+	}
+}
+
+// Purge drops every entry.
+func (l *LRU) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.epoch++
+	l.items = make(map[string]*list.Element)
+	l.order.Init()
+	l.size = 0
+}
+
+func (l *LRU) Stats() LRUStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LRUStats{
+		Entries:   len(l.items),
+		Items:     l.size,
+		Hits:      l.hits,
+		Misses:    l.misses,
+		Evictions: l.evictions,
+	}
+}
+
+func (l *LRU) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+	l.order.Remove(element)
+	delete(l.items, entry.key)
+	l.size -= entry.size
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	l := NewLRU(3, time.Minute)
+	l.SetIfEpoch("a", 1, 1, l.Epoch())
+	l.SetIfEpoch("b", 2, 1, l.Epoch())
+	l.SetIfEpoch("c", 3, 1, l.Epoch())
+	l.Get("a")
+	l.SetIfEpoch("d", 4, 1, l.Epoch())
+
+	if _, ok := l.Get("b"); ok {
+		t.Fatal("b survived although it was least recently used")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := l.Get(key); !ok {
+			t.Fatalf("%s was evicted", key)
+		}
+	}
+	if stats := l.Stats(); stats.Evictions != 1 || stats.Entries != 3 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestLRUCountsEntrySize(t *testing.T) {
+	l := NewLRU(10, time.Minute)
+	l.SetIfEpoch("small", "x", 4, l.Epoch())
+	l.SetIfEpoch("large", "y", 8, l.Epoch())
+
+	if _, ok := l.Get("small"); ok {
+		t.Fatal("small survived although the cache was over its size")
+	}
+	if l.SetIfEpoch("huge", "z", 11, l.Epoch()) {
+		t.Fatal("stored an entry larger than the cache")
+	}
+	if stats := l.Stats(); stats.Items != 8 {
+		t.Fatalf("items = %d, want 8", stats.Items)
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	l := NewLRU(10, 10*time.Millisecond)
+	l.SetIfEpoch("a", 1, 1, l.Epoch())
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := l.Get("a"); ok {
+		t.Fatal("expired entry was served")
+	}
+}
+
+func TestLRURejectsFillAfterDelete(t *testing.T) {
+	l := NewLRU(10, time.Minute)
+	epoch := l.Epoch()
+	l.Delete("a")
+
+	if l.SetIfEpoch("a", "stale", 1, epoch) {
+		t.Fatal("stale fill was stored after a delete")
+	}
+}
+
+func TestLRUConcurrentAccess(t *testing.T) {
+	l := NewLRU(50, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa((i + j) % 100)
+				l.SetIfEpoch(key, j, 1, l.Epoch())
+				l.Get(key)
+				if j%10 == 0 {
+					l.Delete(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := l.Stats(); stats.Items > 50 {
+		t.Fatalf("items = %d, over the limit", stats.Items)
+	}
+}
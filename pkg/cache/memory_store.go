@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by MemoryStore reads of a missing key or field.
+var ErrNotFound = errors.New("cache: key not found")
+
+// MemoryStore is a Store kept in process memory. Pub/Sub only reaches
+// subscribers of the same store, so it is for a single instance.
+type MemoryStore struct {
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	subscribers map[string]map[chan string]struct{}
+}
+
+type memoryEntry struct {
+	value     string
+	hash      map[string]string
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:     make(map[string]memoryEntry),
+		subscribers: make(map[string]map[chan string]struct{}),
+	}
+}
+
+// lookup returns the live entry at key, dropping it once expired. The caller
+// holds mu.
+func (m *MemoryStore) lookup(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+func expiresAt(expiredTimeInSec int64) time.Time {
+	if expiredTimeInSec <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(expiredTimeInSec) * time.Second)
+}
+
+func (m *MemoryStore) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.lookup(key)
+	if !ok || entry.hash != nil {
+		return "", ErrNotFound
+	}
+	return entry.value, nil
+}
+
+func (m *MemoryStore) Set(key string, value string, expiredTimeInSec int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{value: value, expiresAt: expiresAt(expiredTimeInSec)}
+	return nil
+}
+
+func (m *MemoryStore) SetNX(key string, value string, expiredTimeInSec int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+	m.entries[key] = memoryEntry{value: value, expiresAt: expiresAt(expiredTimeInSec)}
+	return true, nil
+}
+
+func (m *MemoryStore) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MemoryStore) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.lookup(key)
+	return ok, nil
+}
+
+func (m *MemoryStore) HGet(key, field string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.lookup(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	value, ok := entry.hash[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (m *MemoryStore) HGetAll(key string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, _ := m.lookup(key)
+	values := make(map[string]string, len(entry.hash))
+	for field, value := range entry.hash {
+		values[field] = value
+	}
+	return values, nil
+}
+
+func (m *MemoryStore) GetGeneration(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generation(key)
+}
+
+func (m *MemoryStore) generation(key string) (int64, error) {
+	entry, ok := m.lookup(key)
+	if !ok {
+		return 0, nil
+	}
+	return strconv.ParseInt(entry.value, 10, 64)
+}
+
+func (m *MemoryStore) Invalidate(generationKey string, generationExpiry time.Duration, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	generation, err := m.generation(generationKey)
+	if err != nil {
+		return err
+	}
+	m.entries[generationKey] = memoryEntry{
+		value:     strconv.FormatInt(generation+1, 10),
+		expiresAt: time.Now().Add(generationExpiry),
+	}
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *MemoryStore) HMSetWithExpiryIfGeneration(generationKey string, generation int64, key string, fields map[string]any, expiredTimeInSec int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := m.generation(generationKey)
+	if err != nil {
+		return false, err
+	}
+	if current != generation {
+		return false, nil
+	}
+	hash := make(map[string]string, len(fields))
+	for field, value := range fields {
+		switch v := value.(type) {
+		case string:
+			hash[field] = v
+		case []byte:
+			hash[field] = string(v)
+		default:
+			hash[field] = fmt.Sprint(v)
+		}
+	}
+	m.entries[key] = memoryEntry{hash: hash, expiresAt: expiresAt(expiredTimeInSec)}
+	return true, nil
+}
+
+func (m *MemoryStore) PatchHash(generationKey string, generation int64, generationExpiry time.Duration, key string, field string, value string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := m.generation(generationKey)
+	if err != nil {
+		return false, err
+	}
+	m.entries[generationKey] = memoryEntry{
+		value:     strconv.FormatInt(current+1, 10),
+		expiresAt: time.Now().Add(generationExpiry),
+	}
+	entry, ok := m.lookup(key)
+	if _, found := entry.hash[field]; !ok || !found || current != generation {
+		delete(m.entries, key)
+		return false, nil
+	}
+	entry.hash[field] = value
+	return true, nil
+}
+
+// Publish hands message to the current subscribers of channel. Like Redis it
+// does not wait for slow subscribers; a subscriber that is not receiving
+// misses the message.
+func (m *MemoryStore) Publish(channel string, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for subscriber := range m.subscribers[channel] {
+		select {
+		case subscriber <- message:
+		default:
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Subscribe(ctx context.Context, channels ...string) (<-chan string, func() error, error) {
+	subscriber := make(chan string, 64)
+	m.mu.Lock()
+	for _, channel := range channels {
+		if m.subscribers[channel] == nil {
+			m.subscribers[channel] = make(map[chan string]struct{})
+		}
+		m.subscribers[channel][subscriber] = struct{}{}
+	}
+	m.mu.Unlock()
+
+	closed := make(chan struct{})
+	var once sync.Once
+	closeSubscription := func() error {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			for _, channel := range channels {
+				delete(m.subscribers[channel], subscriber)
+			}
+			close(subscriber)
+			close(closed)
+		})
+		return nil
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeSubscription()
+		case <-closed:
+		}
+	}()
+	return subscriber, closeSubscription, nil
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSkipsFillFromOldGeneration(t *testing.T) {
+	m := NewMemoryStore()
+	generation, err := m.GetGeneration("gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Invalidate("gen", time.Minute, "list"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := m.HMSetWithExpiryIfGeneration("gen", generation, "list", map[string]any{"1": "a"}, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored {
+		t.Fatal("stored a fill read before the invalidation")
+	}
+	if values, _ := m.HGetAll("list"); len(values) != 0 {
+		t.Fatalf("list = %v", values)
+	}
+}
+
+func TestMemoryStorePatchesHashOnlyFromCurrentGeneration(t *testing.T) {
+	m := NewMemoryStore()
+	if _, err := m.HMSetWithExpiryIfGeneration("gen", 0, "list", map[string]any{"1": "a", "2": "b"}, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := m.PatchHash("gen", 0, time.Minute, "list", "1", "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := m.HGetAll("list"); !patched || values["1"] != "A" || values["2"] != "b" {
+		t.Fatalf("patched = %v, list = %v", patched, values)
+	}
+
+	// The generation moved with the first patch, so a second one based on
+	// generation 0 drops the list.
+	patched, err = m.PatchHash("gen", 0, time.Minute, "list", "2", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, _ := m.HGetAll("list"); patched || len(values) != 0 {
+		t.Fatalf("patched = %v, list = %v", patched, values)
+	}
+	if generation, _ := m.GetGeneration("gen"); generation != 2 {
+		t.Fatalf("generation = %d, want 2", generation)
+	}
+}
+
+func TestMemoryStoreDeliversToSubscribers(t *testing.T) {
+	m := NewMemoryStore()
+	messages, closeFn, err := m.Subscribe(context.Background(), "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeFn()
+
+	if err := m.Publish("events", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-messages:
+		if message != "hello" {
+			t.Fatalf("message = %q", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message delivered")
+	}
+}
@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the shared cache behind CategoryService, the idempotency keys and
+// the per-user event channels. RedisDatabase is the production store;
+// MemoryStore serves a single process in development and tests.
+type Store interface {
+	Get(key string) (string, error)
+	Set(key string, value string, expiredTimeInSec int64) error
+	SetNX(key string, value string, expiredTimeInSec int64) (bool, error)
+	Del(key string) error
+	Exists(key string) (bool, error)
+	HGet(key, field string) (string, error)
+	HGetAll(key string) (map[string]string, error)
+	GetGeneration(key string) (int64, error)
+	Invalidate(generationKey string, generationExpiry time.Duration, keys ...string) error
+	HMSetWithExpiryIfGeneration(generationKey string, generation int64, key string, fields map[string]any, expiredTimeInSec int64) (bool, error)
+	PatchHash(generationKey string, generation int64, generationExpiry time.Duration, key string, field string, value string) (bool, error)
+	Publish(channel string, message string) error
+	Subscribe(ctx context.Context, channels ...string) (<-chan string, func() error, error)
+}
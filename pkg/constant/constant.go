@@ -16,5 +16,12 @@ const (
 )
 
 const (
-	UserIdHeader string = "user_id"
+	UserIdHeader      string = "user_id"
+	IfMatchHeader     string = "If-Match"
+	IfNoneMatchHeader string = "If-None-Match"
+	ETagHeader        string = "ETag"
+	LocationHeader    string = "Location"
+
+	IdempotencyKeyHeader     string = "Idempotency-Key"
+	IdempotentReplayedHeader string = "Idempotent-Replayed"
 )
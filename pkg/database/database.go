@@ -5,11 +5,51 @@ import (
 	"database/sql"
 )
 
+// Dialect names the SQL flavour of a database, for the few statements that
+// cannot be written portably.
+type Dialect string
+
+const (
+	DialectMySQL    Dialect = "mysql"
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+type Executor interface {
+	Dialect() Dialect
+	QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error)
+	QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error)
+	Exec(query string, args ...any) (sql.Result, context.CancelFunc, error)
+}
+
+type Transaction interface {
+	Executor
+	Commit() error
+	Rollback() error
+}
+
 type Database interface {
 	Ping() error
 	Connect() error
 	Close() error
-	QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error)
-	QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error)
-	Exec(query string, args ...any) (sql.Result, context.CancelFunc, error)
+	Begin() (Transaction, error)
+	// Primary returns a view of the database whose reads go to the primary
+	// even when replicas are configured.
+	Primary() Database
+	Executor
+}
+
+// WithTransaction runs fn inside a transaction, committing when fn returns nil
+// and rolling back otherwise.
+func WithTransaction(db Database, fn func(tx Transaction) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
@@ -6,16 +6,32 @@ import (
 	"flashcard_service/pkg/database"
 	"os"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/rs/zerolog/log"
 )
 
+// MySql sends writes and transactions to the primary. Reads outside a
+// transaction go round-robin to the healthy replicas, or to the primary when
+// there are none.
 type MySql struct {
-	conn         string
-	db           *sql.DB
-	queryTimeout time.Duration
+	conn                string
+	replicaConns        []string
+	db                  *sql.DB
+	replicas            []*replica
+	next                atomic.Uint64
+	healthCheckInterval time.Duration
+	stopHealthCheck     chan struct{}
+	queryTimeout        time.Duration
+}
+
+type replica struct {
+	addr    string
+	db      *sql.DB
+	healthy atomic.Bool
 }
 
 func NewMySql() database.Database {
@@ -29,30 +45,142 @@ func NewMySql() database.Database {
 		ParseTime: true,
 	}
 	queryTimeout, _ := strconv.Atoi(os.Getenv("MYSQL_QUERY_TIMEOUT_BY_SECOND"))
+	healthCheckInterval, err := strconv.Atoi(os.Getenv("MYSQL_REPLICA_HEALTH_CHECK_INTERVAL_BY_SECOND"))
+	if err != nil || healthCheckInterval <= 0 {
+		healthCheckInterval = 5
+	}
+
+	// Replicas share the credentials and schema of the primary.
+	replicaConns := make([]string, 0)
+	for _, addr := range strings.Split(os.Getenv("MYSQL_REPLICA_URLS"), ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		replicaConfig := config
+		replicaConfig.Addr = addr
+		replicaConns = append(replicaConns, replicaConfig.FormatDSN())
+	}
+
 	return &MySql{
-		conn:         config.FormatDSN(),
-		queryTimeout: time.Second * time.Duration(queryTimeout),
+		conn:                config.FormatDSN(),
+		replicaConns:        replicaConns,
+		healthCheckInterval: time.Second * time.Duration(healthCheckInterval),
+		queryTimeout:        time.Second * time.Duration(queryTimeout),
 	}
 }
 
 func (m *MySql) Connect() error {
-	db, err := sql.Open("mysql", m.conn)
+	db, err := open(m.conn)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to connect to MySQL database")
 		return err
 	}
 	m.db = db
+	log.Info().Msg("Connected to MySQL database")
+	err = m.Ping()
+	if err != nil {
+		return err
+	}
+
+	for _, conn := range m.replicaConns {
+		replicaDb, err := open(conn)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to connect to MySQL replica")
+			return err
+		}
+		r := &replica{db: replicaDb}
+		parsed, err := mysql.ParseDSN(conn)
+		if err == nil {
+			r.addr = parsed.Addr
+		}
+		m.replicas = append(m.replicas, r)
+	}
+	if len(m.replicas) > 0 {
+		m.checkReplicas()
+		m.stopHealthCheck = make(chan struct{})
+		go m.runHealthCheck()
+		log.Info().Int("replicas", len(m.replicas)).Msg("Connected to MySQL replicas")
+	}
+	return nil
+}
+
+func open(conn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", conn)
+	if err != nil {
+		return nil, err
+	}
 	db.SetConnMaxLifetime(time.Minute * 3)
 	db.SetConnMaxIdleTime(time.Minute * 3)
 	maxOpenCons, _ := strconv.Atoi(os.Getenv("MYSQL_POOL_MAX_OPEN_CONNECTION"))
 	maxIdConst, _ := strconv.Atoi(os.Getenv("MYSQL_POOL_MAX_IDLE_CONNECTION"))
 	db.SetMaxIdleConns(maxIdConst)
 	db.SetMaxOpenConns(maxOpenCons)
-	log.Info().Msg("Connected to MySQL database")
-	return m.Ping()
+	return db, nil
+}
+
+func (m *MySql) runHealthCheck() {
+	ticker := time.NewTicker(m.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.checkReplicas()
+		case <-m.stopHealthCheck:
+			return
+		}
+	}
+}
+
+func (m *MySql) checkReplicas() {
+	for _, r := range m.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), m.healthCheckInterval)
+		err := r.db.PingContext(ctx)
+		cancel()
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Info().Str("replica", r.addr).Msg("MySQL replica is healthy")
+			} else {
+				log.Error().Str("replica", r.addr).Str("error", "error when ping MySQL replica: "+err.Error()).Msg("")
+			}
+		}
+	}
+}
+
+// reader picks the next healthy replica, falling back to the primary.
+func (m *MySql) reader() *sql.DB {
+	count := len(m.replicas)
+	if count == 0 {
+		return m.db
+	}
+	start := m.next.Add(1)
+	for i := 0; i < count; i++ {
+		r := m.replicas[(start+uint64(i))%uint64(count)]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return m.db
+}
+
+// Primary returns a view of the database whose reads also go to the primary,
+// for reads that must see a write that was just committed.
+func (m *MySql) Primary() database.Database {
+	return &primaryMySql{MySql: m}
+}
+
+func (m *MySql) Dialect() database.Dialect {
+	return database.DialectMySQL
 }
 
 func (m *MySql) Close() error {
+	if m.stopHealthCheck != nil {
+		close(m.stopHealthCheck)
+	}
+	for _, r := range m.replicas {
+		r.db.Close()
+	}
 	err := m.db.Close()
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to close MySQL database connection")
@@ -74,7 +202,7 @@ func (m *MySql) Ping() error {
 
 func (m *MySql) QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), m.queryTimeout)
-	rows, err := m.db.QueryContext(ctx, query, args...)
+	rows, err := m.reader().QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, cancel, err
 	}
@@ -92,7 +220,85 @@ func (m *MySql) Exec(query string, args ...any) (sql.Result, context.CancelFunc,
 
 func (m *MySql) QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), m.queryTimeout)
-	row := m.db.QueryRowContext(ctx, query, args...)
+	row := m.reader().QueryRowContext(ctx, query, args...)
+	if row.Err() != nil {
+		return nil, cancel, row.Err()
+	}
+	return row, cancel, nil
+}
+
+type primaryMySql struct {
+	*MySql
+}
+
+func (p *primaryMySql) Primary() database.Database {
+	return p
+}
+
+func (p *primaryMySql) QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return rows, cancel, nil
+}
+
+func (p *primaryMySql) QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	row := p.db.QueryRowContext(ctx, query, args...)
+	if row.Err() != nil {
+		return nil, cancel, row.Err()
+	}
+	return row, cancel, nil
+}
+
+func (m *MySql) Begin() (database.Transaction, error) {
+	tx, err := m.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &mySqlTx{tx: tx, queryTimeout: m.queryTimeout}, nil
+}
+
+type mySqlTx struct {
+	tx           *sql.Tx
+	queryTimeout time.Duration
+}
+
+func (t *mySqlTx) Dialect() database.Dialect {
+	return database.DialectMySQL
+}
+
+func (t *mySqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *mySqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (t *mySqlTx) QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return rows, cancel, nil
+}
+
+func (t *mySqlTx) Exec(query string, args ...any) (sql.Result, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	r, err := t.tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return r, cancel, nil
+}
+
+func (t *mySqlTx) QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	row := t.tx.QueryRowContext(ctx, query, args...)
 	if row.Err() != nil {
 		return nil, cancel, row.Err()
 	}
@@ -0,0 +1,31 @@
+package mysql_test
+
+import (
+	"flashcard_service/internal/repositories/repotest"
+	"flashcard_service/pkg/database/mysql"
+	"os"
+	"testing"
+)
+
+// TestRepositoryContract runs against the database named by MYSQL_TEST_DB,
+// which it empties, using the other MYSQL_* settings to connect. The schema is
+// migrations/mysql, applied in order.
+func TestRepositoryContract(t *testing.T) {
+	testDb := os.Getenv("MYSQL_TEST_DB")
+	if testDb == "" {
+		t.Skip("MYSQL_TEST_DB is not set")
+	}
+	t.Setenv("MYSQL_DB", testDb)
+	t.Setenv("MYSQL_REPLICA_URLS", "")
+	if os.Getenv("MYSQL_QUERY_TIMEOUT_BY_SECOND") == "" {
+		t.Setenv("MYSQL_QUERY_TIMEOUT_BY_SECOND", "15")
+	}
+
+	db := mysql.NewMySql()
+	err := db.Connect()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+	repotest.Run(t, db)
+}
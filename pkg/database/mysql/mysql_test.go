@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func newTestMySql(t *testing.T, replicas int) *MySql {
+	t.Helper()
+	open := func() *sql.DB {
+		// sql.Open does not connect, which is all reader needs.
+		db, err := sql.Open("mysql", "user:pass@tcp(127.0.0.1:1)/db")
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	m := &MySql{db: open()}
+	for i := 0; i < replicas; i++ {
+		r := &replica{db: open()}
+		r.healthy.Store(true)
+		m.replicas = append(m.replicas, r)
+	}
+	return m
+}
+
+func TestReaderRoundRobinsHealthyReplicas(t *testing.T) {
+	m := newTestMySql(t, 3)
+	m.replicas[1].healthy.Store(false)
+
+	seen := make(map[*sql.DB]int)
+	for i := 0; i < 10; i++ {
+		seen[m.reader()]++
+	}
+	if seen[m.replicas[1].db] != 0 {
+		t.Fatal("unhealthy replica was used")
+	}
+	if seen[m.db] != 0 {
+		t.Fatal("primary was used while replicas are healthy")
+	}
+	if seen[m.replicas[0].db] == 0 || seen[m.replicas[2].db] == 0 {
+		t.Fatalf("reads were not spread over the healthy replicas: %v", seen)
+	}
+}
+
+func TestReaderFallsBackToPrimary(t *testing.T) {
+	m := newTestMySql(t, 2)
+	for _, r := range m.replicas {
+		r.healthy.Store(false)
+	}
+	if m.reader() != m.db {
+		t.Fatal("expected the primary when no replica is healthy")
+	}
+	if newTestMySql(t, 0).reader() == nil {
+		t.Fatal("expected the primary without replicas")
+	}
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"flashcard_service/pkg/database"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/rs/zerolog/log"
+)
+
+// Postgres runs the repositories' SQL on PostgreSQL. Queries are written with
+// MySQL style ? placeholders and rebound to $n before they are sent.
+type Postgres struct {
+	conn         string
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgres() database.Database {
+	sslMode := os.Getenv("POSTGRES_SSLMODE")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	conn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASSWORD")),
+		Host:     os.Getenv("POSTGRES_URL"),
+		Path:     "/" + os.Getenv("POSTGRES_DB"),
+		RawQuery: url.Values{"sslmode": []string{sslMode}}.Encode(),
+	}
+	queryTimeout, _ := strconv.Atoi(os.Getenv("POSTGRES_QUERY_TIMEOUT_BY_SECOND"))
+	return &Postgres{
+		conn:         conn.String(),
+		queryTimeout: time.Second * time.Duration(queryTimeout),
+	}
+}
+
+func (p *Postgres) Connect() error {
+	db, err := sql.Open("pgx", p.conn)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to Postgres database")
+		return err
+	}
+	p.db = db
+	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetConnMaxIdleTime(time.Minute * 3)
+	maxOpenCons, _ := strconv.Atoi(os.Getenv("POSTGRES_POOL_MAX_OPEN_CONNECTION"))
+	maxIdleCons, _ := strconv.Atoi(os.Getenv("POSTGRES_POOL_MAX_IDLE_CONNECTION"))
+	db.SetMaxIdleConns(maxIdleCons)
+	db.SetMaxOpenConns(maxOpenCons)
+	log.Info().Msg("Connected to Postgres database")
+	return p.Ping()
+}
+
+func (p *Postgres) Dialect() database.Dialect {
+	return database.DialectPostgres
+}
+
+func (p *Postgres) Close() error {
+	err := p.db.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to close Postgres database connection")
+		return err
+	}
+	log.Info().Msg("Closed Postgres database connection")
+	return nil
+}
+
+func (p *Postgres) Ping() error {
+	err := p.db.Ping()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to ping Postgres database")
+		return err
+	}
+	log.Info().Msg("Pinged Postgres database successfully")
+	return nil
+}
+
+// Primary returns the database itself, reads are not split to replicas.
+func (p *Postgres) Primary() database.Database {
+	return p
+}
+
+func (p *Postgres) QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	rows, err := p.db.QueryContext(ctx, Rebind(query), args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return rows, cancel, nil
+}
+
+func (p *Postgres) Exec(query string, args ...any) (sql.Result, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	r, err := p.db.ExecContext(ctx, Rebind(query), args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return r, cancel, nil
+}
+
+func (p *Postgres) QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.queryTimeout)
+	row := p.db.QueryRowContext(ctx, Rebind(query), args...)
+	if row.Err() != nil {
+		return nil, cancel, row.Err()
+	}
+	return row, cancel, nil
+}
+
+func (p *Postgres) Begin() (database.Transaction, error) {
+	tx, err := p.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresTx{tx: tx, queryTimeout: p.queryTimeout}, nil
+}
+
+type postgresTx struct {
+	tx           *sql.Tx
+	queryTimeout time.Duration
+}
+
+func (t *postgresTx) Dialect() database.Dialect {
+	return database.DialectPostgres
+}
+
+func (t *postgresTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *postgresTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (t *postgresTx) QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	rows, err := t.tx.QueryContext(ctx, Rebind(query), args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return rows, cancel, nil
+}
+
+func (t *postgresTx) Exec(query string, args ...any) (sql.Result, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	r, err := t.tx.ExecContext(ctx, Rebind(query), args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return r, cancel, nil
+}
+
+func (t *postgresTx) QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	row := t.tx.QueryRowContext(ctx, Rebind(query), args...)
+	if row.Err() != nil {
+		return nil, cancel, row.Err()
+	}
+	return row, cancel, nil
+}
+
+// Rebind replaces the ? placeholders of query with $1, $2, ... leaving
+// question marks inside string literals and quoted identifiers alone.
+func Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
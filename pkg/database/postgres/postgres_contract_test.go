@@ -0,0 +1,30 @@
+package postgres_test
+
+import (
+	"flashcard_service/internal/repositories/repotest"
+	"flashcard_service/pkg/database/postgres"
+	"os"
+	"testing"
+)
+
+// TestRepositoryContract runs against the database named by
+// POSTGRES_TEST_DB, which it empties, using the other POSTGRES_* settings to
+// connect. The schema is migrations/postgres.
+func TestRepositoryContract(t *testing.T) {
+	testDb := os.Getenv("POSTGRES_TEST_DB")
+	if testDb == "" {
+		t.Skip("POSTGRES_TEST_DB is not set")
+	}
+	t.Setenv("POSTGRES_DB", testDb)
+	if os.Getenv("POSTGRES_QUERY_TIMEOUT_BY_SECOND") == "" {
+		t.Setenv("POSTGRES_QUERY_TIMEOUT_BY_SECOND", "15")
+	}
+
+	db := postgres.NewPostgres()
+	err := db.Connect()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+	repotest.Run(t, db)
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"UPDATE t SET a = ? WHERE id IN (?,?,?)", "UPDATE t SET a = $1 WHERE id IN ($2,$3,$4)"},
+		{"SELECT '?' FROM t WHERE a = ?", "SELECT '?' FROM t WHERE a = $1"},
+		{`SELECT "a?" FROM t WHERE b = ?`, `SELECT "a?" FROM t WHERE b = $1`},
+		{"SELECT 'it''s ?' WHERE a = ?", "SELECT 'it''s ?' WHERE a = $1"},
+	}
+	for _, test := range tests {
+		got := Rebind(test.query)
+		if got != test.want {
+			t.Fatalf("Rebind(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
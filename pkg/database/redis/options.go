@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// newClientFromEnv builds the client for REDIS_MODE. REDIS_URL is a comma
+// separated list of addresses: the server in standalone mode, the sentinels
+// in sentinel mode and the seed nodes in cluster mode. Without REDIS_MODE the
+// mode is guessed the way redis.NewUniversalClient does.
+func newClientFromEnv() (redis.UniversalClient, error) {
+	options, err := universalOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	switch os.Getenv("REDIS_MODE") {
+	case ModeStandalone:
+		return redis.NewClient(options.Simple()), nil
+	case ModeSentinel:
+		if options.MasterName == "" {
+			return nil, errors.New("REDIS_MASTER_NAME is required in sentinel mode")
+		}
+		return redis.NewFailoverClient(options.Failover()), nil
+	case ModeCluster:
+		return redis.NewClusterClient(options.Cluster()), nil
+	case "":
+		return redis.NewUniversalClient(options), nil
+	default:
+		return nil, errors.New("unknown REDIS_MODE " + os.Getenv("REDIS_MODE"))
+	}
+}
+
+func universalOptionsFromEnv() (*redis.UniversalOptions, error) {
+	db, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+	poolSize, _ := strconv.Atoi(os.Getenv("REDIS_POOL_SIZE"))
+	minIdleConns, _ := strconv.Atoi(os.Getenv("REDIS_MIN_IDLE_CONNS"))
+	poolTimeoutSeconds, _ := strconv.Atoi(os.Getenv("REDIS_POOL_TIMEOUT_BY_SECOND"))
+
+	addrs := make([]string, 0)
+	for _, addr := range strings.Split(os.Getenv("REDIS_URL"), ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	tlsConfig, err := tlsConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &redis.UniversalOptions{
+		Addrs:            addrs,
+		DB:               db,
+		Username:         os.Getenv("REDIS_USERNAME"),
+		Password:         os.Getenv("REDIS_PASSWORD"),
+		MasterName:       os.Getenv("REDIS_MASTER_NAME"),
+		SentinelUsername: os.Getenv("REDIS_SENTINEL_USERNAME"),
+		SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+		PoolSize:         poolSize,
+		MinIdleConns:     minIdleConns,
+		PoolTimeout:      time.Duration(poolTimeoutSeconds) * time.Second,
+		TLSConfig:        tlsConfig,
+	}, nil
+}
+
+// tlsConfigFromEnv returns nil unless REDIS_TLS is true. REDIS_TLS_CA_FILE
+// adds a PEM bundle to trust on top of the system roots.
+func tlsConfigFromEnv() (*tls.Config, error) {
+	enabled, _ := strconv.ParseBool(os.Getenv("REDIS_TLS"))
+	if !enabled {
+		return nil, nil
+	}
+
+	insecureSkipVerify, _ := strconv.ParseBool(os.Getenv("REDIS_TLS_INSECURE_SKIP_VERIFY"))
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         os.Getenv("REDIS_TLS_SERVER_NAME"),
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	caFile := os.Getenv("REDIS_TLS_CA_FILE")
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in REDIS_TLS_CA_FILE")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewClientFromEnvPicksMode(t *testing.T) {
+	tests := []struct {
+		mode       string
+		url        string
+		masterName string
+		check      func(redis.UniversalClient) bool
+	}{
+		{"", "localhost:6379", "", func(c redis.UniversalClient) bool { _, ok := c.(*redis.Client); return ok }},
+		{"", "a:6379,b:6379", "", func(c redis.UniversalClient) bool { _, ok := c.(*redis.ClusterClient); return ok }},
+		{"", "a:26379,b:26379", "mymaster", func(c redis.UniversalClient) bool { _, ok := c.(*redis.Client); return ok }},
+		{ModeCluster, "a:6379", "", func(c redis.UniversalClient) bool { _, ok := c.(*redis.ClusterClient); return ok }},
+		{ModeSentinel, "a:26379", "mymaster", func(c redis.UniversalClient) bool { _, ok := c.(*redis.Client); return ok }},
+	}
+
+	for _, test := range tests {
+		t.Setenv("REDIS_MODE", test.mode)
+		t.Setenv("REDIS_URL", test.url)
+		t.Setenv("REDIS_MASTER_NAME", test.masterName)
+		client, err := newClientFromEnv()
+		if err != nil {
+			t.Fatalf("mode %q url %q: %v", test.mode, test.url, err)
+		}
+		if !test.check(client) {
+			t.Fatalf("mode %q url %q: got %T", test.mode, test.url, client)
+		}
+		client.Close()
+	}
+}
+
+func TestNewClientFromEnvRejectsSentinelWithoutMaster(t *testing.T) {
+	t.Setenv("REDIS_MODE", ModeSentinel)
+	t.Setenv("REDIS_URL", "a:26379")
+	t.Setenv("REDIS_MASTER_NAME", "")
+	_, err := newClientFromEnv()
+	if err == nil {
+		t.Fatal("expected an error without REDIS_MASTER_NAME")
+	}
+}
+
+func TestUserKeysShareHashTag(t *testing.T) {
+	keys := []string{
+		GetCategoriesKey("42"),
+		GetFlashcardsKey("42", "7"),
+		GetCacheGenerationKey("42"),
+		GetIdempotencyKey("42", "abc"),
+	}
+	for _, key := range keys {
+		start := strings.Index(key, "{")
+		end := strings.Index(key, "}")
+		if start < 0 || end < start || key[start+1:end] != "42" {
+			t.Fatalf("key %q does not carry the user hash tag", key)
+		}
+	}
+}
@@ -2,15 +2,51 @@ package redis
 
 import (
 	"context"
-	"os"
-	"strconv"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisDatabase struct {
-	redis *redis.Client
+	redis redis.UniversalClient
+}
+
+// hmsetIfGenerationScript replaces a hash only while the generation counter in
+// KEYS[1] still equals ARGV[1], so a reader that loaded data before a write
+// cannot put it back into the cache after the write invalidated it.
+var hmsetIfGenerationScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1]) or '0'
+if current ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[2])
+for i = 3, #ARGV, 2 do
+	redis.call('HSET', KEYS[2], ARGV[i], ARGV[i + 1])
+end
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+return 1
+`)
+
+// patchHashScript bumps the generation counter in KEYS[1] and, if it still
+// held ARGV[1], replaces the existing field ARGV[3] of the hash in KEYS[2]
+// with ARGV[4]. Otherwise the hash is deleted, as Invalidate would.
+var patchHashScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1]) or '0'
+redis.call('INCR', KEYS[1])
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+if current == ARGV[1] and redis.call('HEXISTS', KEYS[2], ARGV[3]) == 1 then
+	redis.call('HSET', KEYS[2], ARGV[3], ARGV[4])
+	return 1
+end
+redis.call('DEL', KEYS[2])
+return 0
+`)
+
+type StreamMessage struct {
+	Id     string
+	Values map[string]any
 }
 
 func NewRedisClient() *RedisDatabase {
@@ -18,12 +54,10 @@ func NewRedisClient() *RedisDatabase {
 }
 
 func (r *RedisDatabase) Connect() error {
-	db, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_URL"),
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       db,
-	})
+	rdb, err := newClientFromEnv()
+	if err != nil {
+		return err
+	}
 
 	r.redis = rdb
 	return r.Ping()
@@ -49,12 +83,25 @@ func (r *RedisDatabase) Set(key string, value string, expiredTimeInSec int64) er
 	return r.redis.Set(ctx, key, value, time.Duration(expiredTimeInSec)*time.Second).Err()
 }
 
+func (r *RedisDatabase) SetNX(key string, value string, expiredTimeInSec int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return r.redis.SetNX(ctx, key, value, time.Duration(expiredTimeInSec)*time.Second).Result()
+}
+
 func (r *RedisDatabase) Get(key string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	return r.redis.Get(ctx, key).Result()
 }
 
+func (r *RedisDatabase) Exists(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	count, err := r.redis.Exists(ctx, key).Result()
+	return count > 0, err
+}
+
 func (r *RedisDatabase) Del(key string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -110,3 +157,170 @@ func (r *RedisDatabase) HMSetWithExpiry(key string, fields map[string]any, expir
 	_, err := pipe.Exec(ctx)
 	return err
 }
+
+// GetGeneration returns the counter stored at key, 0 when it does not exist.
+func (r *RedisDatabase) GetGeneration(key string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	generation, err := r.redis.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+// Invalidate deletes keys and bumps the generation counter at generationKey in
+// one transaction.
+func (r *RedisDatabase) Invalidate(generationKey string, generationExpiry time.Duration, keys ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pipe := r.redis.TxPipeline()
+	pipe.Incr(ctx, generationKey)
+	pipe.Expire(ctx, generationKey, generationExpiry)
+	if len(keys) > 0 {
+		pipe.Del(ctx, keys...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// HMSetWithExpiryIfGeneration replaces the hash at key with fields unless the
+// counter at generationKey moved away from generation. It reports whether the
+// fields were written.
+func (r *RedisDatabase) HMSetWithExpiryIfGeneration(generationKey string, generation int64, key string, fields map[string]any, expiredTimeInSec int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	args := make([]any, 0, 2+2*len(fields))
+	args = append(args, generation, expiredTimeInSec)
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	written, err := hmsetIfGenerationScript.Run(ctx, r.redis, []string{generationKey, key}, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return written == 1, nil
+}
+
+// PatchHash bumps the generation counter at generationKey and, if it still
+// held generation, replaces field of the hash at key with value. A hash that
+// does not hold field, or one that another write may have changed since
+// generation was read, is deleted instead. It reports whether the field was
+// written.
+func (r *RedisDatabase) PatchHash(generationKey string, generation int64, generationExpiry time.Duration, key string, field string, value string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	written, err := patchHashScript.Run(ctx, r.redis, []string{generationKey, key}, generation, int64(generationExpiry/time.Second), field, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return written == 1, nil
+}
+
+func (r *RedisDatabase) Publish(channel string, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return r.redis.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe delivers the payloads published on channels until ctx is done or
+// the returned close function is called.
+func (r *RedisDatabase) Subscribe(ctx context.Context, channels ...string) (<-chan string, func() error, error) {
+	pubsub := r.redis.Subscribe(ctx, channels...)
+	_, err := pubsub.Receive(ctx)
+	if err != nil {
+		pubsub.Close()
+		return nil, nil, err
+	}
+
+	messages := make(chan string)
+	go func() {
+		defer close(messages)
+		for message := range pubsub.Channel() {
+			select {
+			case messages <- message.Payload:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, pubsub.Close, nil
+}
+
+func (r *RedisDatabase) XAdd(stream string, maxLen int64, values map[string]any) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return r.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: values,
+	}).Result()
+}
+
+// XGroupCreate creates a consumer group that starts from the oldest entry
+// still in the stream, creating the stream if needed. An existing group is
+// left as it is.
+func (r *RedisDatabase) XGroupCreate(stream string, group string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := r.redis.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+func (r *RedisDatabase) XReadGroup(stream string, group string, consumer string, count int64, block time.Duration) ([]StreamMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), block+30*time.Second)
+	defer cancel()
+	streams, err := r.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]StreamMessage, 0)
+	for _, s := range streams {
+		for _, message := range s.Messages {
+			messages = append(messages, StreamMessage{Id: message.ID, Values: message.Values})
+		}
+	}
+	return messages, nil
+}
+
+// XAutoClaim takes over messages another consumer of the group read but did
+// not acknowledge within minIdle.
+func (r *RedisDatabase) XAutoClaim(stream string, group string, consumer string, minIdle time.Duration, count int64) ([]StreamMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	claimed, _, err := r.redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    "0",
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]StreamMessage, 0, len(claimed))
+	for _, message := range claimed {
+		messages = append(messages, StreamMessage{Id: message.ID, Values: message.Values})
+	}
+	return messages, nil
+}
+
+func (r *RedisDatabase) XAck(stream string, group string, ids ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return r.redis.XAck(ctx, stream, group, ids...).Err()
+}
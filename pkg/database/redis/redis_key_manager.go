@@ -1,9 +1,50 @@
 package redis
 
+// Per-user keys put the user id in a hash tag, so that in cluster mode they
+// all map to the same slot and can be used together in a transaction or a
+// script, see RedisDatabase.Invalidate.
+func userTag(userId string) string {
+	return "{" + userId + "}"
+}
+
 func GetCategoriesKey(userId string) string {
-	return "category:" + userId
+	return "category:" + userTag(userId)
 }
 
 func GetFlashcardsKey(userId string, categoryId string) string {
-	return "flashcard:" + userId + ":" + categoryId
+	return "flashcard:" + userTag(userId) + ":" + categoryId
+}
+
+// GetCacheGenerationKey holds a counter bumped on every write of the user's
+// categories or flashcards, see RedisDatabase.HMSetWithExpiryIfGeneration.
+func GetCacheGenerationKey(userId string) string {
+	return "cache_generation:" + userTag(userId)
+}
+
+// GetRecentWriteKey marks a user who wrote within the last few seconds, whose
+// reads must not go to a replica that may lag behind.
+func GetRecentWriteKey(userId string) string {
+	return "recent_write:" + userTag(userId)
+}
+
+// GetCacheWarmedKey is set once the cache has been warmed and disappears with
+// a flush, see jobs.CacheWarmJob.
+func GetCacheWarmedKey() string {
+	return "cache_warmed"
+}
+
+func GetIdempotencyKey(userId string, idempotencyKey string) string {
+	return "idempotency:" + userTag(userId) + ":" + idempotencyKey
+}
+
+func GetUserEventsChannel(userId string) string {
+	return "events:" + userId
+}
+
+func GetCacheInvalidationChannel() string {
+	return "cache_invalidation"
+}
+
+func GetDomainEventsStreamKey() string {
+	return "stream:domain_events"
 }
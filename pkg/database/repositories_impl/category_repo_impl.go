@@ -0,0 +1,382 @@
+package repositories_impl
+
+import (
+	"database/sql"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/objects"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CategoryRepositoryImpl struct {
+	db database.Database
+}
+
+func NewCategoryRepositoryImpl(db database.Database) repositories.CategoryRepository {
+	return &CategoryRepositoryImpl{
+		db: db,
+	}
+}
+
+func (c *CategoryRepositoryImpl) Insert(userId string, name string) (int64, error) {
+	var id int64
+	err := database.WithTransaction(c.db, func(tx database.Transaction) error {
+		var err error
+		id, err = insertReturningId(
+			tx,
+			"INSERT INTO flash_category (name, user_id, created_at) VALUES (?, ?, ?)",
+			name,
+			userId,
+			time.Now().Format("2006-01-02 15:04:05"),
+		)
+		if err != nil {
+			return err
+		}
+
+		categoryId := strconv.FormatInt(id, 10)
+		created, err := findCategory(tx, userId, categoryId)
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.NewEvent(events.CategoryCreated, userId, categoryId, "", created))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *CategoryRepositoryImpl) FindAll(userId string) ([]model.Category, error) {
+	rows, cancel, err := c.db.QueryRows(
+		"SELECT id, name, user_id, version, created_at, updated_at FROM flash_category WHERE user_id = ? AND deleted_at IS NULL ORDER BY id",
+		userId,
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]model.Category, 0)
+	for rows.Next() {
+		var category model.Category
+		err = rows.Scan(
+			&category.Id,
+			&category.Name,
+			&category.UserID,
+			&category.Version,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+func (c *CategoryRepositoryImpl) FindOneById(userId string, id string) (model.Category, error) {
+	return findCategory(c.db, userId, id)
+}
+
+// DeleteById moves the category to the trash together with the flashcards it
+// still holds. Both share the same deleted_at so RestoreById can bring back
+// exactly the cards that went away with the category.
+func (c *CategoryRepositoryImpl) DeleteById(userId string, id string, version int64) error {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	return database.WithTransaction(c.db, func(tx database.Transaction) error {
+		err := lockCategoryVersion(tx, userId, id, version)
+		if err != nil {
+			return err
+		}
+
+		_, cancel, err := tx.Exec(
+			"update flash_category set deleted_at = ?, updated_at = ?, version = version + 1 where user_id = ? and id = ?",
+			now,
+			now,
+			userId,
+			id,
+		)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+
+		_, cancelFlashcards, err := tx.Exec(
+			"update flashcard set deleted_at = ?, updated_at = ?, version = version + 1 where user_id = ? and category_id = ? and deleted_at is null",
+			now,
+			now,
+			userId,
+			id,
+		)
+		defer cancelFlashcards()
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.NewEvent(events.CategoryDeleted, userId, id, "", nil))
+	})
+}
+
+func (c *CategoryRepositoryImpl) UpdateById(userId string, id string, name string, version int64) error {
+	_, err := c.PatchById(userId, id, objects.PatchCategory{Name: objects.Some(name)}, version)
+	return err
+}
+
+// PatchById writes the fields set in patch and returns the updated category.
+// An empty patch only checks the version.
+func (c *CategoryRepositoryImpl) PatchById(userId string, id string, patch objects.PatchCategory, version int64) (model.Category, error) {
+	var updated model.Category
+	err := database.WithTransaction(c.db, func(tx database.Transaction) error {
+		err := lockCategoryVersion(tx, userId, id, version)
+		if err != nil {
+			return err
+		}
+		if patch.IsEmpty() {
+			updated, err = findCategory(tx, userId, id)
+			return err
+		}
+
+		sets := make([]string, 0, 3)
+		args := make([]any, 0, 4)
+		if patch.Name.Set {
+			sets = append(sets, "name = ?")
+			args = append(args, patch.Name.Value)
+		}
+		sets = append(sets, "updated_at = ?", "version = version + 1")
+		args = append(args, time.Now().Format("2006-01-02 15:04:05"), userId, id)
+		_, cancel, err := tx.Exec(
+			"update flash_category set "+strings.Join(sets, ", ")+" where user_id = ? and id = ?",
+			args...,
+		)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+
+		updated, err = findCategory(tx, userId, id)
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.NewEvent(events.CategoryUpdated, userId, id, "", updated))
+	})
+	return updated, err
+}
+
+func (c *CategoryRepositoryImpl) FindDeleted(userId string) ([]model.Category, error) {
+	rows, cancel, err := c.db.QueryRows(
+		"SELECT id, name, user_id, version, created_at, updated_at, deleted_at FROM flash_category WHERE user_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC",
+		userId,
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]model.Category, 0)
+	for rows.Next() {
+		var category model.Category
+		err = rows.Scan(
+			&category.Id,
+			&category.Name,
+			&category.UserID,
+			&category.Version,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+			&category.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+// RestoreById takes the category out of the trash along with the flashcards
+// that were deleted in the same DeleteById call. It returns sql.ErrNoRows when
+// the category is not in the trash.
+func (c *CategoryRepositoryImpl) RestoreById(userId string, id string) (model.Category, error) {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	var restored model.Category
+	err := database.WithTransaction(c.db, func(tx database.Transaction) error {
+		_, cancelFlashcards, err := tx.Exec(
+			`update flashcard set deleted_at = null, updated_at = ?, version = version + 1
+			where user_id = ? and category_id = ? and deleted_at = (
+				select deleted_at from flash_category where user_id = ? and id = ? and deleted_at is not null
+			)`,
+			now,
+			userId,
+			id,
+			userId,
+			id,
+		)
+		defer cancelFlashcards()
+		if err != nil {
+			return err
+		}
+
+		result, cancel, err := tx.Exec(
+			"update flash_category set deleted_at = null, updated_at = ?, version = version + 1 where user_id = ? and id = ? and deleted_at is not null",
+			now,
+			userId,
+			id,
+		)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		restored, err = findCategory(tx, userId, id)
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.NewEvent(events.CategoryRestored, userId, id, "", restored))
+	})
+	if err != nil {
+		return model.Category{}, err
+	}
+	return restored, nil
+}
+
+func (c *CategoryRepositoryImpl) PurgeDeletedBefore(before time.Time) (int64, error) {
+	result, cancel, err := c.db.Exec(
+		"delete from flash_category where deleted_at is not null and deleted_at < ?",
+		before.Format("2006-01-02 15:04:05"),
+	)
+	defer cancel()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// FindChangedSince returns every category created, updated or deleted in
+// [since, until), deleted ones included as tombstones.
+func (c *CategoryRepositoryImpl) FindChangedSince(userId string, since time.Time, until time.Time) ([]model.Category, error) {
+	rows, cancel, err := c.db.QueryRows(
+		`SELECT id, name, user_id, version, created_at, updated_at, deleted_at FROM flash_category
+		WHERE user_id = ? AND COALESCE(updated_at, created_at) >= ? AND COALESCE(updated_at, created_at) < ?
+		ORDER BY COALESCE(updated_at, created_at), id`,
+		userId,
+		since.Format("2006-01-02 15:04:05"),
+		until.Format("2006-01-02 15:04:05"),
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]model.Category, 0)
+	for rows.Next() {
+		var category model.Category
+		err = rows.Scan(
+			&category.Id,
+			&category.Name,
+			&category.UserID,
+			&category.Version,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+			&category.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+func (c *CategoryRepositoryImpl) FindActiveUserIds(since time.Time, limit int) ([]string, error) {
+	rows, cancel, err := c.db.QueryRows(
+		`SELECT user_id FROM (
+			SELECT user_id, MAX(COALESCE(updated_at, created_at)) AS last_change FROM flash_category GROUP BY user_id
+			UNION ALL
+			SELECT user_id, MAX(COALESCE(updated_at, created_at)) AS last_change FROM flashcard GROUP BY user_id
+		) changes
+		WHERE last_change >= ?
+		GROUP BY user_id
+		ORDER BY MAX(last_change) DESC
+		LIMIT ?`,
+		since.Format("2006-01-02 15:04:05"),
+		limit,
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIds := make([]string, 0)
+	for rows.Next() {
+		var userId string
+		err = rows.Scan(&userId)
+		if err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, userId)
+	}
+	return userIds, nil
+}
+
+// lockCategoryVersion locks the category row for the rest of the transaction
+// and checks it against the version the caller last saw. A version of 0 skips
+// the check.
+func lockCategoryVersion(tx database.Executor, userId string, id string, version int64) error {
+	row, cancel, err := tx.QueryRow(
+		"select version from flash_category where user_id = ? and id = ? and deleted_at is null"+forUpdate(tx, false),
+		userId,
+		id,
+	)
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	var current int64
+	err = row.Scan(&current)
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != current {
+		return repositories.ErrVersionMismatch
+	}
+	return nil
+}
+
+func findCategory(exec database.Executor, userId string, id string) (model.Category, error) {
+	row, cancel, err := exec.QueryRow(
+		"SELECT id, name, user_id, version, created_at, updated_at FROM flash_category WHERE user_id = ? AND id = ? AND deleted_at IS NULL",
+		userId,
+		id,
+	)
+	defer cancel()
+	if err != nil {
+		return model.Category{}, err
+	}
+	var category model.Category
+	err = row.Scan(
+		&category.Id,
+		&category.Name,
+		&category.UserID,
+		&category.Version,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if err != nil {
+		return model.Category{}, err
+	}
+	return category, nil
+}
@@ -0,0 +1,62 @@
+package repositories_impl
+
+import (
+	"flashcard_service/pkg/database"
+	"strings"
+)
+
+// The queries in this package run on MySQL, Postgres and SQLite. The helpers
+// below cover the statements they cannot share.
+
+// insertReturningId runs an INSERT into a table with an id column and returns
+// the id of the new row, using LastInsertId on MySQL and RETURNING on
+// Postgres where the driver has no LastInsertId.
+func insertReturningId(exec database.Executor, query string, args ...any) (int64, error) {
+	if exec.Dialect() == database.DialectPostgres {
+		row, cancel, err := exec.QueryRow(query+" RETURNING id", args...)
+		defer cancel()
+		if err != nil {
+			return 0, err
+		}
+		var id int64
+		err = row.Scan(&id)
+		return id, err
+	}
+
+	result, cancel, err := exec.Exec(query, args...)
+	defer cancel()
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// insertIgnoringDuplicates runs an "INSERT INTO ..." statement that skips
+// rows violating a unique key instead of failing.
+func insertIgnoringDuplicates(exec database.Executor, query string, args ...any) error {
+	switch exec.Dialect() {
+	case database.DialectPostgres:
+		query += " ON CONFLICT DO NOTHING"
+	case database.DialectSQLite:
+		query = strings.Replace(query, "INSERT INTO", "INSERT OR IGNORE INTO", 1)
+	default:
+		query = strings.Replace(query, "INSERT INTO", "INSERT IGNORE INTO", 1)
+	}
+	_, cancel, err := exec.Exec(query, args...)
+	defer cancel()
+	return err
+}
+
+// forUpdate returns the clause locking the rows a SELECT in a transaction
+// reads, optionally skipping rows another transaction holds. SQLite has no
+// row locks; it runs one writer at a time anyway.
+func forUpdate(exec database.Executor, skipLocked bool) string {
+	switch {
+	case exec.Dialect() == database.DialectSQLite:
+		return ""
+	case skipLocked:
+		return " FOR UPDATE SKIP LOCKED"
+	default:
+		return " FOR UPDATE"
+	}
+}
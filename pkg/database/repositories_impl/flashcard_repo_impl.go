@@ -0,0 +1,453 @@
+package repositories_impl
+
+import (
+	"database/sql"
+	"errors"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"flashcard_service/pkg/objects"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FlashcardRepositoryImpl struct {
+	db database.Database
+}
+
+func NewFlashcardRepositoryImpl(
+	db database.Database,
+) repositories.FlashcardRepository {
+	return &FlashcardRepositoryImpl{
+		db: db,
+	}
+}
+
+func (f *FlashcardRepositoryImpl) Insert(userId string, categoryId string, flashcard objects.CreateFlashcard) (int64, error) {
+	var id int64
+	err := database.WithTransaction(f.db, func(tx database.Transaction) error {
+		created, err := insertFlashcard(tx, userId, categoryId, flashcard)
+		id = created.ID
+		return err
+	})
+	return id, err
+}
+
+// InsertManyByUserId inserts the flashcards one row at a time inside a single
+// transaction so every card gets its own id and created event. It returns the
+// created cards in the order given.
+func (f *FlashcardRepositoryImpl) InsertManyByUserId(userId string, categoryId string, flashcards []objects.CreateFlashcard) ([]model.Flashcard, error) {
+	created := make([]model.Flashcard, 0, len(flashcards))
+	err := database.WithTransaction(f.db, func(tx database.Transaction) error {
+		for _, flashcard := range flashcards {
+			inserted, err := insertFlashcard(tx, userId, categoryId, flashcard)
+			if err != nil {
+				return err
+			}
+			created = append(created, inserted)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (f *FlashcardRepositoryImpl) FindOneById(userId string, id string) (model.Flashcard, error) {
+	return findFlashcard(f.db, userId, id)
+}
+
+func (f *FlashcardRepositoryImpl) DeleteById(userId string, id string, version int64) error {
+	return database.WithTransaction(f.db, func(tx database.Transaction) error {
+		previous, err := lockFlashcard(tx, userId, id, version)
+		if err != nil {
+			return err
+		}
+		return deleteFlashcard(tx, userId, previous)
+	})
+}
+
+// UpdateById overwrites the editable fields of a flashcard, see PatchById. A
+// version of 0 skips the optimistic concurrency check.
+func (f *FlashcardRepositoryImpl) UpdateById(userId string, id string, flashcard model.Flashcard, version int64) error {
+	_, err := f.PatchById(userId, id, objects.PatchFlashcard{
+		Name:       objects.Some(flashcard.Name),
+		Content:    objects.Some(flashcard.Content),
+		CategoryId: objects.Some(flashcard.CategoryId),
+	}, version)
+	return err
+}
+
+// PatchById writes the fields set in patch, records the previous values as a
+// new revision in the same transaction and returns the updated flashcard. An
+// empty patch only checks the version.
+func (f *FlashcardRepositoryImpl) PatchById(userId string, id string, patch objects.PatchFlashcard, version int64) (model.Flashcard, error) {
+	var updated model.Flashcard
+	err := database.WithTransaction(f.db, func(tx database.Transaction) error {
+		previous, err := lockFlashcard(tx, userId, id, version)
+		if err != nil {
+			return err
+		}
+		if patch.IsEmpty() {
+			updated, err = findFlashcard(tx, userId, id)
+			return err
+		}
+		updated, err = patchFlashcard(tx, userId, previous, patch)
+		return err
+	})
+	return updated, err
+}
+
+// ApplyBulk runs bulk on every listed flashcard of the user in one
+// transaction. Cards that do not exist are reported, not failed; any other
+// error rolls the whole operation back.
+func (f *FlashcardRepositoryImpl) ApplyBulk(userId string, bulk objects.BulkFlashcards) ([]objects.BulkFlashcardResult, error) {
+	var results []objects.BulkFlashcardResult
+	err := database.WithTransaction(f.db, func(tx database.Transaction) error {
+		results = make([]objects.BulkFlashcardResult, 0, len(bulk.Ids))
+		seen := make(map[int64]bool, len(bulk.Ids))
+		for _, id := range bulk.Ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			result := objects.BulkFlashcardResult{Id: id, Status: objects.BulkStatusApplied}
+			previous, err := lockFlashcard(tx, userId, strconv.FormatInt(id, 10), 0)
+			if errors.Is(err, sql.ErrNoRows) {
+				result.Status = objects.BulkStatusNotFound
+				results = append(results, result)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			result.PreviousCategoryId = previous.CategoryId
+
+			switch {
+			case bulk.Op == objects.BulkOpDelete:
+				err = deleteFlashcard(tx, userId, previous)
+			case bulk.Op == objects.BulkOpMove && previous.CategoryId == bulk.CategoryId:
+				result.Status = objects.BulkStatusUnchanged
+			case bulk.Op == objects.BulkOpMove:
+				var moved model.Flashcard
+				moved, err = patchFlashcard(tx, userId, previous, objects.PatchFlashcard{CategoryId: objects.Some(bulk.CategoryId)})
+				result.Flashcard = &moved
+			default:
+				err = errors.New("unknown bulk operation " + bulk.Op)
+			}
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	return results, err
+}
+
+func (f *FlashcardRepositoryImpl) FindByCategoryId(userId string, categoryId string) ([]model.Flashcard, error) {
+	query := "SELECT id, name, content, category_id, version, created_at, updated_at, user_id FROM flashcard WHERE category_id = ? and user_id = ? and deleted_at IS NULL ORDER BY id"
+	rows, cancel, err := f.db.QueryRows(query, categoryId, userId)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flashcards := make([]model.Flashcard, 0)
+	for rows.Next() {
+		var flashcard model.Flashcard
+		err = rows.Scan(
+			&flashcard.ID,
+			&flashcard.Name,
+			&flashcard.Content,
+			&flashcard.CategoryId,
+			&flashcard.Version,
+			&flashcard.CreatedAt,
+			&flashcard.UpdatedAt,
+			&flashcard.UserId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		flashcards = append(flashcards, flashcard)
+	}
+	return flashcards, nil
+}
+
+// FindDeleted lists the flashcards that were deleted on their own. Cards that
+// went to the trash with their category are restored through the category.
+func (f *FlashcardRepositoryImpl) FindDeleted(userId string) ([]model.Flashcard, error) {
+	query := `SELECT f.id, f.name, f.content, f.category_id, f.version, f.created_at, f.updated_at, f.deleted_at, f.user_id
+		FROM flashcard f JOIN flash_category c ON c.id = f.category_id
+		WHERE f.user_id = ? and f.deleted_at IS NOT NULL and c.deleted_at IS NULL
+		ORDER BY f.deleted_at DESC`
+	rows, cancel, err := f.db.QueryRows(query, userId)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flashcards := make([]model.Flashcard, 0)
+	for rows.Next() {
+		var flashcard model.Flashcard
+		err = rows.Scan(
+			&flashcard.ID,
+			&flashcard.Name,
+			&flashcard.Content,
+			&flashcard.CategoryId,
+			&flashcard.Version,
+			&flashcard.CreatedAt,
+			&flashcard.UpdatedAt,
+			&flashcard.DeletedAt,
+			&flashcard.UserId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		flashcards = append(flashcards, flashcard)
+	}
+	return flashcards, nil
+}
+
+// RestoreById takes the flashcard out of the trash. It returns sql.ErrNoRows
+// when the card is not in the trash or its category is deleted.
+func (f *FlashcardRepositoryImpl) RestoreById(userId string, id string) (model.Flashcard, error) {
+	var restored model.Flashcard
+	err := database.WithTransaction(f.db, func(tx database.Transaction) error {
+		query := `UPDATE flashcard SET deleted_at = NULL, updated_at = ?, version = version + 1
+			WHERE id = ? and user_id = ? and deleted_at IS NOT NULL
+			and category_id IN (SELECT id FROM flash_category WHERE user_id = ? and deleted_at IS NULL)`
+		result, cancel, err := tx.Exec(
+			query,
+			time.Now().Format("2006-01-02 15:04:05"),
+			id,
+			userId,
+			userId,
+		)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return sql.ErrNoRows
+		}
+
+		restored, err = findFlashcard(tx, userId, id)
+		if err != nil {
+			return err
+		}
+		return insertOutboxEvent(tx, events.NewEvent(events.FlashcardRestored, userId, strconv.Itoa(restored.CategoryId), id, restored))
+	})
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	return restored, nil
+}
+
+func (f *FlashcardRepositoryImpl) PurgeDeletedBefore(before time.Time) (int64, error) {
+	var purged int64
+	deletedBefore := before.Format("2006-01-02 15:04:05")
+	err := database.WithTransaction(f.db, func(tx database.Transaction) error {
+		// The revisions of a purged flashcard go with it.
+		_, cancel, err := tx.Exec(
+			`DELETE FROM flashcard_revision WHERE flashcard_id IN
+			(SELECT id FROM flashcard WHERE deleted_at IS NOT NULL and deleted_at < ?)`,
+			deletedBefore,
+		)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		result, cancel, err := tx.Exec("DELETE FROM flashcard WHERE deleted_at IS NOT NULL and deleted_at < ?", deletedBefore)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+		purged, err = result.RowsAffected()
+		return err
+	})
+	return purged, err
+}
+
+// FindChangedSince returns every flashcard created, updated or deleted in
+// [since, until), deleted ones included as tombstones.
+func (f *FlashcardRepositoryImpl) FindChangedSince(userId string, since time.Time, until time.Time) ([]model.Flashcard, error) {
+	query := `SELECT id, name, content, category_id, version, created_at, updated_at, deleted_at, user_id FROM flashcard
+		WHERE user_id = ? and COALESCE(updated_at, created_at) >= ? and COALESCE(updated_at, created_at) < ?
+		ORDER BY COALESCE(updated_at, created_at), id`
+	rows, cancel, err := f.db.QueryRows(
+		query,
+		userId,
+		since.Format("2006-01-02 15:04:05"),
+		until.Format("2006-01-02 15:04:05"),
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flashcards := make([]model.Flashcard, 0)
+	for rows.Next() {
+		var flashcard model.Flashcard
+		err = rows.Scan(
+			&flashcard.ID,
+			&flashcard.Name,
+			&flashcard.Content,
+			&flashcard.CategoryId,
+			&flashcard.Version,
+			&flashcard.CreatedAt,
+			&flashcard.UpdatedAt,
+			&flashcard.DeletedAt,
+			&flashcard.UserId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		flashcards = append(flashcards, flashcard)
+	}
+	return flashcards, nil
+}
+
+// lockFlashcard locks the flashcard row for the rest of the transaction and
+// checks it against the version the caller last saw. A version of 0 skips the
+// check.
+func lockFlashcard(tx database.Executor, userId string, id string, version int64) (model.Flashcard, error) {
+	row, cancel, err := tx.QueryRow(
+		"SELECT id, name, content, category_id, version FROM flashcard WHERE id = ? and user_id = ? and deleted_at IS NULL"+forUpdate(tx, false),
+		id,
+		userId,
+	)
+	defer cancel()
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	var flashcard model.Flashcard
+	err = row.Scan(&flashcard.ID, &flashcard.Name, &flashcard.Content, &flashcard.CategoryId, &flashcard.Version)
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	if version != 0 && version != flashcard.Version {
+		return model.Flashcard{}, repositories.ErrVersionMismatch
+	}
+	return flashcard, nil
+}
+
+// deleteFlashcard moves a locked flashcard to the trash.
+func deleteFlashcard(tx database.Executor, userId string, previous model.Flashcard) error {
+	id := strconv.FormatInt(previous.ID, 10)
+	now := time.Now().Format("2006-01-02 15:04:05")
+	query := "UPDATE flashcard SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? and user_id = ?"
+	_, cancel, err := tx.Exec(query, now, now, id, userId)
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	return insertOutboxEvent(tx, events.NewEvent(events.FlashcardDeleted, userId, strconv.Itoa(previous.CategoryId), id, nil))
+}
+
+// patchFlashcard writes the fields set in a non-empty patch to a locked
+// flashcard and records the previous values as a revision.
+func patchFlashcard(tx database.Executor, userId string, previous model.Flashcard, patch objects.PatchFlashcard) (model.Flashcard, error) {
+	id := strconv.FormatInt(previous.ID, 10)
+	now := time.Now().Format("2006-01-02 15:04:05")
+	sets := make([]string, 0, 5)
+	args := make([]any, 0, 6)
+	if patch.Name.Set {
+		sets = append(sets, "name = ?")
+		args = append(args, patch.Name.Value)
+	}
+	if patch.Content.Set {
+		sets = append(sets, "content = ?")
+		args = append(args, patch.Content.Value)
+	}
+	if patch.CategoryId.Set {
+		sets = append(sets, "category_id = ?")
+		args = append(args, patch.CategoryId.Value)
+	}
+	sets = append(sets, "updated_at = ?", "version = version + 1")
+	args = append(args, now, id, userId)
+	_, cancel, err := tx.Exec(
+		"UPDATE flashcard SET "+strings.Join(sets, ", ")+" WHERE id = ? and user_id = ?",
+		args...,
+	)
+	defer cancel()
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+
+	diff := model.DiffFlashcards(previous, patch.ApplyTo(previous))
+	if len(diff) > 0 {
+		err = insertFlashcardRevision(tx, userId, previous, diff, now)
+		if err != nil {
+			return model.Flashcard{}, err
+		}
+	}
+
+	updated, err := findFlashcard(tx, userId, id)
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	return updated, insertOutboxEvent(tx, events.NewEvent(events.FlashcardUpdated, userId, strconv.Itoa(updated.CategoryId), id, updated))
+}
+
+func findFlashcard(exec database.Executor, userId string, id string) (model.Flashcard, error) {
+	query := "SELECT id, name, content, category_id, version, created_at, updated_at, user_id FROM flashcard WHERE id = ? and user_id = ? and deleted_at IS NULL"
+	row, cancel, err := exec.QueryRow(query, id, userId)
+	defer cancel()
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	var flashcard model.Flashcard
+	err = row.Scan(
+		&flashcard.ID,
+		&flashcard.Name,
+		&flashcard.Content,
+		&flashcard.CategoryId,
+		&flashcard.Version,
+		&flashcard.CreatedAt,
+		&flashcard.UpdatedAt,
+		&flashcard.UserId,
+	)
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	return flashcard, nil
+}
+
+func insertFlashcard(tx database.Executor, userId string, categoryId string, flashcard objects.CreateFlashcard) (model.Flashcard, error) {
+	query := "INSERT INTO flashcard (name, content, category_id, created_at, user_id) VALUES (?, ?, ?, ?, ?)"
+	id, err := insertReturningId(
+		tx,
+		query,
+		flashcard.Name,
+		flashcard.Content,
+		categoryId,
+		time.Now().Format("2006-01-02 15:04:05"),
+		userId,
+	)
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+
+	created, err := findFlashcard(tx, userId, strconv.FormatInt(id, 10))
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	err = insertOutboxEvent(tx, events.NewEvent(events.FlashcardCreated, userId, categoryId, strconv.FormatInt(id, 10), created))
+	if err != nil {
+		return model.Flashcard{}, err
+	}
+	return created, nil
+}
@@ -0,0 +1,117 @@
+package repositories_impl
+
+import (
+	"encoding/json"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+)
+
+type FlashcardRevisionRepositoryImpl struct {
+	db database.Database
+}
+
+func NewFlashcardRevisionRepositoryImpl(db database.Database) repositories.FlashcardRevisionRepository {
+	return &FlashcardRevisionRepositoryImpl{
+		db: db,
+	}
+}
+
+func (f *FlashcardRevisionRepositoryImpl) FindByFlashcardId(userId string, flashcardId string) ([]model.FlashcardRevision, error) {
+	query := `SELECT id, flashcard_id, revision, name, content, category_id, diff, created_at
+		FROM flashcard_revision WHERE flashcard_id = ? and user_id = ? ORDER BY revision DESC`
+	rows, cancel, err := f.db.QueryRows(query, flashcardId, userId)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]model.FlashcardRevision, 0)
+	for rows.Next() {
+		revision, err := scanFlashcardRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+func (f *FlashcardRevisionRepositoryImpl) FindOne(userId string, flashcardId string, revision string) (model.FlashcardRevision, error) {
+	query := `SELECT id, flashcard_id, revision, name, content, category_id, diff, created_at
+		FROM flashcard_revision WHERE flashcard_id = ? and user_id = ? and revision = ?`
+	row, cancel, err := f.db.QueryRow(query, flashcardId, userId, revision)
+	defer cancel()
+	if err != nil {
+		return model.FlashcardRevision{}, err
+	}
+	return scanFlashcardRevision(row)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFlashcardRevision(s scanner) (model.FlashcardRevision, error) {
+	var revision model.FlashcardRevision
+	var diff []byte
+	err := s.Scan(
+		&revision.ID,
+		&revision.FlashcardId,
+		&revision.Revision,
+		&revision.Name,
+		&revision.Content,
+		&revision.CategoryId,
+		&diff,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		return model.FlashcardRevision{}, err
+	}
+	err = json.Unmarshal(diff, &revision.Diff)
+	if err != nil {
+		return model.FlashcardRevision{}, err
+	}
+	return revision, nil
+}
+
+// insertFlashcardRevision appends the next revision of a flashcard, stamped
+// with updatedAt, the updated_at the update writes to the card. It must run in
+// the transaction that holds the row lock on the flashcard so concurrent
+// updates cannot pick the same revision number.
+func insertFlashcardRevision(tx database.Executor, userId string, previous model.Flashcard, diff []model.FieldChange, updatedAt string) error {
+	row, cancel, err := tx.QueryRow(
+		"SELECT COALESCE(MAX(revision), 0) + 1 FROM flashcard_revision WHERE flashcard_id = ?",
+		previous.ID,
+	)
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	var next int
+	err = row.Scan(&next)
+	if err != nil {
+		return err
+	}
+
+	diffJson, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO flashcard_revision (flashcard_id, user_id, revision, name, content, category_id, diff, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, cancelInsert, err := tx.Exec(
+		query,
+		previous.ID,
+		userId,
+		next,
+		previous.Name,
+		previous.Content,
+		previous.CategoryId,
+		string(diffJson),
+		updatedAt,
+	)
+	defer cancelInsert()
+	return err
+}
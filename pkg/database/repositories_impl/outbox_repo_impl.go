@@ -0,0 +1,136 @@
+package repositories_impl
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flashcard_service/internal/events"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type OutboxRepositoryImpl struct {
+	db database.Database
+}
+
+func NewOutboxRepositoryImpl(db database.Database) repositories.OutboxRepository {
+	return &OutboxRepositoryImpl{
+		db: db,
+	}
+}
+
+func (o *OutboxRepositoryImpl) PublishPending(limit int, publish func(entries []model.OutboxEntry) error) (int, error) {
+	published := 0
+	err := database.WithTransaction(o.db, func(tx database.Transaction) error {
+		rows, cancel, err := tx.QueryRows(
+			`SELECT id, event_id, event_type, user_id, payload, created_at, published_at FROM outbox
+			WHERE published_at IS NULL ORDER BY id LIMIT ?`+forUpdate(tx, true),
+			limit,
+		)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+		entries, err := scanOutboxEntries(rows)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		err = publish(entries)
+		if err != nil {
+			return err
+		}
+
+		placeholders := make([]string, 0, len(entries))
+		args := make([]any, 0, len(entries)+1)
+		args = append(args, time.Now().Format("2006-01-02 15:04:05"))
+		for _, entry := range entries {
+			placeholders = append(placeholders, "?")
+			args = append(args, entry.Id)
+		}
+		query := fmt.Sprintf("UPDATE outbox SET published_at = ? WHERE id IN (%s)", strings.Join(placeholders, ","))
+		_, cancelUpdate, err := tx.Exec(query, args...)
+		defer cancelUpdate()
+		if err != nil {
+			return err
+		}
+		published = len(entries)
+		return nil
+	})
+	return published, err
+}
+
+func (o *OutboxRepositoryImpl) FindRange(fromId int64, toId int64, limit int) ([]model.OutboxEntry, error) {
+	rows, cancel, err := o.db.QueryRows(
+		`SELECT id, event_id, event_type, user_id, payload, created_at, published_at FROM outbox
+		WHERE id >= ? AND id <= ? ORDER BY id LIMIT ?`,
+		fromId,
+		toId,
+		limit,
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxEntries(rows)
+}
+
+func (o *OutboxRepositoryImpl) DeletePublishedBefore(before time.Time) (int64, error) {
+	result, cancel, err := o.db.Exec(
+		"DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < ?",
+		before.Format("2006-01-02 15:04:05"),
+	)
+	defer cancel()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanOutboxEntries(rows *sql.Rows) ([]model.OutboxEntry, error) {
+	defer rows.Close()
+	entries := make([]model.OutboxEntry, 0)
+	for rows.Next() {
+		var entry model.OutboxEntry
+		err := rows.Scan(
+			&entry.Id,
+			&entry.EventId,
+			&entry.EventType,
+			&entry.UserId,
+			&entry.Payload,
+			&entry.CreatedAt,
+			&entry.PublishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// insertOutboxEvent stores a domain event next to the change that produced it.
+// It must run in the transaction of that change so the event exists exactly
+// when the change is committed.
+func insertOutboxEvent(tx database.Executor, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, cancel, err := tx.Exec(
+		"INSERT INTO outbox (event_id, event_type, user_id, payload, created_at) VALUES (?, ?, ?, ?, ?)",
+		event.Id,
+		string(event.Type),
+		event.UserId,
+		string(payload),
+		event.OccurredAt.Format("2006-01-02 15:04:05"),
+	)
+	defer cancel()
+	return err
+}
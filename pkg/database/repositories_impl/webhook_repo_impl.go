@@ -0,0 +1,318 @@
+package repositories_impl
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flashcard_service/internal/model"
+	"flashcard_service/internal/repositories"
+	"flashcard_service/pkg/database"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type WebhookSubscriptionRepositoryImpl struct {
+	db database.Database
+}
+
+func NewWebhookSubscriptionRepositoryImpl(db database.Database) repositories.WebhookSubscriptionRepository {
+	return &WebhookSubscriptionRepositoryImpl{
+		db: db,
+	}
+}
+
+func (w *WebhookSubscriptionRepositoryImpl) Insert(subscription model.WebhookSubscription) (int64, error) {
+	eventTypes, err := json.Marshal(subscription.EventTypes)
+	if err != nil {
+		return 0, err
+	}
+	return insertReturningId(
+		w.db,
+		"INSERT INTO webhook_subscription (user_id, url, secret, event_types, active, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		subscription.UserId,
+		subscription.Url,
+		subscription.Secret,
+		string(eventTypes),
+		subscription.Active,
+		time.Now().Format("2006-01-02 15:04:05"),
+	)
+}
+
+func (w *WebhookSubscriptionRepositoryImpl) FindAll(userId string) ([]model.WebhookSubscription, error) {
+	rows, cancel, err := w.db.QueryRows(
+		"SELECT id, user_id, url, secret, event_types, active, created_at, updated_at FROM webhook_subscription WHERE user_id = ? ORDER BY id",
+		userId,
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookSubscriptions(rows)
+}
+
+func (w *WebhookSubscriptionRepositoryImpl) FindOneById(userId string, id string) (model.WebhookSubscription, error) {
+	row, cancel, err := w.db.QueryRow(
+		"SELECT id, user_id, url, secret, event_types, active, created_at, updated_at FROM webhook_subscription WHERE user_id = ? AND id = ?",
+		userId,
+		id,
+	)
+	defer cancel()
+	if err != nil {
+		return model.WebhookSubscription{}, err
+	}
+	return scanWebhookSubscription(row)
+}
+
+func (w *WebhookSubscriptionRepositoryImpl) FindActiveByUserId(userId string) ([]model.WebhookSubscription, error) {
+	rows, cancel, err := w.db.QueryRows(
+		"SELECT id, user_id, url, secret, event_types, active, created_at, updated_at FROM webhook_subscription WHERE user_id = ? AND active = TRUE ORDER BY id",
+		userId,
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookSubscriptions(rows)
+}
+
+func (w *WebhookSubscriptionRepositoryImpl) UpdateById(userId string, id string, subscription model.WebhookSubscription) error {
+	eventTypes, err := json.Marshal(subscription.EventTypes)
+	if err != nil {
+		return err
+	}
+	_, cancel, err := w.db.Exec(
+		"UPDATE webhook_subscription SET url = ?, event_types = ?, active = ?, updated_at = ? WHERE user_id = ? AND id = ?",
+		subscription.Url,
+		string(eventTypes),
+		subscription.Active,
+		time.Now().Format("2006-01-02 15:04:05"),
+		userId,
+		id,
+	)
+	defer cancel()
+	return err
+}
+
+// DeleteById removes the subscription. Its delivery log goes with it through
+// the foreign key.
+func (w *WebhookSubscriptionRepositoryImpl) DeleteById(userId string, id string) error {
+	result, cancel, err := w.db.Exec("DELETE FROM webhook_subscription WHERE user_id = ? AND id = ?", userId, id)
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanWebhookSubscriptions(rows *sql.Rows) ([]model.WebhookSubscription, error) {
+	defer rows.Close()
+	subscriptions := make([]model.WebhookSubscription, 0)
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+func scanWebhookSubscription(s scanner) (model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+	var eventTypes []byte
+	err := s.Scan(
+		&subscription.Id,
+		&subscription.UserId,
+		&subscription.Url,
+		&subscription.Secret,
+		&eventTypes,
+		&subscription.Active,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+	if err != nil {
+		return model.WebhookSubscription{}, err
+	}
+	err = json.Unmarshal(eventTypes, &subscription.EventTypes)
+	if err != nil {
+		return model.WebhookSubscription{}, err
+	}
+	return subscription, nil
+}
+
+type WebhookDeliveryRepositoryImpl struct {
+	db database.Database
+}
+
+func NewWebhookDeliveryRepositoryImpl(db database.Database) repositories.WebhookDeliveryRepository {
+	return &WebhookDeliveryRepositoryImpl{
+		db: db,
+	}
+}
+
+func (w *WebhookDeliveryRepositoryImpl) Insert(delivery model.WebhookDelivery) error {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	return insertIgnoringDuplicates(
+		w.db,
+		`INSERT INTO webhook_delivery (subscription_id, user_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		delivery.SubscriptionId,
+		delivery.UserId,
+		delivery.EventId,
+		delivery.EventType,
+		delivery.Payload,
+		model.WebhookDeliveryPending,
+		now,
+		now,
+	)
+}
+
+func (w *WebhookDeliveryRepositoryImpl) ClaimDue(limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := database.WithTransaction(w.db, func(tx database.Transaction) error {
+		rows, cancel, err := tx.QueryRows(
+			`SELECT id, subscription_id, user_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at
+			FROM webhook_delivery WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`+forUpdate(tx, true),
+			model.WebhookDeliveryPending,
+			time.Now().Format("2006-01-02 15:04:05"),
+			limit,
+		)
+		defer cancel()
+		if err != nil {
+			return err
+		}
+		deliveries, err = scanWebhookDeliveries(rows)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		placeholders := make([]string, 0, len(deliveries))
+		args := make([]any, 0, len(deliveries)+1)
+		args = append(args, time.Now().Add(lease).Format("2006-01-02 15:04:05"))
+		for _, delivery := range deliveries {
+			placeholders = append(placeholders, "?")
+			args = append(args, delivery.Id)
+		}
+		query := fmt.Sprintf("UPDATE webhook_delivery SET next_attempt_at = ? WHERE id IN (%s)", strings.Join(placeholders, ","))
+		_, cancelUpdate, err := tx.Exec(query, args...)
+		defer cancelUpdate()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (w *WebhookDeliveryRepositoryImpl) MarkSucceeded(id int64, statusCode int) error {
+	_, cancel, err := w.db.Exec(
+		"UPDATE webhook_delivery SET status = ?, attempts = attempts + 1, last_status_code = ?, last_error = NULL, updated_at = ? WHERE id = ?",
+		model.WebhookDeliverySucceeded,
+		statusCode,
+		time.Now().Format("2006-01-02 15:04:05"),
+		id,
+	)
+	defer cancel()
+	return err
+}
+
+// MarkFailed records a failed attempt. A statusCode of 0 means no response was
+// received.
+func (w *WebhookDeliveryRepositoryImpl) MarkFailed(id int64, status string, nextAttemptAt time.Time, statusCode int, lastError string) error {
+	var code any
+	if statusCode != 0 {
+		code = statusCode
+	}
+	if len(lastError) > 1024 {
+		lastError = lastError[:1024]
+	}
+	_, cancel, err := w.db.Exec(
+		"UPDATE webhook_delivery SET status = ?, attempts = attempts + 1, next_attempt_at = ?, last_status_code = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		status,
+		nextAttemptAt.Format("2006-01-02 15:04:05"),
+		code,
+		lastError,
+		time.Now().Format("2006-01-02 15:04:05"),
+		id,
+	)
+	defer cancel()
+	return err
+}
+
+func (w *WebhookDeliveryRepositoryImpl) FindBySubscriptionId(userId string, subscriptionId string, limit int) ([]model.WebhookDelivery, error) {
+	rows, cancel, err := w.db.QueryRows(
+		`SELECT id, subscription_id, user_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at
+		FROM webhook_delivery WHERE user_id = ? AND subscription_id = ? ORDER BY id DESC LIMIT ?`,
+		userId,
+		subscriptionId,
+		limit,
+	)
+	defer cancel()
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookDeliveries(rows)
+}
+
+func (w *WebhookDeliveryRepositoryImpl) Requeue(userId string, subscriptionId string, id string) error {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	result, cancel, err := w.db.Exec(
+		"UPDATE webhook_delivery SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ? WHERE user_id = ? AND subscription_id = ? AND id = ?",
+		model.WebhookDeliveryPending,
+		now,
+		now,
+		userId,
+		subscriptionId,
+		id,
+	)
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+	deliveries := make([]model.WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+		err := rows.Scan(
+			&delivery.Id,
+			&delivery.SubscriptionId,
+			&delivery.UserId,
+			&delivery.EventId,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,79 @@
+CREATE TABLE IF NOT EXISTS flash_category (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       VARCHAR(255) NOT NULL,
+    user_id    BIGINT       NOT NULL,
+    version    BIGINT       NOT NULL DEFAULT 1,
+    created_at DATETIME     NOT NULL,
+    updated_at DATETIME     NULL DEFAULT NULL,
+    deleted_at DATETIME     NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_flash_category_user_deleted ON flash_category (user_id, deleted_at);
+
+CREATE TABLE IF NOT EXISTS flashcard (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        VARCHAR(255) NOT NULL,
+    content     TEXT         NOT NULL,
+    category_id BIGINT       NOT NULL,
+    user_id     BIGINT       NOT NULL,
+    version     BIGINT       NOT NULL DEFAULT 1,
+    created_at  DATETIME     NOT NULL,
+    updated_at  DATETIME     NULL DEFAULT NULL,
+    deleted_at  DATETIME     NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_flashcard_user_category_deleted ON flashcard (user_id, category_id, deleted_at);
+CREATE INDEX IF NOT EXISTS idx_flashcard_deleted ON flashcard (deleted_at);
+
+CREATE TABLE IF NOT EXISTS flashcard_revision (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    flashcard_id BIGINT       NOT NULL,
+    user_id      BIGINT       NOT NULL,
+    revision     INT          NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    content      TEXT         NOT NULL,
+    category_id  BIGINT       NOT NULL,
+    diff         TEXT         NOT NULL,
+    created_at   DATETIME     NOT NULL,
+    UNIQUE (flashcard_id, revision)
+);
+CREATE INDEX IF NOT EXISTS idx_flashcard_revision_user ON flashcard_revision (user_id, flashcard_id);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id     CHAR(36)    NOT NULL UNIQUE,
+    event_type   VARCHAR(64) NOT NULL,
+    user_id      BIGINT      NOT NULL,
+    payload      TEXT        NOT NULL,
+    created_at   DATETIME    NOT NULL,
+    published_at DATETIME    NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (published_at, id);
+
+CREATE TABLE IF NOT EXISTS webhook_subscription (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id     BIGINT        NOT NULL,
+    url         VARCHAR(2048) NOT NULL,
+    secret      VARCHAR(128)  NOT NULL,
+    event_types TEXT          NOT NULL,
+    active      BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at  DATETIME      NOT NULL,
+    updated_at  DATETIME      NULL DEFAULT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_subscription_user ON webhook_subscription (user_id, active);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id  BIGINT        NOT NULL REFERENCES webhook_subscription (id) ON DELETE CASCADE,
+    user_id          BIGINT        NOT NULL,
+    event_id         CHAR(36)      NOT NULL,
+    event_type       VARCHAR(64)   NOT NULL,
+    payload          TEXT          NOT NULL,
+    status           VARCHAR(16)   NOT NULL,
+    attempts         INT           NOT NULL DEFAULT 0,
+    next_attempt_at  DATETIME      NOT NULL,
+    last_status_code INT           NULL DEFAULT NULL,
+    last_error       VARCHAR(1024) NULL DEFAULT NULL,
+    created_at       DATETIME      NOT NULL,
+    updated_at       DATETIME      NULL DEFAULT NULL,
+    UNIQUE (subscription_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due ON webhook_delivery (status, next_attempt_at);
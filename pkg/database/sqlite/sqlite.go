@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"flashcard_service/pkg/database"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed schema.sql
+var schema string
+
+// SQLite is an embedded database for local development and tests. It keeps a
+// single connection, which serializes every statement and is what keeps an
+// in-memory database alive between them. The schema is created on Connect.
+type SQLite struct {
+	conn         string
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+// NewSQLite opens the file at SQLITE_PATH, or an in-memory database when it
+// is empty or ":memory:".
+func NewSQLite() database.Database {
+	return NewSQLiteWithPath(os.Getenv("SQLITE_PATH"))
+}
+
+func NewSQLiteWithPath(path string) database.Database {
+	if path == "" {
+		path = ":memory:"
+	}
+	queryTimeout, err := strconv.Atoi(os.Getenv("SQLITE_QUERY_TIMEOUT_BY_SECOND"))
+	if err != nil || queryTimeout <= 0 {
+		queryTimeout = 15
+	}
+	return &SQLite{
+		conn:         "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+		queryTimeout: time.Second * time.Duration(queryTimeout),
+	}
+}
+
+func (s *SQLite) Connect() error {
+	db, err := sql.Open("sqlite", s.conn)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open SQLite database")
+		return err
+	}
+	s.db = db
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	defer cancel()
+	_, err = db.ExecContext(ctx, schema)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create SQLite schema")
+		return err
+	}
+	log.Info().Msg("Opened SQLite database")
+	return s.Ping()
+}
+
+func (s *SQLite) Dialect() database.Dialect {
+	return database.DialectSQLite
+}
+
+func (s *SQLite) Close() error {
+	err := s.db.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to close SQLite database")
+		return err
+	}
+	log.Info().Msg("Closed SQLite database")
+	return nil
+}
+
+func (s *SQLite) Ping() error {
+	return s.db.Ping()
+}
+
+// Primary returns the database itself, there are no replicas.
+func (s *SQLite) Primary() database.Database {
+	return s
+}
+
+func (s *SQLite) QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return rows, cancel, nil
+}
+
+func (s *SQLite) Exec(query string, args ...any) (sql.Result, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	r, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return r, cancel, nil
+}
+
+func (s *SQLite) QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout)
+	row := s.db.QueryRowContext(ctx, query, args...)
+	if row.Err() != nil {
+		return nil, cancel, row.Err()
+	}
+	return row, cancel, nil
+}
+
+func (s *SQLite) Begin() (database.Transaction, error) {
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{tx: tx, queryTimeout: s.queryTimeout}, nil
+}
+
+type sqliteTx struct {
+	tx           *sql.Tx
+	queryTimeout time.Duration
+}
+
+func (t *sqliteTx) Dialect() database.Dialect {
+	return database.DialectSQLite
+}
+
+func (t *sqliteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (t *sqliteTx) QueryRows(query string, args ...any) (*sql.Rows, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return rows, cancel, nil
+}
+
+func (t *sqliteTx) Exec(query string, args ...any) (sql.Result, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	r, err := t.tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, cancel, err
+	}
+	return r, cancel, nil
+}
+
+func (t *sqliteTx) QueryRow(query string, args ...any) (*sql.Row, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), t.queryTimeout)
+	row := t.tx.QueryRowContext(ctx, query, args...)
+	if row.Err() != nil {
+		return nil, cancel, row.Err()
+	}
+	return row, cancel, nil
+}
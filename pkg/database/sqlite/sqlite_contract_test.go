@@ -0,0 +1,17 @@
+package sqlite_test
+
+import (
+	"flashcard_service/internal/repositories/repotest"
+	"flashcard_service/pkg/database/sqlite"
+	"testing"
+)
+
+func TestRepositoryContract(t *testing.T) {
+	db := sqlite.NewSQLiteWithPath(":memory:")
+	err := db.Connect()
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+	repotest.Run(t, db)
+}
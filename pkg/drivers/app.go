@@ -1,13 +1,25 @@
 package drivers
 
 import (
+	"context"
 	"flashcard_service/internal/app_log"
 	"flashcard_service/internal/controllers/app"
 	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/controllers/offline_sync"
+	"flashcard_service/internal/controllers/stream"
+	"flashcard_service/internal/controllers/trash"
+	"flashcard_service/internal/controllers/webhook"
+	"flashcard_service/internal/jobs"
 	"flashcard_service/internal/middleware"
+	"flashcard_service/internal/worker"
 	"flashcard_service/pkg"
+	"flashcard_service/pkg/cache"
+	"flashcard_service/pkg/database"
 	"flashcard_service/pkg/database/mysql"
+	"flashcard_service/pkg/database/postgres"
 	"flashcard_service/pkg/database/redis"
+	"flashcard_service/pkg/database/sqlite"
+	"flashcard_service/pkg/utils"
 
 	"github.com/rs/zerolog/log"
 
@@ -25,34 +37,95 @@ const GetCateforyByID = "/{id}"
 const GetAllCategories = ""
 const UpdateCategoryByID = "/{id}"
 const DeleteCategoryByID = "/{id}"
+const PatchCategoryByID = "/{id}"
 const GetFlashcards = "/{category_id}/flashcards"
 const CreateNewFlashcards = "/{category_id}/flashcards"
+const GetFlashcard = "/{category_id}/flashcards/{flashcard_id}"
 const DeleteFlashcard = "/{category_id}/flashcards/{flashcard_id}"
 const UpdateFlashcard = "/{category_id}/flashcards/{flashcard_id}"
+const PatchFlashcard = "/{category_id}/flashcards/{flashcard_id}"
+const MoveFlashcard = "/{category_id}/flashcards/{flashcard_id}/move"
+const CopyFlashcard = "/{category_id}/flashcards/{flashcard_id}/copy"
+const GetFlashcardRevisions = "/{category_id}/flashcards/{flashcard_id}/revisions"
+const RestoreFlashcardRevision = "/{category_id}/flashcards/{flashcard_id}/revisions/{revision}/restore"
+
+const FlashcardControllerPrefix = "/flashcards"
+const BulkFlashcards = "/bulk"
+
+const TrashControllerPrefix = "/trash"
+const GetTrash = ""
+const RestoreFromTrash = "/{type}/{id}/restore"
+
+const SyncControllerPrefix = "/sync"
+const GetSyncChanges = "/changes"
+const PushSyncMutations = "/push"
+
+const StreamEvents = "/events"
+
+const WebhookControllerPrefix = "/webhooks"
+const CreateWebhook = ""
+const GetWebhooks = ""
+const GetWebhookByID = "/{id}"
+const UpdateWebhookByID = "/{id}"
+const DeleteWebhookByID = "/{id}"
+const GetWebhookDeliveries = "/{id}/deliveries"
+const RetryWebhookDelivery = "/{id}/deliveries/{delivery_id}/retry"
 
 const HeathCheck = "/health"
+const CacheStats = "/metrics/cache"
 
-func Run() {
+// Run starts the server. In dev mode it needs no external service: the data
+// lives in SQLite and the cache in process memory. The outbox relay and the
+// webhook dispatch read Redis streams and stay off in dev mode.
+func Run(dev bool) {
 	pkg.LoadConfig()
 	app_log.InitLogger()
-	sqlDb := mysql.NewMySql()
-	err := sqlDb.Connect()
-	if err != nil {
-		log.Fatal().Msg("Error when connect to db: " + err.Error())
+	var sqlDb database.Database
+	var store cache.Store
+	var redisDb *redis.RedisDatabase
+	if dev {
+		sqlDb = connectDev()
+		store = cache.NewMemoryStore()
+	} else {
+		sqlDb, redisDb = connect()
+		store = redisDb
 	}
-	log.Info().Msg("Connect to db successfully")
 
-	redis := redis.NewRedisClient()
-	err = redis.Connect()
-	if err != nil {
-		log.Fatal().Msg("Error when connect to redis: " + err.Error())
-		panic(err)
+	workerQueue := worker.NewQueueFromEnv()
+	categoryService := category.NewCategoryService(store, workerQueue)
+	go categoryService.ListenForInvalidations(context.Background())
+
+	router := NewRouter(sqlDb, store, categoryService)
+
+	jobs.NewTrashPurgeJob(sqlDb).Start()
+	jobs.NewWebhookDeliveryJob(sqlDb).Start()
+	jobs.NewCacheWarmJob(sqlDb, store, categoryService, jobs.CacheWarmOptionsFromEnv()).Start()
+	if redisDb != nil {
+		jobs.NewOutboxRelayJob(sqlDb, redisDb).Start()
+		jobs.NewWebhookDispatchJob(sqlDb, redisDb).Start()
+	} else {
+		log.Info().Msg("Outbox relay and webhook dispatch are off in dev mode")
 	}
-	log.Info().Msg("Connect to redis successfully")
 
+	log.Info().Msg("Server is running on port: " + os.Getenv("SERVER_PORT"))
+	http.ListenAndServe(":"+os.Getenv("SERVER_PORT"), router)
+}
+
+// NewRouter wires every route of the API on top of the given database and
+// cache store.
+func NewRouter(sqlDb database.Database, store cache.Store, categoryService *category.CategoryService) *mux.Router {
 	router := mux.NewRouter()
-	appController := &app.AppController{}
+	// Subrouters fall back to these for unknown routes. mux reports a wrong
+	// method on a subrouter route as not found.
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteError(w, r, utils.ErrRouteNotFound, nil)
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteError(w, r, utils.ErrMethodNotAllowed, nil)
+	})
+	appController := app.NewAppController(categoryService)
 	router.HandleFunc(HeathCheck, appController.HeathCheck).Methods(http.MethodGet)
+	router.HandleFunc(CacheStats, appController.CacheStats).Methods(http.MethodGet)
 
 	router.Use(
 		middleware.XssProtectionMiddleware,
@@ -63,17 +136,89 @@ func Run() {
 
 	baseRouter := router.PathPrefix(apiV1Prefix).Subrouter()
 
-	categoryController := category.NewCategoryController(sqlDb, redis)
+	categoryController := category.NewCategoryController(sqlDb, categoryService)
 	categoryRouter := baseRouter.PathPrefix(CategoryControllerPrefix).Subrouter()
-	categoryRouter.HandleFunc(CreateCategory, categoryController.CreateCategory).Methods(http.MethodPost)
+	idempotencyMiddleware := middleware.NewIdempotencyMiddleware(store)
+	categoryRouter.Handle(CreateCategory, utils.ChainMiddlewares(http.HandlerFunc(categoryController.CreateCategory), idempotencyMiddleware)).Methods(http.MethodPost)
 	categoryRouter.HandleFunc(GetAllCategories, categoryController.GetAllCategory).Methods(http.MethodGet)
+	categoryRouter.HandleFunc(GetCateforyByID, categoryController.GetCategory).Methods(http.MethodGet)
 	categoryRouter.HandleFunc(DeleteCategoryByID, categoryController.DeleteCategory).Methods(http.MethodDelete)
 	categoryRouter.HandleFunc(UpdateCategoryByID, categoryController.UpdateCategory).Methods(http.MethodPut)
-	categoryRouter.HandleFunc(CreateNewFlashcards, categoryController.CreateNewFlashcards).Methods(http.MethodPost)
+	categoryRouter.HandleFunc(PatchCategoryByID, categoryController.PatchCategory).Methods(http.MethodPatch)
+	categoryRouter.Handle(CreateNewFlashcards, utils.ChainMiddlewares(http.HandlerFunc(categoryController.CreateNewFlashcards), idempotencyMiddleware)).Methods(http.MethodPost)
 	categoryRouter.HandleFunc(GetFlashcards, categoryController.GetFlashcardsByCategoryId).Methods(http.MethodGet)
+	categoryRouter.HandleFunc(GetFlashcard, categoryController.GetFlashcard).Methods(http.MethodGet)
 	categoryRouter.HandleFunc(DeleteFlashcard, categoryController.DeleteFlashcard).Methods(http.MethodDelete)
 	categoryRouter.HandleFunc(UpdateFlashcard, categoryController.UpdateFlashcard).Methods(http.MethodPut)
+	categoryRouter.HandleFunc(PatchFlashcard, categoryController.PatchFlashcard).Methods(http.MethodPatch)
+	categoryRouter.HandleFunc(MoveFlashcard, categoryController.MoveFlashcard).Methods(http.MethodPost)
+	categoryRouter.Handle(CopyFlashcard, utils.ChainMiddlewares(http.HandlerFunc(categoryController.CopyFlashcard), idempotencyMiddleware)).Methods(http.MethodPost)
+	categoryRouter.HandleFunc(GetFlashcardRevisions, categoryController.GetFlashcardRevisions).Methods(http.MethodGet)
+	categoryRouter.HandleFunc(RestoreFlashcardRevision, categoryController.RestoreFlashcardRevision).Methods(http.MethodPost)
 
-	log.Info().Msg("Server is running on port: " + os.Getenv("SERVER_PORT"))
-	http.ListenAndServe(":"+os.Getenv("SERVER_PORT"), router)
+	flashcardRouter := baseRouter.PathPrefix(FlashcardControllerPrefix).Subrouter()
+	flashcardRouter.Handle(BulkFlashcards, utils.ChainMiddlewares(http.HandlerFunc(categoryController.BulkFlashcards), idempotencyMiddleware)).Methods(http.MethodPost)
+
+	trashController := trash.NewTrashController(sqlDb, categoryService)
+	trashRouter := baseRouter.PathPrefix(TrashControllerPrefix).Subrouter()
+	trashRouter.HandleFunc(GetTrash, trashController.GetTrash).Methods(http.MethodGet)
+	trashRouter.HandleFunc(RestoreFromTrash, trashController.Restore).Methods(http.MethodPost)
+
+	syncController := offline_sync.NewSyncController(sqlDb, categoryService)
+	syncRouter := baseRouter.PathPrefix(SyncControllerPrefix).Subrouter()
+	syncRouter.HandleFunc(GetSyncChanges, syncController.GetChanges).Methods(http.MethodGet)
+	syncRouter.Handle(PushSyncMutations, utils.ChainMiddlewares(http.HandlerFunc(syncController.Push), idempotencyMiddleware)).Methods(http.MethodPost)
+
+	eventStreamController := stream.NewEventStreamController(store)
+	baseRouter.HandleFunc(StreamEvents, eventStreamController.StreamEvents).Methods(http.MethodGet)
+
+	webhookController := webhook.NewWebhookController(sqlDb)
+	webhookRouter := baseRouter.PathPrefix(WebhookControllerPrefix).Subrouter()
+	webhookRouter.HandleFunc(CreateWebhook, webhookController.CreateWebhook).Methods(http.MethodPost)
+	webhookRouter.HandleFunc(GetWebhooks, webhookController.GetWebhooks).Methods(http.MethodGet)
+	webhookRouter.HandleFunc(GetWebhookByID, webhookController.GetWebhook).Methods(http.MethodGet)
+	webhookRouter.HandleFunc(UpdateWebhookByID, webhookController.UpdateWebhook).Methods(http.MethodPut)
+	webhookRouter.HandleFunc(DeleteWebhookByID, webhookController.DeleteWebhook).Methods(http.MethodDelete)
+	webhookRouter.HandleFunc(GetWebhookDeliveries, webhookController.GetWebhookDeliveries).Methods(http.MethodGet)
+	webhookRouter.HandleFunc(RetryWebhookDelivery, webhookController.RetryWebhookDelivery).Methods(http.MethodPost)
+
+	return router
+}
+
+func connect() (database.Database, *redis.RedisDatabase) {
+	var sqlDb database.Database
+	switch os.Getenv("DB_DRIVER") {
+	case "", "mysql":
+		sqlDb = mysql.NewMySql()
+	case "postgres":
+		sqlDb = postgres.NewPostgres()
+	case "sqlite":
+		sqlDb = sqlite.NewSQLite()
+	default:
+		log.Fatal().Msg("Unknown DB_DRIVER " + os.Getenv("DB_DRIVER"))
+	}
+	err := sqlDb.Connect()
+	if err != nil {
+		log.Fatal().Msg("Error when connect to db: " + err.Error())
+	}
+	log.Info().Msg("Connect to db successfully")
+
+	redisDb := redis.NewRedisClient()
+	err = redisDb.Connect()
+	if err != nil {
+		log.Fatal().Msg("Error when connect to redis: " + err.Error())
+	}
+	log.Info().Msg("Connect to redis successfully")
+	return sqlDb, redisDb
+}
+
+// connectDev opens the SQLite database at SQLITE_PATH, in memory when unset.
+func connectDev() database.Database {
+	sqlDb := sqlite.NewSQLite()
+	err := sqlDb.Connect()
+	if err != nil {
+		log.Fatal().Msg("Error when open sqlite db: " + err.Error())
+	}
+	log.Info().Msg("Open sqlite db successfully")
+	return sqlDb
 }
@@ -0,0 +1,126 @@
+package drivers
+
+import (
+	"flag"
+	"flashcard_service/internal/app_log"
+	"flashcard_service/internal/controllers/category"
+	"flashcard_service/internal/jobs"
+	"flashcard_service/internal/worker"
+	"flashcard_service/pkg"
+	"fmt"
+	"os"
+	"time"
+)
+
+const usage = `Usage:
+  flashcard_service [serve [--dev]]
+  flashcard_service outbox replay --from <id> --to <id>
+  flashcard_service cache warm|verify [--active-within-hours <n>] [--max-users <n>] [--concurrency <n>]`
+
+// RunCommand dispatches the command line arguments, without the program name.
+// No argument starts the server.
+func RunCommand(args []string) {
+	if len(args) == 0 {
+		Run(false)
+		return
+	}
+
+	switch args[0] {
+	case "serve":
+		flags := flag.NewFlagSet("serve", flag.ExitOnError)
+		dev := flags.Bool("dev", false, "run on SQLite and an in-memory cache, without MySQL or Redis")
+		flags.Parse(args[1:])
+		Run(*dev)
+	case "outbox":
+		runOutboxCommand(args[1:])
+	case "cache":
+		runCacheCommand(args[1:])
+	default:
+		exitWithUsage("unknown command: " + args[0])
+	}
+}
+
+func runOutboxCommand(args []string) {
+	if len(args) == 0 || args[0] != "replay" {
+		exitWithUsage("unknown outbox command")
+	}
+
+	flags := flag.NewFlagSet("outbox replay", flag.ExitOnError)
+	from := flags.Int64("from", 0, "first outbox id to replay")
+	to := flags.Int64("to", 0, "last outbox id to replay")
+	flags.Parse(args[1:])
+	if *from <= 0 || *to < *from {
+		exitWithUsage("--from and --to must be outbox ids with from <= to")
+	}
+
+	pkg.LoadConfig()
+	app_log.InitLogger()
+	sqlDb, redis := connect()
+	defer sqlDb.Close()
+
+	replayed, err := jobs.NewOutboxRelayJob(sqlDb, redis).Replay(*from, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replayed %d entries before failing: %v\n", replayed, err)
+		os.Exit(1)
+	}
+	fmt.Printf("replayed %d entries\n", replayed)
+}
+
+func runCacheCommand(args []string) {
+	if len(args) == 0 || (args[0] != "warm" && args[0] != "verify") {
+		exitWithUsage("unknown cache command")
+	}
+
+	pkg.LoadConfig()
+	options := jobs.CacheWarmOptionsFromEnv()
+	flags := flag.NewFlagSet("cache "+args[0], flag.ExitOnError)
+	activeWithinHours := flags.Int("active-within-hours", int(options.ActiveWithin/time.Hour), "users changed within this many hours")
+	maxUsers := flags.Int("max-users", options.MaxUsers, "most recently active users to process")
+	concurrency := flags.Int("concurrency", options.Concurrency, "users processed at the same time")
+	flags.Parse(args[1:])
+	if *activeWithinHours <= 0 || *maxUsers <= 0 || *concurrency <= 0 {
+		exitWithUsage("--active-within-hours, --max-users and --concurrency must be positive")
+	}
+	options.ActiveWithin = time.Duration(*activeWithinHours) * time.Hour
+	options.MaxUsers = *maxUsers
+	options.Concurrency = *concurrency
+
+	app_log.InitLogger()
+	sqlDb, redis := connect()
+	defer sqlDb.Close()
+	workerQueue := worker.NewQueueFromEnv()
+	defer workerQueue.Close()
+	job := jobs.NewCacheWarmJob(sqlDb, redis, category.NewCategoryService(redis, workerQueue), options)
+
+	if args[0] == "warm" {
+		report, err := job.Warm()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warm failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("warmed %d users, %d categories, %d decks, %d failed\n", report.Users, report.Categories, report.Decks, report.Failed)
+		if report.Failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	report, err := job.Verify()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify failed: %v\n", err)
+		os.Exit(1)
+	}
+	for _, drift := range report.Drifts {
+		fmt.Printf("%s\tuser=%s\tkey=%s\tfield=%s\n", drift.Kind, drift.UserId, drift.Key, drift.Field)
+	}
+	fmt.Printf("verified %d users, %d keys, %d not cached, %d drifted, %d failed\n", report.Users, report.Keys, report.MissingKeys, len(report.Drifts), report.Failed)
+	if len(report.Drifts) > 0 || report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func exitWithUsage(message string) {
+	fmt.Fprintln(os.Stderr, message)
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(2)
+}
@@ -20,11 +20,11 @@ func LoadConfig() {
 	case "development":
 		envFile = ".env.development"
 	default:
-		log.Fatalf("ENV không hợp lệ: %s. Chỉ hỗ trợ 'development' hoặc 'production'", env)
+		log.Fatalf("Invalid ENV %s, only 'development' and 'production' are supported", env)
 	}
 
 	err := godotenv.Load(envFile)
 	if err != nil {
-		log.Fatalf("Lỗi khi load file %s: %v", envFile, err)
+		log.Fatalf("Error when load %s: %v", envFile, err)
 	}
 }
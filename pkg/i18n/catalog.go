@@ -0,0 +1,72 @@
+package i18n
+
+var catalog = map[string]map[string]string{
+	Vietnamese: {
+		"OK":                         "Thành công",
+		"NOT_FOUND":                  "Không tìm thấy tài nguyên",
+		"BAD_REQUEST":                "Yêu cầu không hợp lệ",
+		"INTERNAL_ERROR":             "Lỗi máy chủ",
+		"UNAUTHORIZED":               "Không có quyền truy cập",
+		"CONFLICT":                   "Xung đột dữ liệu",
+		"UNPROCESSABLE_ENTITY":       "Không thể xử lý yêu cầu",
+		"PRECONDITION_REQUIRED":      "Thiếu điều kiện If-Match",
+		"VALIDATION_FAILED":          "Dữ liệu không hợp lệ",
+		"MALFORMED_BODY":             "Nội dung yêu cầu không đúng định dạng",
+		"VERSION_MISMATCH":           "Dữ liệu đã bị thay đổi",
+		"CATEGORY_NOT_FOUND":         "Không tìm thấy danh mục",
+		"FLASHCARD_NOT_FOUND":        "Không tìm thấy thẻ",
+		"REVISION_NOT_FOUND":         "Không tìm thấy phiên bản của thẻ",
+		"WEBHOOK_NOT_FOUND":          "Không tìm thấy webhook",
+		"WEBHOOK_DELIVERY_NOT_FOUND": "Không tìm thấy lần gửi webhook",
+		"IDEMPOTENCY_KEY_REUSED":     "Idempotency-Key đã được dùng cho yêu cầu khác",
+		"REQUEST_IN_PROGRESS":        "Yêu cầu đang được xử lý",
+		"ROUTE_NOT_FOUND":            "Không tìm thấy đường dẫn",
+		"METHOD_NOT_ALLOWED":         "Phương thức không được hỗ trợ",
+		"REQUEST_TOO_LARGE":          "Nội dung yêu cầu quá lớn",
+
+		"field.required":  "Không được để trống",
+		"field.invalid":   "Giá trị không hợp lệ",
+		"field.too_many":  "Tối đa {max} phần tử",
+		"field.too_long":  "Tối đa {max} ký tự",
+		"field.too_short": "Tối thiểu {min} ký tự",
+		"field.too_few":   "Tối thiểu {min} phần tử",
+		"field.too_small": "Tối thiểu là {min}",
+		"field.too_large": "Tối đa là {max}",
+		"field.unknown":   "Trường không được hỗ trợ",
+		"field.forbidden": "Không được phép dùng giá trị này",
+	},
+	English: {
+		"OK":                         "Success",
+		"NOT_FOUND":                  "Resource not found",
+		"BAD_REQUEST":                "Invalid request",
+		"INTERNAL_ERROR":             "Internal server error",
+		"UNAUTHORIZED":               "Unauthorized",
+		"CONFLICT":                   "Data conflict",
+		"UNPROCESSABLE_ENTITY":       "Request cannot be processed",
+		"PRECONDITION_REQUIRED":      "If-Match header is required",
+		"VALIDATION_FAILED":          "Validation failed",
+		"MALFORMED_BODY":             "Request body is malformed",
+		"VERSION_MISMATCH":           "The resource was modified",
+		"CATEGORY_NOT_FOUND":         "Category not found",
+		"FLASHCARD_NOT_FOUND":        "Flashcard not found",
+		"REVISION_NOT_FOUND":         "Flashcard revision not found",
+		"WEBHOOK_NOT_FOUND":          "Webhook not found",
+		"WEBHOOK_DELIVERY_NOT_FOUND": "Webhook delivery not found",
+		"IDEMPOTENCY_KEY_REUSED":     "Idempotency-Key was used with a different request",
+		"REQUEST_IN_PROGRESS":        "Request is still in progress",
+		"ROUTE_NOT_FOUND":            "Route not found",
+		"METHOD_NOT_ALLOWED":         "Method not allowed",
+		"REQUEST_TOO_LARGE":          "Request body is too large",
+
+		"field.required":  "Is required",
+		"field.invalid":   "Is invalid",
+		"field.too_many":  "At most {max} items",
+		"field.too_long":  "At most {max} characters",
+		"field.too_short": "At least {min} characters",
+		"field.too_few":   "At least {min} items",
+		"field.too_small": "Must be at least {min}",
+		"field.too_large": "Must be at most {max}",
+		"field.unknown":   "Unknown field",
+		"field.forbidden": "This value is not allowed",
+	},
+}
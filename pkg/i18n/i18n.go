@@ -0,0 +1,70 @@
+// Package i18n translates the messages the API returns. Messages are keyed by
+// error code, or by "field."+reason for field errors.
+package i18n
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	Vietnamese = "vi"
+	English    = "en"
+)
+
+// supported lists the languages of the catalog. The first one is what the
+// matcher falls back to when nothing in Accept-Language is supported, and is
+// replaced by DEFAULT_LANGUAGE at lookup time.
+var supported = []language.Tag{language.Vietnamese, language.English}
+
+var matcher = language.NewMatcher(supported)
+
+// DefaultLanguage is the language of clients that ask for none we support,
+// DEFAULT_LANGUAGE or Vietnamese.
+func DefaultLanguage() string {
+	lang := os.Getenv("DEFAULT_LANGUAGE")
+	if _, ok := catalog[lang]; ok {
+		return lang
+	}
+	return Vietnamese
+}
+
+// FromRequest picks the response language from the Accept-Language header.
+// The service keeps no user profile, so a gateway that knows the user's
+// language should pass it in that header.
+func FromRequest(r *http.Request) string {
+	header := r.Header.Get("Accept-Language")
+	if len(header) == 0 {
+		return DefaultLanguage()
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return DefaultLanguage()
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLanguage()
+	}
+	base, _ := supported[index].Base()
+	return base.String()
+}
+
+// Translate returns the message of key in lang with each {name} replaced by
+// params[name]. Keys missing in lang fall back to the default language, then
+// to the key itself.
+func Translate(lang string, key string, params map[string]string) string {
+	message, ok := catalog[lang][key]
+	if !ok {
+		message, ok = catalog[DefaultLanguage()][key]
+	}
+	if !ok {
+		return key
+	}
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+	return message
+}
@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", Vietnamese},
+		{"en", English},
+		{"en-US,en;q=0.9", English},
+		{"fr-FR, en;q=0.5", English},
+		{"vi-VN", Vietnamese},
+		{"de", Vietnamese},
+		{"not a language;;", Vietnamese},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", test.header)
+		if got := FromRequest(r); got != test.want {
+			t.Errorf("FromRequest(%q) = %q, want %q", test.header, got, test.want)
+		}
+	}
+}
+
+func TestDefaultLanguageFromEnv(t *testing.T) {
+	t.Setenv("DEFAULT_LANGUAGE", English)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "de")
+	if got := FromRequest(r); got != English {
+		t.Fatalf("FromRequest = %q, want the configured default", got)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := Translate(English, "field.too_long", map[string]string{"max": "255"}); got != "At most 255 characters" {
+		t.Fatalf("Translate = %q", got)
+	}
+	if got := Translate("fr", "CATEGORY_NOT_FOUND", nil); got != catalog[Vietnamese]["CATEGORY_NOT_FOUND"] {
+		t.Fatalf("unknown language did not fall back to the default: %q", got)
+	}
+	if got := Translate(English, "NO_SUCH_CODE", nil); got != "NO_SUCH_CODE" {
+		t.Fatalf("unknown key = %q", got)
+	}
+}
+
+func TestCatalogsHaveTheSameKeys(t *testing.T) {
+	for lang, messages := range catalog {
+		for other, otherMessages := range catalog {
+			for key := range messages {
+				if _, ok := otherMessages[key]; !ok {
+					t.Errorf("%s is in %s but not in %s", key, lang, other)
+				}
+			}
+		}
+	}
+}
@@ -1,7 +1,21 @@
 package objects
 
+// ApiResponse is the body of every response. Code repeats the HTTP status;
+// ErrorCode and Details are only set on errors.
 type ApiResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Data    any    `json:"data"`
+	Code      int          `json:"code"`
+	Message   string       `json:"message"`
+	ErrorCode string       `json:"errorCode,omitempty"`
+	Details   []FieldError `json:"details,omitempty"`
+	Data      any          `json:"data"`
+}
+
+// FieldError tells which part of the request failed validation. Field is a
+// JSON field or a header name, Code a machine-readable reason such as
+// "required" and Params the values the reason refers to, such as "max".
+type FieldError struct {
+	Field   string            `json:"field"`
+	Code    string            `json:"code"`
+	Params  map[string]string `json:"params,omitempty"`
+	Message string            `json:"message"`
 }
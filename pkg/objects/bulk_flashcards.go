@@ -0,0 +1,31 @@
+package objects
+
+import "flashcard_service/internal/model"
+
+const (
+	BulkOpDelete = "delete"
+	BulkOpMove   = "move"
+
+	BulkStatusApplied   = "applied"
+	BulkStatusUnchanged = "unchanged"
+	BulkStatusNotFound  = "not_found"
+)
+
+// BulkFlashcards applies one operation to many flashcards of a user. Move
+// needs CategoryId, the category the cards go to. Tagging and resetting the
+// review schedule are not offered: flashcards have no tags or scheduling.
+type BulkFlashcards struct {
+	Op         string  `json:"op" validate:"required"`
+	Ids        []int64 `json:"ids" validate:"required"`
+	CategoryId int     `json:"categoryId"`
+}
+
+type BulkFlashcardResult struct {
+	Id     int64  `json:"id"`
+	Status string `json:"status"`
+	// Flashcard is the moved card.
+	Flashcard *model.Flashcard `json:"flashcard,omitempty"`
+	// PreviousCategoryId is the category the card was in before the
+	// operation, whose cached deck the operation changed.
+	PreviousCategoryId int `json:"-"`
+}
@@ -0,0 +1,14 @@
+package objects
+
+import "flashcard_service/pkg/cache"
+
+type CacheTierStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+type CacheStats struct {
+	Local         cache.LRUStats `json:"local"`
+	Redis         CacheTierStats `json:"redis"`
+	DatabaseLoads int64          `json:"databaseLoads"`
+}
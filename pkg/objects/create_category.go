@@ -3,7 +3,7 @@ package objects
 import "flashcard_service/internal/model"
 
 type CreateCategory struct {
-	Name string
+	Name string `validate:"required,max=100,utf8"`
 }
 
 func (c CreateCategory) ToCategory() model.Category {
@@ -3,8 +3,8 @@ package objects
 import "flashcard_service/internal/model"
 
 type CreateFlashcard struct {
-	Name    string
-	Content string
+	Name    string `validate:"required,max=255,utf8"`
+	Content string `validate:"max=10000,utf8"`
 }
 
 func (c CreateFlashcard) ToFlashcard() model.Flashcard {
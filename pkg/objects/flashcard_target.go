@@ -0,0 +1,6 @@
+package objects
+
+// FlashcardTarget names the category a flashcard is moved or copied to.
+type FlashcardTarget struct {
+	CategoryId int `json:"categoryId" validate:"required,min=1"`
+}
@@ -0,0 +1,30 @@
+package objects
+
+import "encoding/json"
+
+// Optional is a field of a JSON Merge Patch (RFC 7396). Set tells a field left
+// out of the patch from one sent as null, which sets the zero value.
+type Optional[T any] struct {
+	Value T
+	Set   bool
+}
+
+// Some returns a set Optional holding value.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{Value: value, Set: true}
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	var zero T
+	o.Value = zero
+	if string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Present returns the value to validate and whether the field was sent.
+func (o Optional[T]) Present() (any, bool) {
+	return o.Value, o.Set
+}
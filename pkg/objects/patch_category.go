@@ -0,0 +1,9 @@
+package objects
+
+type PatchCategory struct {
+	Name Optional[string] `validate:"required,max=100,utf8"`
+}
+
+func (p PatchCategory) IsEmpty() bool {
+	return !p.Name.Set
+}
@@ -0,0 +1,29 @@
+package objects
+
+import "flashcard_service/internal/model"
+
+// PatchFlashcard is a merge patch of a flashcard. Content sent as null is
+// cleared; name and category cannot be.
+type PatchFlashcard struct {
+	Name       Optional[string] `validate:"required,max=255,utf8"`
+	Content    Optional[string] `validate:"max=10000,utf8"`
+	CategoryId Optional[int]    `validate:"required,min=1"`
+}
+
+func (p PatchFlashcard) IsEmpty() bool {
+	return !p.Name.Set && !p.Content.Set && !p.CategoryId.Set
+}
+
+// ApplyTo returns flashcard with the fields of the patch replaced.
+func (p PatchFlashcard) ApplyTo(flashcard model.Flashcard) model.Flashcard {
+	if p.Name.Set {
+		flashcard.Name = p.Name.Value
+	}
+	if p.Content.Set {
+		flashcard.Content = p.Content.Value
+	}
+	if p.CategoryId.Set {
+		flashcard.CategoryId = p.CategoryId.Value
+	}
+	return flashcard
+}
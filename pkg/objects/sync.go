@@ -0,0 +1,66 @@
+package objects
+
+import (
+	"flashcard_service/internal/model"
+	"time"
+)
+
+const (
+	SyncEntityCategory  = "category"
+	SyncEntityFlashcard = "flashcard"
+
+	SyncOpCreate = "create"
+	SyncOpUpdate = "update"
+	SyncOpDelete = "delete"
+
+	SyncConflictNotFound        = "not_found"
+	SyncConflictVersionMismatch = "version_mismatch"
+	SyncConflictStale           = "stale"
+	SyncConflictInvalid         = "invalid"
+)
+
+type SyncChanges struct {
+	Cursor        string            `json:"cursor"`
+	ResetRequired bool              `json:"resetRequired"`
+	Categories    []model.Category  `json:"categories"`
+	Flashcards    []model.Flashcard `json:"flashcards"`
+}
+
+type SyncPushRequest struct {
+	Mutations []SyncMutation `json:"mutations"`
+}
+
+// SyncMutation is one change made on a device while it was offline. A
+// non-zero Version asks for version-based conflict detection; otherwise the
+// change wins when ClientUpdatedAt is not older than the server copy.
+type SyncMutation struct {
+	ClientMutationId string     `json:"clientMutationId"`
+	Entity           string     `json:"entity"`
+	Op               string     `json:"op"`
+	Id               int64      `json:"id"`
+	Version          int64      `json:"version"`
+	ClientUpdatedAt  *time.Time `json:"clientUpdatedAt"`
+	CategoryId       int        `json:"categoryId"`
+	Name             string     `json:"name"`
+	Content          string     `json:"content"`
+}
+
+type SyncPushResult struct {
+	Applied   []SyncApplied  `json:"applied"`
+	Conflicts []SyncConflict `json:"conflicts"`
+}
+
+type SyncApplied struct {
+	ClientMutationId string `json:"clientMutationId"`
+	Entity           string `json:"entity"`
+	Id               int64  `json:"id"`
+	Version          int64  `json:"version"`
+}
+
+type SyncConflict struct {
+	ClientMutationId string `json:"clientMutationId"`
+	Entity           string `json:"entity"`
+	Id               int64  `json:"id"`
+	Reason           string `json:"reason"`
+	Current          any    `json:"current,omitempty"`
+}
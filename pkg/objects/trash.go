@@ -0,0 +1,8 @@
+package objects
+
+import "flashcard_service/internal/model"
+
+type Trash struct {
+	Categories []model.Category  `json:"categories"`
+	Flashcards []model.Flashcard `json:"flashcards"`
+}
@@ -1,5 +1,5 @@
 package objects
 
 type UpdateCategory struct {
-	Name string
+	Name string `validate:"required,max=100,utf8"`
 }
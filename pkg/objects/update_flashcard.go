@@ -3,9 +3,9 @@ package objects
 import "flashcard_service/internal/model"
 
 type UpdateFlashcard struct {
-	Name       string
-	Content    string
-	CategoryId int
+	Name       string `validate:"required,max=255,utf8"`
+	Content    string `validate:"max=10000,utf8"`
+	CategoryId int    `validate:"required,min=1"`
 }
 
 func (u UpdateFlashcard) ToFlashcard() model.Flashcard {
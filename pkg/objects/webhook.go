@@ -0,0 +1,20 @@
+package objects
+
+import "flashcard_service/internal/model"
+
+type CreateWebhook struct {
+	Url        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+}
+
+type UpdateWebhook struct {
+	Url        string   `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	Active     bool     `json:"active"`
+}
+
+// CreatedWebhook is the only response that carries the signing secret.
+type CreatedWebhook struct {
+	model.WebhookSubscription
+	Secret string `json:"secret"`
+}
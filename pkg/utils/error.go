@@ -3,28 +3,85 @@ package utils
 import (
 	"context"
 	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/objects"
+	"flashcard_service/pkg/validation"
 	"net/http"
+	"strconv"
 )
 
+// AppError is an error answered to the client. The message shown for it is
+// looked up by ErrorCode in the i18n catalog.
 type AppError struct {
-	Message      string `json:"message"`
-	Code         int    `json:"code"`
-	ErrorMessage string `json:"error_message,omitempty"`
+	Code         int                  `json:"code"`
+	ErrorCode    string               `json:"errorCode"`
+	ErrorMessage string               `json:"error_message,omitempty"`
+	Details      []objects.FieldError `json:"details,omitempty"`
 }
 
 func (e AppError) Error() string {
-	return e.Message
+	return e.ErrorCode
+}
+
+// WithDetails returns a copy of e carrying the given field errors.
+func (e AppError) WithDetails(details ...objects.FieldError) AppError {
+	e.Details = append(append([]objects.FieldError{}, e.Details...), details...)
+	return e
 }
 
 // Một số lỗi cụ thể
 var (
-	ErrNotFound     = AppError{Code: http.StatusNotFound, Message: "Không tìm thấy tài nguyên"}
-	ErrBadRequest   = AppError{Code: http.StatusBadRequest, Message: "Yêu cầu không hợp lệ"}
-	ErrServerError  = AppError{Code: http.StatusInternalServerError, Message: "Lỗi máy chủ"}
-	ErrUnAuthorized = AppError{Code: http.StatusUnauthorized, Message: "Không có quyền truy cập"}
-	OK              = AppError{Code: http.StatusOK, Message: "Thành công"}
+	ErrNotFound                = AppError{Code: http.StatusNotFound, ErrorCode: "NOT_FOUND"}
+	ErrBadRequest              = AppError{Code: http.StatusBadRequest, ErrorCode: "BAD_REQUEST"}
+	ErrServerError             = AppError{Code: http.StatusInternalServerError, ErrorCode: "INTERNAL_ERROR"}
+	ErrUnAuthorized            = AppError{Code: http.StatusUnauthorized, ErrorCode: "UNAUTHORIZED"}
+	ErrConflict                = AppError{Code: http.StatusConflict, ErrorCode: "CONFLICT"}
+	ErrUnprocessableEntity     = AppError{Code: http.StatusUnprocessableEntity, ErrorCode: "UNPROCESSABLE_ENTITY"}
+	ErrPreconditionRequired    = AppError{Code: http.StatusPreconditionRequired, ErrorCode: "PRECONDITION_REQUIRED"}
+	ErrValidationFailed        = AppError{Code: http.StatusBadRequest, ErrorCode: "VALIDATION_FAILED"}
+	ErrMalformedBody           = AppError{Code: http.StatusBadRequest, ErrorCode: "MALFORMED_BODY"}
+	ErrRequestTooLarge         = AppError{Code: http.StatusRequestEntityTooLarge, ErrorCode: "REQUEST_TOO_LARGE"}
+	ErrVersionMismatch         = AppError{Code: http.StatusPreconditionFailed, ErrorCode: "VERSION_MISMATCH"}
+	ErrCategoryNotFound        = AppError{Code: http.StatusNotFound, ErrorCode: "CATEGORY_NOT_FOUND"}
+	ErrFlashcardNotFound       = AppError{Code: http.StatusNotFound, ErrorCode: "FLASHCARD_NOT_FOUND"}
+	ErrRevisionNotFound        = AppError{Code: http.StatusNotFound, ErrorCode: "REVISION_NOT_FOUND"}
+	ErrWebhookNotFound         = AppError{Code: http.StatusNotFound, ErrorCode: "WEBHOOK_NOT_FOUND"}
+	ErrWebhookDeliveryNotFound = AppError{Code: http.StatusNotFound, ErrorCode: "WEBHOOK_DELIVERY_NOT_FOUND"}
+	ErrIdempotencyKeyReused    = AppError{Code: http.StatusUnprocessableEntity, ErrorCode: "IDEMPOTENCY_KEY_REUSED"}
+	ErrRequestInProgress       = AppError{Code: http.StatusConflict, ErrorCode: "REQUEST_IN_PROGRESS"}
+	ErrRouteNotFound           = AppError{Code: http.StatusNotFound, ErrorCode: "ROUTE_NOT_FOUND"}
+	ErrMethodNotAllowed        = AppError{Code: http.StatusMethodNotAllowed, ErrorCode: "METHOD_NOT_ALLOWED"}
 )
 
+// RequiredField reports a missing field or header.
+func RequiredField(field string) objects.FieldError {
+	return objects.FieldError{Field: field, Code: validation.Required}
+}
+
+// InvalidField reports a field or header whose value cannot be used.
+func InvalidField(field string) objects.FieldError {
+	return objects.FieldError{Field: field, Code: validation.Invalid}
+}
+
+// UnknownField reports a field the payload does not have.
+func UnknownField(field string) objects.FieldError {
+	return objects.FieldError{Field: field, Code: validation.Unknown}
+}
+
+// ForbiddenField reports a valid value the service refuses to use.
+func ForbiddenField(field string) objects.FieldError {
+	return objects.FieldError{Field: field, Code: validation.Forbidden}
+}
+
+// TooManyField reports a list longer than max.
+func TooManyField(field string, max int) objects.FieldError {
+	return objects.FieldError{Field: field, Code: validation.TooMany, Params: map[string]string{"max": strconv.Itoa(max)}}
+}
+
+// TooLongField reports a string longer than max characters.
+func TooLongField(field string, max int) objects.FieldError {
+	return objects.FieldError{Field: field, Code: validation.TooLong, Params: map[string]string{"max": strconv.Itoa(max)}}
+}
+
 func SetHttpReponseError(r *http.Request, err AppError, originalError error) {
 	err.ErrorMessage = originalError.Error()
 	ctx := context.WithValue(r.Context(), constant.AppErrorContextKey, err)
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flashcard_service/pkg/constant"
+	"flashcard_service/pkg/i18n"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VersionETag formats a row version as a strong entity tag.
+func VersionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ContentETag derives a weak entity tag from the data of a response. It is
+// weak because the envelope around the data, whose message follows
+// Accept-Language, is not part of it.
+func ContentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETagMatches reports whether an If-Match or If-None-Match header value lists
+// etag. Weak validators compare equal to their strong counterparts.
+func ETagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIfMatchVersion reads the row version out of an If-Match header built
+// from VersionETag. "*" matches any version and is returned as 0.
+func ParseIfMatchVersion(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, nil
+	}
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errors.New("invalid If-Match header: " + header)
+	}
+	return version, nil
+}
+
+// WriteJSONWithETag writes data in the ApiResponse envelope with an ETag
+// header, or answers 304 Not Modified when the request already holds that
+// representation. An empty etag is a weak tag derived from the encoded data
+// alone, so the localized message of the envelope does not change it.
+func WriteJSONWithETag(w http.ResponseWriter, r *http.Request, etag string, data any) {
+	if len(etag) == 0 {
+		encoded, _ := json.Marshal(data)
+		etag = ContentETag(encoded)
+	}
+
+	w.Header().Set(constant.ETagHeader, etag)
+	// The envelope message follows Accept-Language while the tag does not.
+	w.Header().Add("Vary", "Accept-Language")
+	if ifNoneMatch := r.Header.Get(constant.IfNoneMatchHeader); len(ifNoneMatch) > 0 && ETagMatches(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(success(r, http.StatusOK, data))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", i18n.FromRequest(r))
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxRequestBodyBytes caps every request body the service reads.
+const MaxRequestBodyBytes = 1 << 20
+
+// DecodeJSON decodes the request body into v. Bodies over 1 MiB, bodies that
+// are not valid UTF-8 and fields v does not have are rejected; the returned
+// AppError is how to answer the failure.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) (AppError, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ErrRequestTooLarge, err
+	}
+	if err != nil {
+		return ErrMalformedBody, err
+	}
+	if !utf8.Valid(body) {
+		return ErrMalformedBody, errors.New("body is not valid UTF-8")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err != nil {
+		if field, ok := unknownField(err); ok {
+			return ErrValidationFailed.WithDetails(UnknownField(field)), err
+		}
+		return ErrMalformedBody, err
+	}
+	if decoder.More() {
+		return ErrMalformedBody, errors.New("body holds more than one JSON value")
+	}
+	return AppError{}, nil
+}
+
+// unknownField extracts the field name from the error encoding/json returns
+// for a field rejected by DisallowUnknownFields.
+func unknownField(err error) (string, bool) {
+	quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return "", false
+	}
+	field, err := strconv.Unquote(quoted)
+	if err != nil {
+		return quoted, true
+	}
+	return field, true
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"encoding/json"
+	"flashcard_service/pkg/i18n"
+	"flashcard_service/pkg/objects"
+	"net/http"
+)
+
+// WriteJSON writes data in the ApiResponse envelope with the given status.
+func WriteJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", i18n.FromRequest(r))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(success(r, status, data))
+}
+
+// WriteError writes err in the ApiResponse envelope with its status, its
+// messages in the language the client asked for. data is for errors that
+// carry a body, such as the current version on a 412.
+func WriteError(w http.ResponseWriter, r *http.Request, err AppError, data any) {
+	lang := i18n.FromRequest(r)
+	details := make([]objects.FieldError, len(err.Details))
+	for i, detail := range err.Details {
+		detail.Message = i18n.Translate(lang, "field."+detail.Code, detail.Params)
+		details[i] = detail
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(err.Code)
+	json.NewEncoder(w).Encode(objects.ApiResponse{
+		Code:      err.Code,
+		Message:   i18n.Translate(lang, err.ErrorCode, nil),
+		ErrorCode: err.ErrorCode,
+		Details:   details,
+		Data:      data,
+	})
+}
+
+func success(r *http.Request, status int, data any) objects.ApiResponse {
+	return objects.ApiResponse{
+		Code:    status,
+		Message: i18n.Translate(i18n.FromRequest(r), "OK", nil),
+		Data:    data,
+	}
+}
@@ -0,0 +1,175 @@
+// Package validation checks request payloads against the rules in their
+// `validate` struct tags, for example `validate:"required,max=255,utf8"`.
+//
+//   - required: not empty. Strings must hold more than whitespace, numbers
+//     must not be 0, lists must not be empty.
+//   - min=N, max=N: bounds on the length of a string (in characters), the
+//     length of a list or the value of a number.
+//   - utf8: a string of valid UTF-8 without control characters other than
+//     tab and newlines.
+//
+// A field implementing Optional, as the fields of a merge patch do, is only
+// checked when it is present.
+//
+// Fields are reported by their JSON name, or by their Go name with a lower
+// case first letter when they have no json tag.
+package validation
+
+import (
+	"flashcard_service/pkg/objects"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reasons reported in objects.FieldError.Code.
+const (
+	Required = "required"
+	TooShort = "too_short"
+	TooLong  = "too_long"
+	TooFew   = "too_few"
+	TooMany  = "too_many"
+	TooSmall = "too_small"
+	TooLarge = "too_large"
+	Invalid  = "invalid"
+	Unknown  = "unknown"
+	// Forbidden is a well-formed value the service refuses, such as a
+	// webhook url pointing inside its network.
+	Forbidden = "forbidden"
+)
+
+// Optional is a field that may be left out of a payload. Present returns its
+// value and whether it was sent.
+type Optional interface {
+	Present() (any, bool)
+}
+
+// Validate checks v, a struct or a slice of structs, and returns one error
+// per broken rule. Elements of a slice are reported as "[i].field".
+func Validate(v any) []objects.FieldError {
+	details := make([]objects.FieldError, 0)
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			details = append(details, validateStruct(value.Index(i), "["+strconv.Itoa(i)+"].")...)
+		}
+		return details
+	}
+	return append(details, validateStruct(value, "")...)
+}
+
+func validateStruct(value reflect.Value, prefix string) []objects.FieldError {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	details := make([]objects.FieldError, 0)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		rules := field.Tag.Get("validate")
+		if len(rules) == 0 || !field.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(field)
+		fieldValue := value.Field(i)
+		if optional, ok := fieldValue.Interface().(Optional); ok {
+			present, set := optional.Present()
+			if !set {
+				continue
+			}
+			fieldValue = reflect.ValueOf(present)
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			detail, ok := check(fieldValue, name, rule)
+			if !ok {
+				details = append(details, detail)
+				// One error per field, the first rule it breaks.
+				break
+			}
+		}
+	}
+	return details
+}
+
+func fieldName(field reflect.StructField) string {
+	if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); len(tag) > 0 && tag != "-" {
+		return tag
+	}
+	return strings.ToLower(field.Name[:1]) + field.Name[1:]
+}
+
+func check(value reflect.Value, name string, rule string) (objects.FieldError, bool) {
+	ruleName, param, _ := strings.Cut(rule, "=")
+	switch ruleName {
+	case "required":
+		if isEmpty(value) {
+			return objects.FieldError{Field: name, Code: Required}, false
+		}
+	case "min", "max":
+		limit, err := strconv.Atoi(param)
+		if err != nil {
+			panic("validation: bad limit in rule " + rule + " of " + name)
+		}
+		return checkBound(value, name, ruleName, limit)
+	case "utf8":
+		if value.Kind() == reflect.String && !isText(value.String()) {
+			return objects.FieldError{Field: name, Code: Invalid}, false
+		}
+	default:
+		panic("validation: unknown rule " + rule + " of " + name)
+	}
+	return objects.FieldError{}, true
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return len(strings.TrimSpace(value.String())) == 0
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+func checkBound(value reflect.Value, name string, bound string, limit int) (objects.FieldError, bool) {
+	var size int64
+	var code string
+	switch value.Kind() {
+	case reflect.String:
+		size = int64(utf8.RuneCountInString(value.String()))
+		code = map[string]string{"min": TooShort, "max": TooLong}[bound]
+	case reflect.Slice, reflect.Map:
+		size = int64(value.Len())
+		code = map[string]string{"min": TooFew, "max": TooMany}[bound]
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = value.Int()
+		code = map[string]string{"min": TooSmall, "max": TooLarge}[bound]
+	default:
+		return objects.FieldError{}, true
+	}
+	if (bound == "min" && size >= int64(limit)) || (bound == "max" && size <= int64(limit)) {
+		return objects.FieldError{}, true
+	}
+	return objects.FieldError{Field: name, Code: code, Params: map[string]string{bound: strconv.Itoa(limit)}}, false
+}
+
+func isText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
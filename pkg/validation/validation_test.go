@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"flashcard_service/pkg/objects"
+	"strings"
+	"testing"
+)
+
+type card struct {
+	Name    string   `json:"name" validate:"required,max=5,utf8"`
+	Content string   `validate:"min=2"`
+	Tags    []string `json:"tags,omitempty" validate:"max=2"`
+	Level   int      `json:"level" validate:"min=1,max=3"`
+	Note    string
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		card  card
+		field string
+		code  string
+	}{
+		{"blank name", card{Name: "  ", Content: "ok", Level: 1}, "name", Required},
+		{"long name counts characters", card{Name: "tiếng việt", Content: "ok", Level: 1}, "name", TooLong},
+		{"control character", card{Name: "a\x00b", Content: "ok", Level: 1}, "name", Invalid},
+		{"short content uses the Go name", card{Name: "go", Content: "a", Level: 1}, "content", TooShort},
+		{"too many tags", card{Name: "go", Content: "ok", Tags: []string{"a", "b", "c"}, Level: 1}, "tags", TooMany},
+		{"number too small", card{Name: "go", Content: "ok"}, "level", TooSmall},
+		{"number too large", card{Name: "go", Content: "ok", Level: 4}, "level", TooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			details := Validate(test.card)
+			if len(details) != 1 || details[0].Field != test.field || details[0].Code != test.code {
+				t.Fatalf("Validate = %+v, want %s %s", details, test.field, test.code)
+			}
+		})
+	}
+
+	if details := Validate(card{Name: "việt", Content: "a\tb\n", Level: 3}); len(details) != 0 {
+		t.Fatalf("Validate(valid card) = %+v", details)
+	}
+}
+
+func TestValidateReportsSliceElements(t *testing.T) {
+	details := Validate([]card{
+		{Name: "go", Content: "ok", Level: 1},
+		{Name: strings.Repeat("a", 6), Content: "a", Level: 1},
+	})
+	if len(details) != 2 || details[0].Field != "[1].name" || details[1].Field != "[1].content" {
+		t.Fatalf("Validate = %+v", details)
+	}
+	if details[0].Params["max"] != "5" {
+		t.Fatalf("params = %+v, want max 5", details[0].Params)
+	}
+}
+
+func TestValidateChecksOnlyPresentOptionalFields(t *testing.T) {
+	type patch struct {
+		Name    objects.Optional[string] `validate:"required,max=5"`
+		Content objects.Optional[string] `validate:"max=5"`
+	}
+	if details := Validate(patch{}); len(details) != 0 {
+		t.Fatalf("Validate(empty patch) = %+v", details)
+	}
+	details := Validate(patch{Name: objects.Optional[string]{Set: true}, Content: objects.Some("too long")})
+	if len(details) != 2 || details[0].Code != Required || details[1].Code != TooLong {
+		t.Fatalf("Validate = %+v", details)
+	}
+}